@@ -0,0 +1,375 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/tenant"
+	"github.com/suyono3484/transactiondemo/types"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	// ScopeAdmin grants every scope.
+	ScopeAdmin Scope = "admin"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+	HMACScheme   = "HMAC-SHA256"
+
+	// MaxSkew is how far the timestamp of a signed request may be from the server clock.
+	MaxSkew = 5 * time.Minute
+
+	maxSignedBodySize = 32 << 20
+	// seenPruneInterval is how often the signatures past MaxSkew are dropped.
+	seenPruneInterval = time.Minute
+)
+
+// Key is an entry of the keys file, or the subject of a bearer token. A key of the file authenticates with
+// a static API key, whose SHA-256 hash is in the file, with an HMAC secret signing each request, or with
+// both. Tenant is the tenant the key is bound to, empty for the default tenant.
+type Key struct {
+	ID     string
+	Scopes []Scope
+	Tenant string
+	secret []byte
+}
+
+// Allows reports whether the key has the scope.
+func (k *Key) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticator checks the credentials of requests against the keys file and, with a JWTVerifier, the
+// bearer tokens.
+type Authenticator struct {
+	mtx      *sync.RWMutex
+	keys     map[string]*Key
+	hashes   map[[sha256.Size]byte]*Key
+	subjects map[string]*Key
+	jwt      *JWTVerifier
+
+	seenMtx   *sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// New returns an authenticator without keys, accepting only bearer tokens once SetJWTVerifier is called.
+func New() *Authenticator {
+	return &Authenticator{
+		mtx:      &sync.RWMutex{},
+		keys:     make(map[string]*Key),
+		hashes:   make(map[[sha256.Size]byte]*Key),
+		subjects: make(map[string]*Key),
+		seenMtx:  &sync.Mutex{},
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// SetJWTVerifier makes the authenticator accept bearer tokens validated by v.
+func (a *Authenticator) SetJWTVerifier(v *JWTVerifier) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.jwt = v
+}
+
+// Replace makes a accept the credentials of b instead of its own, e.g. once the keys file is read again.
+// The requests being authenticated complete with the former credentials; the signatures seen by a are
+// still rejected as replays.
+func (a *Authenticator) Replace(b *Authenticator) {
+	b.mtx.RLock()
+	keys, hashes, subjects, jwt := b.keys, b.hashes, b.subjects, b.jwt
+	b.mtx.RUnlock()
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.keys, a.hashes, a.subjects, a.jwt = keys, hashes, subjects, jwt
+}
+
+// Parse reads keys in the form "id scopes credentials...", one per line. scopes is a comma separated list
+// of read, write and admin. A credential is either sha256:hex, the hash of an API key, or hmac:base64, an
+// HMAC secret, or cert:name, the subject common name of a client certificate verified by the server. A
+// tenant:name entry among the credentials binds the key to the tenant. Empty lines and lines starting with #
+// are ignored.
+func Parse(spec string) (*Authenticator, error) {
+	a := New()
+
+	scan := bufio.NewScanner(strings.NewReader(spec))
+	for n := 1; scan.Scan(); n++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected id, scopes and credentials", n)
+		}
+
+		k := &Key{ID: fields[0]}
+		if _, exists := a.keys[k.ID]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key id %q", n, k.ID)
+		}
+
+		for _, s := range strings.Split(fields[1], ",") {
+			switch scope := Scope(s); scope {
+			case ScopeRead, ScopeWrite, ScopeAdmin:
+				k.Scopes = append(k.Scopes, scope)
+			default:
+				return nil, fmt.Errorf("line %d: unknown scope %q", n, s)
+			}
+		}
+
+		for _, cred := range fields[2:] {
+			kind, value, _ := strings.Cut(cred, ":")
+			switch kind {
+			case "sha256":
+				b, err := hex.DecodeString(value)
+				if err != nil || len(b) != sha256.Size {
+					return nil, fmt.Errorf("line %d: invalid sha256 hash", n)
+				}
+
+				var hash [sha256.Size]byte
+				copy(hash[:], b)
+				a.hashes[hash] = k
+			case "hmac":
+				b, err := base64.StdEncoding.DecodeString(value)
+				if err != nil || len(b) < 16 {
+					return nil, fmt.Errorf("line %d: hmac secret is not a base64 encoded secret of 16 bytes or more", n)
+				}
+				k.secret = b
+			case "cert":
+				if value == "" {
+					return nil, fmt.Errorf("line %d: cert requires the common name of the client certificate", n)
+				}
+				if _, exists := a.subjects[value]; exists {
+					return nil, fmt.Errorf("line %d: duplicate client certificate %q", n, value)
+				}
+				a.subjects[value] = k
+			case "tenant":
+				if value != types.DefaultTenant && !tenant.ValidName(value) {
+					return nil, fmt.Errorf("line %d: invalid tenant name %q", n, value)
+				}
+				k.Tenant = value
+			default:
+				return nil, fmt.Errorf("line %d: unknown credential %q", n, kind)
+			}
+		}
+
+		a.keys[k.ID] = k
+	}
+
+	if len(a.keys) == 0 {
+		return nil, fmt.Errorf("no key found")
+	}
+
+	return a, nil
+}
+
+func ReadFile(path string) (*Authenticator, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(string(b))
+}
+
+// GenerateAPIKey returns a new API key and its entry for the keys file. Only the hash of the key is in the
+// entry, the key itself is shown once.
+func GenerateAPIKey(id string, scopes []Scope) (key, entry string, err error) {
+	b := make([]byte, 32)
+	if _, err = io.ReadFull(rand.Reader, b); err != nil {
+		return
+	}
+
+	key = "tdk_" + base64.RawURLEncoding.EncodeToString(b)
+	hash := sha256.Sum256([]byte(key))
+	entry = fmt.Sprintf("%s %s sha256:%x", id, joinScopes(scopes), hash)
+	return
+}
+
+// GenerateHMACSecret returns a new HMAC secret and its entry for the keys file.
+func GenerateHMACSecret(id string, scopes []Scope) (secret []byte, entry string, err error) {
+	secret = make([]byte, 32)
+	if _, err = io.ReadFull(rand.Reader, secret); err != nil {
+		return
+	}
+
+	entry = fmt.Sprintf("%s %s hmac:%s", id, joinScopes(scopes), base64.StdEncoding.EncodeToString(secret))
+	return
+}
+
+func joinScopes(scopes []Scope) string {
+	s := make([]string, len(scopes))
+	for i := range scopes {
+		s[i] = string(scopes[i])
+	}
+
+	return strings.Join(s, ",")
+}
+
+// Authenticate returns the key of the request. The request carries either an API key in the X-API-Key
+// header, an HMAC signature in the Authorization header, see Sign, a bearer token or, without any of them,
+// a client certificate verified by the server. A signed request is accepted once, and only within MaxSkew
+// of its timestamp, and its body cannot exceed 32 MiB, a types.TooLargeError otherwise. Any other failure
+// is a types.UnauthenticatedError.
+func (a *Authenticator) Authenticate(r *http.Request) (*Key, error) {
+	a.mtx.RLock()
+	keys, hashes, subjects, jwt := a.keys, a.hashes, a.subjects, a.jwt
+	a.mtx.RUnlock()
+
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		hash := sha256.Sum256([]byte(key))
+		if k, ok := hashes[hash]; ok {
+			return k, nil
+		}
+
+		return nil, fmt.Errorf("%w: unknown API key", types.UnauthenticatedError)
+	}
+
+	scheme, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	switch {
+	case scheme == HMACScheme:
+		return a.authenticateHMAC(r, params, keys)
+	case strings.EqualFold(scheme, BearerScheme) && jwt != nil:
+		return jwt.Verify(strings.TrimSpace(params))
+	case strings.EqualFold(scheme, BearerScheme):
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", types.UnauthenticatedError)
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if k, ok := subjects[cn]; ok {
+			return k, nil
+		}
+
+		return nil, fmt.Errorf("%w: unknown client certificate %q", types.UnauthenticatedError, cn)
+	}
+
+	return nil, fmt.Errorf("%w: no credentials", types.UnauthenticatedError)
+}
+
+func (a *Authenticator) authenticateHMAC(r *http.Request, params string, keys map[string]*Key) (*Key, error) {
+	var id, ts, sig string
+	for _, p := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch name {
+		case "KeyId":
+			id = value
+		case "Timestamp":
+			ts = value
+		case "Signature":
+			sig = value
+		}
+	}
+
+	k, ok := keys[id]
+	if !ok || k.secret == nil {
+		return nil, fmt.Errorf("%w: unknown HMAC key", types.UnauthenticatedError)
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp", types.UnauthenticatedError)
+	}
+
+	now := time.Now()
+	t := time.Unix(unix, 0)
+	if t.Before(now.Add(-MaxSkew)) || t.After(now.Add(MaxSkew)) {
+		return nil, fmt.Errorf("%w: the timestamp is outside the accepted window", types.UnauthenticatedError)
+	}
+
+	var got []byte
+	if got, err = hex.DecodeString(sig); err != nil {
+		return nil, fmt.Errorf("%w: invalid signature", types.UnauthenticatedError)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		// one byte past the limit tells a body too large to sign from one exactly at the limit
+		if body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1)); err != nil {
+			return nil, fmt.Errorf("%w: reading the body: %w", types.UnauthenticatedError, err)
+		}
+		if len(body) > maxSignedBodySize {
+			return nil, fmt.Errorf("%w: a signed body cannot exceed %d bytes", types.TooLargeError, maxSignedBodySize)
+		}
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if !hmac.Equal(got, signature(k.secret, r, ts, body)) {
+		return nil, fmt.Errorf("%w: signature mismatch", types.UnauthenticatedError)
+	}
+
+	// the decoded signature, hex letters may come in either case
+	if !a.remember(k.ID+":"+hex.EncodeToString(got), t.Add(MaxSkew), now) {
+		return nil, fmt.Errorf("%w: replayed request", types.UnauthenticatedError)
+	}
+
+	return k, nil
+}
+
+// remember records a signature until it expires. It returns false if the signature was seen before. The
+// expired signatures are dropped every seenPruneInterval.
+func (a *Authenticator) remember(sig string, expires, now time.Time) bool {
+	a.seenMtx.Lock()
+	defer a.seenMtx.Unlock()
+
+	if now.Sub(a.lastPrune) >= seenPruneInterval {
+		a.lastPrune = now
+		for s, exp := range a.seen {
+			if exp.Before(now) {
+				delete(a.seen, s)
+			}
+		}
+	}
+
+	if exp, ok := a.seen[sig]; ok && !exp.Before(now) {
+		return false
+	}
+
+	a.seen[sig] = expires
+	return true
+}
+
+// Sign adds the HMAC signature of the request at time t to its Authorization header. The signature covers
+// the method, the path with the query, the timestamp and the SHA-256 hash of the body:
+//
+//	METHOD\nREQUEST-URI\nTIMESTAMP\nhex(sha256(body))
+func Sign(r *http.Request, keyID string, secret, body []byte, t time.Time) {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	r.Header.Set("Authorization", fmt.Sprintf("%s KeyId=%s, Timestamp=%s, Signature=%x",
+		HMACScheme, keyID, ts, signature(secret, r, ts, body)))
+}
+
+func signature(secret []byte, r *http.Request, ts string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n%x", r.Method, r.URL.RequestURI(), ts, bodyHash)
+	return mac.Sum(nil)
+}
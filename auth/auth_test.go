@@ -0,0 +1,168 @@
+package auth_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticator(t *testing.T) {
+	key, keyEntry, err := auth.GenerateAPIKey("reader", []auth.Scope{auth.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, secretEntry, err := auth.GenerateHMACSecret("batch", []auth.Scope{auth.ScopeWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := auth.Parse("# keys\n" + keyEntry + "\n\n" + secretEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost/v1/transactions", nil)
+	r.Header.Set(auth.APIKeyHeader, key)
+	k, err := a.Authenticate(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "reader", k.ID)
+		assert.True(t, k.Allows(auth.ScopeRead))
+		assert.False(t, k.Allows(auth.ScopeWrite))
+	}
+
+	r.Header.Set(auth.APIKeyHeader, key+"x")
+	_, err = a.Authenticate(r)
+	assert.True(t, errors.Is(err, types.UnauthenticatedError))
+
+	r.Header.Del(auth.APIKeyHeader)
+	_, err = a.Authenticate(r)
+	assert.True(t, errors.Is(err, types.UnauthenticatedError))
+
+	body := []byte(`{"description":"rent","date":"2023-09-12","amount":100}`)
+	signed := func(body []byte, ts time.Time) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "http://localhost/v1/transactions?x=1", bytes.NewReader(body))
+		auth.Sign(r, "batch", secret, body, ts)
+		return r
+	}
+
+	r = signed(body, time.Now())
+	k, err = a.Authenticate(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "batch", k.ID)
+		assert.True(t, k.Allows(auth.ScopeWrite))
+	}
+
+	// the body is still readable by the handler
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r.Body)
+	assert.Equal(t, body, buf.Bytes())
+
+	// the same signed request again is a replay
+	replay, _ := http.NewRequest(http.MethodPost, "http://localhost/v1/transactions?x=1", bytes.NewReader(body))
+	replay.Header = r.Header.Clone()
+	_, err = a.Authenticate(replay)
+	assert.ErrorContains(t, err, "replayed")
+
+	// so is the same signature in upper case
+	replay, _ = http.NewRequest(http.MethodPost, "http://localhost/v1/transactions?x=1", bytes.NewReader(body))
+	replay.Header = r.Header.Clone()
+	params := replay.Header.Get("Authorization")
+	i := strings.Index(params, "Signature=") + len("Signature=")
+	replay.Header.Set("Authorization", params[:i]+strings.ToUpper(params[i:]))
+	_, err = a.Authenticate(replay)
+	assert.ErrorContains(t, err, "replayed")
+
+	tampered := signed(body, time.Now().Add(time.Second))
+	tampered.Body = http.NoBody
+	_, err = a.Authenticate(tampered)
+	assert.ErrorContains(t, err, "signature mismatch")
+
+	_, err = a.Authenticate(signed(body, time.Now().Add(-auth.MaxSkew-time.Minute)))
+	assert.ErrorContains(t, err, "window")
+
+	// a body past the limit is rejected, not cut off before checking the signature
+	large := bytes.Repeat([]byte(" "), 32<<20+1)
+	_, err = a.Authenticate(signed(large, time.Now()))
+	assert.ErrorIs(t, err, types.TooLargeError)
+
+	// an unknown key is rejected before reading the body
+	r = signed(large, time.Now())
+	auth.Sign(r, "unknown", secret, large, time.Now())
+	_, err = a.Authenticate(r)
+	assert.ErrorIs(t, err, types.UnauthenticatedError)
+
+	other := make([]byte, 32)
+	r = signed(body, time.Now())
+	auth.Sign(r, "batch", other, body, time.Now())
+	_, err = a.Authenticate(r)
+	assert.ErrorContains(t, err, "signature mismatch")
+
+	for _, spec := range []string{
+		"",
+		"k1 read",
+		"k1 read sha256:abcd",
+		"k1 owner sha256:" + string(bytes.Repeat([]byte("ab"), 32)),
+		"k1 read hmac:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		keyEntry + "\n" + keyEntry,
+		keyEntry + " tenant:../etc",
+		keyEntry + " tenant:Team-A",
+	} {
+		_, err = auth.Parse(spec)
+		assert.Error(t, err, spec)
+	}
+
+	rootKey, rootEntry, err := auth.GenerateAPIKey("root", []auth.Scope{auth.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, err = auth.Parse(rootEntry); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ = http.NewRequest(http.MethodDelete, "http://localhost/v1/budgets/1", nil)
+	r.Header.Set(auth.APIKeyHeader, rootKey)
+	k, err = a.Authenticate(r)
+	if assert.NoError(t, err) {
+		assert.True(t, k.Allows(auth.ScopeRead))
+		assert.True(t, k.Allows(auth.ScopeWrite))
+	}
+}
+
+func TestAuthenticator_Replace(t *testing.T) {
+	oldKey, oldEntry, err := auth.GenerateAPIKey("old", []auth.Scope{auth.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, newEntry, err := auth.GenerateAPIKey("new", []auth.Scope{auth.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := auth.Parse(oldEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := auth.Parse(newEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Replace(b)
+
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost/v1/transactions", nil)
+	r.Header.Set(auth.APIKeyHeader, oldKey)
+	_, err = a.Authenticate(r)
+	assert.ErrorIs(t, err, types.UnauthenticatedError)
+
+	r.Header.Set(auth.APIKeyHeader, newKey)
+	k, err := a.Authenticate(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "new", k.ID)
+	}
+}
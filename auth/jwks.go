@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSTTL is how long keys fetched from a URL are used before they are fetched again.
+	DefaultJWKSTTL = time.Hour
+
+	// minJWKSRefresh limits the fetches triggered by tokens signed with an unknown key id.
+	minJWKSRefresh = time.Minute
+	maxJWKSSize    = 1 << 20
+)
+
+// JWK is a JSON Web Key as found in a JWKS document. Only the members of RSA, EC P-256 and symmetric keys
+// are read.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"k,omitempty"`
+}
+
+// verificationKey is a parsed JWK with the algorithm it verifies.
+type verificationKey struct {
+	alg string
+	key any
+}
+
+// KeySet holds the keys verifying JWT signatures. The keys come from a JWKS file or URL; a URL is fetched
+// again when the keys are older than TTL or a token names an unknown key.
+type KeySet struct {
+	source string
+	client *http.Client
+	ttl    time.Duration
+
+	mtx       *sync.Mutex
+	keys      map[string]verificationKey
+	fetchedAt time.Time
+	// fetching is the refresh in progress, nil when there is none
+	fetching *jwksFetch
+}
+
+// jwksFetch is a refresh of the keys shared by the callers asking for it while it runs. err is set before
+// done is closed.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// LoadKeySet reads the JWKS at source, a file path or an http(s) URL.
+func LoadKeySet(source string, ttl time.Duration) (*KeySet, error) {
+	if ttl <= 0 {
+		ttl = DefaultJWKSTTL
+	}
+
+	s := &KeySet{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+		ttl:    ttl,
+		mtx:    &sync.Mutex{},
+	}
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ParseKeySet builds a static key set from a JWKS document.
+func ParseKeySet(b []byte) (*KeySet, error) {
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySet{mtx: &sync.Mutex{}, keys: keys}, nil
+}
+
+func (s *KeySet) remote() bool {
+	return strings.HasPrefix(s.source, "http://") || strings.HasPrefix(s.source, "https://")
+}
+
+// key returns the key with the id. A remote set is fetched again when it is stale, or when the key is
+// unknown and the last fetch is older than a minute; a key unknown while a fetch runs waits for it. A failed
+// fetch keeps the previous keys.
+func (s *KeySet) key(kid string) (verificationKey, error) {
+	s.mtx.Lock()
+	k, ok := s.keys[kid]
+	var refresh bool
+	if s.remote() {
+		age := time.Since(s.fetchedAt)
+		refresh = age > s.ttl || (!ok && (age > minJWKSRefresh || s.fetching != nil))
+	}
+	s.mtx.Unlock()
+
+	if refresh && s.refresh() == nil {
+		s.mtx.Lock()
+		k, ok = s.keys[kid]
+		s.mtx.Unlock()
+	}
+
+	if !ok {
+		return k, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return k, nil
+}
+
+// refresh loads the keys again and swaps them in. The lock is not held while loading, the current keys
+// verify the tokens meanwhile; a caller asking while a refresh runs waits for it and gets its result.
+func (s *KeySet) refresh() error {
+	s.mtx.Lock()
+	if f := s.fetching; f != nil {
+		s.mtx.Unlock()
+		<-f.done
+		return f.err
+	}
+
+	f := &jwksFetch{done: make(chan struct{})}
+	s.fetching = f
+	if s.remote() {
+		// the attempt counts even when it fails, an unreachable URL is not hammered
+		s.fetchedAt = time.Now()
+	}
+	s.mtx.Unlock()
+
+	keys, err := s.load()
+
+	s.mtx.Lock()
+	if err == nil {
+		s.keys = keys
+	}
+	s.fetching = nil
+	s.mtx.Unlock()
+
+	f.err = err
+	close(f.done)
+	return err
+}
+
+func (s *KeySet) load() (map[string]verificationKey, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if s.remote() {
+		b, err = s.fetch()
+	} else {
+		b, err = os.ReadFile(s.source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading JWKS: %w", err)
+	}
+
+	return parseJWKS(b)
+}
+
+func (s *KeySet) fetch() ([]byte, error) {
+	resp, err := s.client.Get(s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS returns the signing keys of the JWKS document. The keys that cannot verify a signature, e.g. of
+// an unsupported type, are logged and skipped; it fails when no key is left.
+func parseJWKS(b []byte) (map[string]verificationKey, error) {
+	var doc struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+
+	keys := make(map[string]verificationKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		// identity providers publish keys of other types and algorithms too, the set is usable without them
+		k, err := jwk.parse()
+		if err != nil {
+			slog.Warn("skipping an unusable JWK", "kid", jwk.Kid, "kty", jwk.Kty, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = k
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("parsing JWKS: no usable signing key found")
+	}
+
+	return keys, nil
+}
+
+func (j JWK) parse() (verificationKey, error) {
+	var k verificationKey
+
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return k, err
+		}
+		var e *big.Int
+		if e, err = decodeBigInt(j.E); err != nil {
+			return k, err
+		}
+		if !e.IsInt64() || n.BitLen() < 2048 {
+			return k, fmt.Errorf("RSA keys must have a modulus of 2048 bits or more")
+		}
+		k = verificationKey{alg: "RS256", key: &rsa.PublicKey{N: n, E: int(e.Int64())}}
+	case "EC":
+		if j.Crv != "P-256" {
+			return k, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return k, err
+		}
+		var y *big.Int
+		if y, err = decodeBigInt(j.Y); err != nil {
+			return k, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return k, fmt.Errorf("the point is not on the curve")
+		}
+		k = verificationKey{alg: "ES256", key: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}}
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(j.K)
+		if err != nil {
+			return k, err
+		}
+		if len(secret) < 32 {
+			return k, fmt.Errorf("HS256 secrets must have 32 bytes or more")
+		}
+		k = verificationKey{alg: "HS256", key: secret}
+	default:
+		return k, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+
+	if j.Alg != "" && j.Alg != k.alg {
+		return k, fmt.Errorf("the algorithm %s does not match the %s key", j.Alg, j.Kty)
+	}
+
+	return k, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("missing key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
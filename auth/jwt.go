@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/types"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	BearerScheme = "Bearer"
+
+	// DefaultLeeway absorbs the clock skew between the token issuer and the server.
+	DefaultLeeway = time.Minute
+)
+
+// JWTVerifier validates bearer tokens signed with RS256, ES256 or HS256 by a key of the key set. Issuer and
+// Audience are checked when set. The scopes are read from the space separated scope claim or the scp
+// claim, and mapped through ScopeMap; the tenant is read from TenantClaim.
+type JWTVerifier struct {
+	Keys        *KeySet
+	Issuer      string
+	Audience    string
+	ScopeMap    map[string]Scope
+	TenantClaim string
+	Leeway      time.Duration
+}
+
+// NewJWTVerifier returns a verifier mapping the scopes read, write and admin to themselves and reading the
+// tenant from the tenant claim.
+func NewJWTVerifier(keys *KeySet, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		Keys:     keys,
+		Issuer:   issuer,
+		Audience: audience,
+		ScopeMap: map[string]Scope{
+			string(ScopeRead):  ScopeRead,
+			string(ScopeWrite): ScopeWrite,
+			string(ScopeAdmin): ScopeAdmin,
+		},
+		TenantClaim: "tenant",
+		Leeway:      DefaultLeeway,
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Verify validates the token and returns its subject as a key. Any failure is a types.UnauthenticatedError.
+func (v *JWTVerifier) Verify(token string) (*Key, error) {
+	k, err := v.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid bearer token: %w", types.UnauthenticatedError, err)
+	}
+
+	return k, nil
+}
+
+func (v *JWTVerifier) verify(token string) (*Key, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+
+	key, err := v.Keys.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// the algorithm comes from the key, a token cannot pick another one, e.g. HS256 with an RSA public key
+	if header.Alg != key.alg {
+		return nil, fmt.Errorf("the algorithm %q does not match the key %q", header.Alg, header.Kid)
+	}
+
+	var sig []byte
+	if sig, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	if err = verifySignature(key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+
+	if err = v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("missing sub claim")
+	}
+
+	k := &Key{ID: sub, Scopes: v.scopes(claims)}
+	if v.TenantClaim != "" {
+		k.Tenant, _ = claims[v.TenantClaim].(string)
+	}
+
+	return k, nil
+}
+
+func (v *JWTVerifier) validate(claims map[string]any) error {
+	now := time.Now()
+
+	exp, ok := numericDate(claims, "exp")
+	if !ok {
+		return fmt.Errorf("missing exp claim")
+	}
+	if now.After(exp.Add(v.Leeway)) {
+		return fmt.Errorf("token expired")
+	}
+
+	if nbf, ok := numericDate(claims, "nbf"); ok && now.Add(v.Leeway).Before(nbf) {
+		return fmt.Errorf("token not valid yet")
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+
+	if v.Audience != "" && !hasAudience(claims["aud"], v.Audience) {
+		return fmt.Errorf("the token is not meant for %q", v.Audience)
+	}
+
+	return nil
+}
+
+func (v *JWTVerifier) scopes(claims map[string]any) []Scope {
+	var names []string
+	if s, ok := claims["scope"].(string); ok {
+		names = strings.Fields(s)
+	}
+	switch s := claims["scp"].(type) {
+	case string:
+		names = append(names, strings.Fields(s)...)
+	case []any:
+		for _, n := range s {
+			if n, ok := n.(string); ok {
+				names = append(names, n)
+			}
+		}
+	}
+
+	scopes := make([]Scope, 0, len(names))
+	for _, n := range names {
+		if scope, ok := v.ScopeMap[n]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+func verifySignature(key verificationKey, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch k := key.key.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return fmt.Errorf("signature mismatch")
+		}
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return fmt.Errorf("signature mismatch")
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return fmt.Errorf("signature mismatch")
+		}
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("signature mismatch")
+		}
+	default:
+		return fmt.Errorf("unsupported key")
+	}
+
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func numericDate(claims map[string]any, name string) (time.Time, bool) {
+	f, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(f), 0), true
+}
+
+func hasAudience(aud any, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []any:
+		for _, v := range a {
+			if v == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,269 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/types"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+	jwks   []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	var (
+		k   = &testKeys{secret: make([]byte, 32)}
+		err error
+	)
+
+	if k.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if k.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rand.Read(k.secret); err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	k.jwks, err = json.Marshal(map[string]any{"keys": []auth.JWK{
+		{Kty: "RSA", Kid: "rsa", Alg: "RS256", Use: "sig", N: b64(k.rsa.N.Bytes()), E: b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: b64(k.ec.X.FillBytes(make([]byte, 32))), Y: b64(k.ec.Y.FillBytes(make([]byte, 32)))},
+		{Kty: "oct", Kid: "hs", K: b64(k.secret)},
+		{Kty: "RSA", Kid: "enc", Use: "enc"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var (
+		sig []byte
+		err error
+	)
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case "HS256":
+		// an HS256 token signed with the public RSA modulus checks the algorithm confusion
+		secret := k.secret
+		if kid == "rsa" {
+			secret = k.rsa.N.Bytes()
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + b64(sig)
+}
+
+func TestJWTVerifier(t *testing.T) {
+	keys := newTestKeys(t)
+	set, err := auth.ParseKeySet(keys.jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := auth.NewJWTVerifier(set, "https://issuer.example", "transactiondemo")
+	claims := func(changes map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":    "alice",
+			"iss":    "https://issuer.example",
+			"aud":    []string{"other", "transactiondemo"},
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"scope":  "read write unknown",
+			"tenant": "team-a",
+		}
+		for name, value := range changes {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+
+	for _, tc := range []struct{ alg, kid string }{{"RS256", "rsa"}, {"ES256", "ec"}, {"HS256", "hs"}} {
+		k, err := v.Verify(keys.sign(t, tc.alg, tc.kid, claims(nil)))
+		if assert.NoError(t, err, tc.alg) {
+			assert.Equal(t, "alice", k.ID)
+			assert.Equal(t, "team-a", k.Tenant)
+			assert.Equal(t, []auth.Scope{auth.ScopeRead, auth.ScopeWrite}, k.Scopes)
+		}
+	}
+
+	k, err := v.Verify(keys.sign(t, "ES256", "ec", claims(map[string]any{"scope": nil, "scp": []string{"admin"}})))
+	if assert.NoError(t, err) {
+		assert.True(t, k.Allows(auth.ScopeWrite))
+	}
+
+	for name, token := range map[string]string{
+		"expired":         keys.sign(t, "RS256", "rsa", claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"no exp":          keys.sign(t, "RS256", "rsa", claims(map[string]any{"exp": nil})),
+		"not yet valid":   keys.sign(t, "RS256", "rsa", claims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"issuer":          keys.sign(t, "RS256", "rsa", claims(map[string]any{"iss": "https://evil.example"})),
+		"audience":        keys.sign(t, "RS256", "rsa", claims(map[string]any{"aud": "other"})),
+		"no subject":      keys.sign(t, "RS256", "rsa", claims(map[string]any{"sub": nil})),
+		"unknown key":     keys.sign(t, "RS256", "other", claims(nil)),
+		"encryption key":  keys.sign(t, "RS256", "enc", claims(nil)),
+		"alg confusion":   keys.sign(t, "HS256", "rsa", claims(nil)),
+		"alg none":        keys.sign(t, "none", "rsa", claims(nil)),
+		"wrong signature": keys.sign(t, "RS256", "rsa", claims(nil))[:20] + keys.sign(t, "ES256", "ec", claims(nil))[20:],
+		"malformed":       "not.a-token",
+	} {
+		_, err = v.Verify(token)
+		assert.True(t, errors.Is(err, types.UnauthenticatedError), name)
+	}
+
+	a := auth.New()
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost/v1/transactions", nil)
+	r.Header.Set("Authorization", "Bearer "+keys.sign(t, "ES256", "ec", claims(nil)))
+	_, err = a.Authenticate(r)
+	assert.ErrorContains(t, err, "not accepted")
+
+	a.SetJWTVerifier(v)
+	k, err = a.Authenticate(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice", k.ID)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(keys.jwks)
+	}))
+	defer ts.Close()
+
+	set, err := auth.LoadKeySet(ts.URL, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := auth.NewJWTVerifier(set, "", "")
+	for i := 0; i < 3; i++ {
+		_, err = v.Verify(keys.sign(t, "RS256", "rsa", map[string]any{"sub": "bob", "exp": time.Now().Add(time.Minute).Unix()}))
+		assert.NoError(t, err)
+	}
+
+	// the keys are cached, and an unknown key id right after a fetch does not fetch again
+	_, err = v.Verify(keys.sign(t, "RS256", "other", map[string]any{"sub": "bob", "exp": time.Now().Add(time.Minute).Unix()}))
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	_, err = auth.LoadKeySet(missing.URL, time.Hour)
+	assert.Error(t, err)
+
+	_, err = auth.ParseKeySet([]byte(`{"keys":[{"kty":"oct","kid":"short","k":"c2hvcnQ"}]}`))
+	assert.Error(t, err)
+
+	// the unusable keys of a mixed set are skipped
+	mixed := strings.Replace(string(keys.jwks), `"keys":[`,
+		`"keys":[{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"AA"},{"kty":"oct","kid":"short","k":"c2hvcnQ"},`, 1)
+	set, err = auth.ParseKeySet([]byte(mixed))
+	if assert.NoError(t, err) {
+		_, err = auth.NewJWTVerifier(set, "", "").Verify(keys.sign(t, "ES256", "ec",
+			map[string]any{"sub": "bob", "exp": time.Now().Add(time.Minute).Unix()}))
+		assert.NoError(t, err)
+	}
+}
+
+func TestLoadKeySet_Refresh(t *testing.T) {
+	keys := newTestKeys(t)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		_, _ = w.Write(keys.jwks)
+	}))
+	defer ts.Close()
+
+	set, err := auth.LoadKeySet(ts.URL, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := auth.NewJWTVerifier(set, "", "")
+	token := func(kid string) string {
+		return keys.sign(t, "RS256", kid, map[string]any{"sub": "bob", "exp": time.Now().Add(time.Minute).Unix()})
+	}
+
+	// the stale keys are fetched again, the fetch hangs
+	time.Sleep(60 * time.Millisecond)
+	results := make(chan error, 2)
+	go func() {
+		_, err := v.Verify(token("rsa"))
+		results <- err
+	}()
+	assert.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+
+	// the current keys verify the tokens meanwhile, and an unknown key id does not start another fetch
+	done := make(chan error)
+	go func() {
+		_, err := v.Verify(token("rsa"))
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the keys are locked during the fetch")
+	}
+	go func() {
+		_, err := v.Verify(token("other"))
+		results <- err
+	}()
+
+	close(release)
+	assert.NoError(t, <-results)
+	assert.Error(t, <-results)
+	assert.Equal(t, int32(2), fetches.Load())
+}
@@ -0,0 +1,384 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"github.com/cespare/xxhash"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Thresholds are the fractions of a budget that trigger an alert when spending crosses them.
+var Thresholds = []float64{0.8, 1.0}
+
+type Config interface {
+	Repo() types.RepoI
+	Transaction() types.TxI
+	Notifier() types.Notifier
+}
+
+type Module struct {
+	config  Config
+	table   map[string]record.Budget
+	fired   map[string]bool
+	tallies map[string]*tally
+	mtx     *sync.Mutex
+	wg      sync.WaitGroup
+}
+
+func New(config Config) *Module {
+	return &Module{
+		config:  config,
+		table:   make(map[string]record.Budget),
+		fired:   make(map[string]bool),
+		tallies: make(map[string]*tally),
+		mtx:     &sync.Mutex{},
+	}
+}
+
+func (m *Module) Load(ctx context.Context) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	budgets, err := m.config.Repo().ReadBudgets(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range budgets {
+		m.table[b.ID] = b
+	}
+
+	return nil
+}
+
+// Add stores a budget. There is a single budget for each combination of category, tag and currency, adding
+// it again replaces the amount.
+func (m *Module) Add(ctx context.Context, b record.Budget) (record.Budget, error) {
+	var verr types.ValidationError
+	if b.Category == "" && b.Tag == "" {
+		verr.Add("category", "or tag is required")
+	}
+
+	if b.Amount <= 0 {
+		verr.Add("amount", "must be positive")
+	}
+
+	if err := verr.Err(); err != nil {
+		return b, err
+	}
+
+	if b.Currency == "" {
+		b.Currency = types.DefaultCurrency
+	}
+
+	b.ID = fmt.Sprintf("%x", xxhash.Sum64String(fmt.Sprintf("%s\x00%s\x00%s", b.Category, b.Tag, b.Currency)))
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	old, existed := m.table[b.ID]
+	m.table[b.ID] = b
+	if err := m.persist(ctx); err != nil {
+		if existed {
+			m.table[b.ID] = old
+		} else {
+			delete(m.table, b.ID)
+		}
+		return b, types.AsServerError(err)
+	}
+
+	return b, nil
+}
+
+func (m *Module) List() []record.Budget {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.sorted()
+}
+
+func (m *Module) Delete(ctx context.Context, id string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	b, ok := m.table[id]
+	if !ok {
+		return types.RecordNotFound
+	}
+
+	delete(m.table, id)
+	if err := m.persist(ctx); err != nil {
+		m.table[id] = b
+		return types.AsServerError(err)
+	}
+
+	for key := range m.tallies {
+		if strings.HasPrefix(key, id+"/") {
+			delete(m.tallies, key)
+		}
+	}
+
+	return nil
+}
+
+// Status reports the spending of the budget in the month containing the given time.
+func (m *Module) Status(ctx context.Context, id string, month time.Time) (record.BudgetStatus, error) {
+	m.mtx.Lock()
+	b, ok := m.table[id]
+	m.mtx.Unlock()
+
+	if !ok {
+		return record.BudgetStatus{}, types.RecordNotFound
+	}
+
+	return m.status(ctx, b, month)
+}
+
+func (m *Module) Statuses(ctx context.Context, month time.Time) ([]record.BudgetStatus, error) {
+	m.mtx.Lock()
+	budgets := m.sorted()
+	m.mtx.Unlock()
+
+	statuses := make([]record.BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		s, err := m.status(ctx, b, month)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+
+	return statuses, nil
+}
+
+// Evaluate is meant to be registered as a TxModule add hook. It counts the new transaction toward the running
+// total of each budget it matches in its month and notifies every threshold the total crosses. The
+// evaluation runs in the background, so that it does not hold up the add; Close waits for it.
+func (m *Module) Evaluate(ctx context.Context, rec record.TransactionRecord) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.evaluate(ctx, rec)
+	}()
+}
+
+// Close waits for the evaluations in progress, or until ctx is done.
+func (m *Module) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return types.Canceled(ctx, "evaluating budgets")
+	}
+}
+
+func (m *Module) evaluate(ctx context.Context, rec record.TransactionRecord) {
+	m.mtx.Lock()
+	budgets := m.sorted()
+	m.mtx.Unlock()
+
+	for _, b := range budgets {
+		if !matchesAny(b, items(rec)) {
+			continue
+		}
+
+		before, s, err := m.count(ctx, b, rec)
+		if err != nil {
+			logging.FromContext(ctx).Error("evaluating budget", "budget", b.ID, "error", err)
+			continue
+		}
+
+		for _, threshold := range Thresholds {
+			if before/b.Amount >= threshold || s.Ratio < threshold || !m.markFired(b.ID, s.Month, threshold) {
+				continue
+			}
+			alert := record.BudgetAlert{
+				BudgetStatus: s,
+				Threshold:    threshold,
+				Time:         time.Now(),
+			}
+			if err = m.config.Notifier().Notify(ctx, alert); err != nil {
+				logging.FromContext(ctx).Error("notifying budget alert", "budget", b.ID, "error", err)
+			}
+		}
+	}
+}
+
+// tally is the running total of a budget in a month. It is built from the stored transactions when the
+// month is first needed, counted holds the transactions in it so that none is counted twice.
+type tally struct {
+	mtx     sync.Mutex
+	spent   float64
+	counted map[string]bool
+}
+
+// count adds the transaction to the running total of the budget in its month. It returns the total without
+// the transaction and the status with it. The evaluations of the same budget and month are serialized by
+// the lock of the tally, so that each sees the transactions counted by the ones before.
+func (m *Module) count(ctx context.Context, b record.Budget, rec record.TransactionRecord) (before float64, s record.BudgetStatus, err error) {
+	month := rec.Date.Date()
+	key := b.ID + "/" + month.Format(record.BudgetMonthFormat)
+
+	m.mtx.Lock()
+	t, ok := m.tallies[key]
+	if !ok {
+		t = &tally{}
+		m.tallies[key] = t
+	}
+	m.mtx.Unlock()
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.counted == nil {
+		var (
+			spent   float64
+			counted map[string]bool
+		)
+		if spent, counted, err = m.monthSpent(ctx, b, month); err != nil {
+			return
+		}
+		t.spent, t.counted = spent, counted
+	}
+
+	var spent float64
+	if spent, err = m.spent(ctx, b, rec); err != nil {
+		return
+	}
+
+	if t.counted[rec.ID] {
+		before = t.spent - spent
+	} else {
+		before = t.spent
+		t.spent += spent
+		t.counted[rec.ID] = true
+	}
+
+	return before, newStatus(b, month, t.spent), nil
+}
+
+// markFired records that an alert was sent and reports whether it had not been sent before.
+func (m *Module) markFired(id, month string, threshold float64) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	key := fmt.Sprintf("%s/%s/%f", id, month, threshold)
+	if m.fired[key] {
+		return false
+	}
+	m.fired[key] = true
+	return true
+}
+
+func (m *Module) status(ctx context.Context, b record.Budget, month time.Time) (record.BudgetStatus, error) {
+	spent, _, err := m.monthSpent(ctx, b, month)
+	if err != nil {
+		return record.BudgetStatus{}, err
+	}
+
+	return newStatus(b, month, spent), nil
+}
+
+func newStatus(b record.Budget, month time.Time, spent float64) (s record.BudgetStatus) {
+	s.Budget = b
+	s.Month = month.Format(record.BudgetMonthFormat)
+	s.Spent = math.Round(spent*100) / 100
+	s.Ratio = s.Spent / b.Amount
+	return
+}
+
+// monthSpent adds up the spending of the budget in the month containing the given time, and returns the
+// transactions it counted.
+func (m *Module) monthSpent(ctx context.Context, b record.Budget, month time.Time) (spent float64, counted map[string]bool, err error) {
+	var (
+		recs []record.TransactionRecord
+		s    float64
+	)
+	if recs, err = m.config.Transaction().List(ctx); err != nil {
+		return
+	}
+
+	counted = make(map[string]bool)
+	key := month.Format(record.BudgetMonthFormat)
+	for _, rec := range recs {
+		if rec.Date.Date().Format(record.BudgetMonthFormat) != key || !matchesAny(b, items(rec)) {
+			continue
+		}
+		if s, err = m.spent(ctx, b, rec); err != nil {
+			return
+		}
+		spent += s
+		counted[rec.ID] = true
+	}
+
+	return
+}
+
+// spent returns the part of the transaction counting toward the budget, in the currency of the budget.
+func (m *Module) spent(ctx context.Context, b record.Budget, rec record.TransactionRecord) (spent float64, err error) {
+	var outRec record.ConvertedTransaction
+	if outRec, err = m.config.Transaction().Get(ctx, rec.ID, b.Currency); err != nil {
+		return
+	}
+
+	lines := outRec.Lines
+	if len(lines) == 0 {
+		lines = []record.ConvertedLineItem{{LineItem: items(outRec.TransactionRecord)[0], Converted: outRec.Converted}}
+	}
+	for _, line := range lines {
+		if b.Matches(line.LineItem) {
+			spent += line.Converted
+		}
+	}
+
+	return
+}
+
+// items returns the line items of the transaction. An unsplit transaction counts as a single line item of its
+// whole amount, whose description is also its category.
+func items(rec record.TransactionRecord) []record.LineItem {
+	if len(rec.Lines) > 0 {
+		return rec.Lines
+	}
+
+	return []record.LineItem{{Description: rec.Description, Category: rec.Description, Amount: rec.Amount}}
+}
+
+func matchesAny(b record.Budget, lines []record.LineItem) bool {
+	for _, line := range lines {
+		if b.Matches(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Module) sorted() []record.Budget {
+	budgets := make([]record.Budget, 0, len(m.table))
+	for _, b := range m.table {
+		budgets = append(budgets, b)
+	}
+
+	sort.Slice(budgets, func(i, j int) bool {
+		return budgets[i].ID < budgets[j].ID
+	})
+
+	return budgets
+}
+
+func (m *Module) persist(ctx context.Context) error {
+	return m.config.Repo().WriteBudgets(ctx, m.sorted())
+}
@@ -0,0 +1,189 @@
+package budget_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/budget"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	tx "github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	alerts []record.BudgetAlert
+	mtx    sync.Mutex
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, alert record.BudgetAlert) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func newBudgets(t *testing.T) (*tx.TxModule, *budget.Module, *recordingNotifier) {
+	notifier := &recordingNotifier{}
+	app := &transactiondemo.App{
+		AppSkipFile: true,
+		AppNotifier: notifier,
+	}
+	app.AppRepo = repoModule.New(app)
+	transaction := tx.New(app)
+	app.AppTransaction = transaction
+	budgets := budget.New(app)
+	transaction.OnAdd(budgets.Evaluate)
+	t.Cleanup(func() {
+		_ = budgets.Close(context.Background())
+	})
+
+	return transaction, budgets, notifier
+}
+
+func TestModule_Evaluate(t *testing.T) {
+	transaction, budgets, notifier := newBudgets(t)
+
+	b, err := budgets.Add(context.Background(), record.Budget{Category: "travel", Amount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, types.DefaultCurrency, b.Currency)
+
+	// the budgets are evaluated in the background
+	date := "2023-09-12"
+	add := func(description, amount string, lines ...record.LineItem) {
+		assert.NoError(t, transaction.Add(context.Background(), description, date, amount, lines...))
+		assert.NoError(t, budgets.Close(context.Background()))
+	}
+
+	add("taxi", "50", record.LineItem{Category: "travel", Amount: 50})
+	assert.Empty(t, notifier.alerts)
+
+	add("hotel and dinner", "70",
+		record.LineItem{Category: "travel", Amount: 35},
+		record.LineItem{Category: "meals", Amount: 35})
+	if assert.Len(t, notifier.alerts, 1) {
+		assert.Equal(t, 0.8, notifier.alerts[0].Threshold)
+		assert.Equal(t, 85.0, notifier.alerts[0].Spent)
+	}
+
+	add("train", "20", record.LineItem{Category: "travel", Amount: 20})
+	add("bus", "5", record.LineItem{Category: "travel", Amount: 5})
+	if assert.Len(t, notifier.alerts, 2) {
+		assert.Equal(t, 1.0, notifier.alerts[1].Threshold)
+	}
+
+	month, _ := time.Parse(record.BudgetMonthFormat, "2023-09")
+	status, err := budgets.Status(context.Background(), b.ID, month)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 110.0, status.Spent)
+		assert.Equal(t, "2023-09", status.Month)
+	}
+
+	_, err = budgets.Add(context.Background(), record.Budget{Amount: 100})
+	assert.ErrorIs(t, err, types.InvalidInputError)
+}
+
+func TestModule_EvaluateConcurrent(t *testing.T) {
+	transaction, budgets, notifier := newBudgets(t)
+
+	if _, err := budgets.Add(context.Background(), record.Budget{Category: "travel", Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	// together, and only together, the transactions cross both thresholds
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, transaction.Add(context.Background(), fmt.Sprintf("taxi %d", i), "2023-09-12", "10",
+				record.LineItem{Category: "travel", Amount: 10}))
+		}(i)
+	}
+	wg.Wait()
+	assert.NoError(t, budgets.Close(context.Background()))
+
+	if assert.Len(t, notifier.alerts, 2) {
+		assert.ElementsMatch(t, []float64{0.8, 1.0}, []float64{notifier.alerts[0].Threshold, notifier.alerts[1].Threshold})
+	}
+}
+
+func TestModule_EvaluateUnsplit(t *testing.T) {
+	transaction, budgets, notifier := newBudgets(t)
+
+	b, err := budgets.Add(context.Background(), record.Budget{Category: "rent", Amount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an unsplit transaction counts under its description
+	assert.NoError(t, transaction.Add(context.Background(), "rent", "2023-09-01", "900"))
+	assert.NoError(t, transaction.Add(context.Background(), "groceries", "2023-09-02", "900"))
+	assert.NoError(t, budgets.Close(context.Background()))
+
+	if assert.Len(t, notifier.alerts, 1) {
+		assert.Equal(t, 0.8, notifier.alerts[0].Threshold)
+	}
+
+	month, _ := time.Parse(record.BudgetMonthFormat, "2023-09")
+	status, err := budgets.Status(context.Background(), b.ID, month)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 900.0, status.Spent)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	n := &budget.FileNotifier{Path: filepath.Join(dir, "alerts.json")}
+	assert.NoError(t, n.Notify(context.Background(), record.BudgetAlert{Threshold: 0.8}))
+	assert.NoError(t, n.Notify(context.Background(), record.BudgetAlert{Threshold: 1}))
+
+	b, err := os.ReadFile(n.Path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, strings.Count(string(b), "\n"))
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan record.BudgetAlert, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var alert record.BudgetAlert
+		_ = json.NewDecoder(r.Body).Decode(&alert)
+		received <- alert
+	}))
+	defer ts.Close()
+
+	// Notify does not wait for a slow endpoint
+	n := &budget.WebhookNotifier{URL: ts.URL}
+	assert.NoError(t, n.Notify(context.Background(), record.BudgetAlert{Threshold: 0.8}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, n.Close(ctx), types.CanceledError)
+
+	close(release)
+	assert.NoError(t, n.Close(context.Background()))
+	assert.Equal(t, 0.8, (<-received).Threshold)
+}
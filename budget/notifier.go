@@ -0,0 +1,139 @@
+package budget
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogNotifier logs each alert as a warning, to the logger of the context unless Logger is set.
+type LogNotifier struct {
+	Logger *slog.Logger
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, alert record.BudgetAlert) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = logging.FromContext(ctx)
+	}
+
+	logger.Warn("budget threshold reached", "budget", alert.ID, "category", alert.Category, "tag", alert.Tag,
+		"threshold", alert.Threshold, "month", alert.Month, "spent", alert.Spent, "amount", alert.Amount,
+		"currency", alert.Currency)
+	return nil
+}
+
+// DefaultWebhookTimeout is the timeout of a post of the WebhookNotifier without its own Client.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// WebhookNotifier posts each alert as JSON to the URL. The post happens in the background, so that a slow
+// endpoint does not hold up the transaction crossing the threshold: Notify only fails when the alert cannot
+// be encoded, a failed post is logged. Close waits for the posts in progress.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+	wg     sync.WaitGroup
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert record.BudgetAlert) error {
+	b, err := json.Marshal(&alert)
+	if err != nil {
+		return err
+	}
+
+	// the post outlives the request adding the transaction, it keeps the logger and the span of its context
+	ctx = context.WithoutCancel(ctx)
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		if err := n.post(ctx, b); err != nil {
+			logging.FromContext(ctx).Error("notifying budget alert", "budget", alert.ID, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, b []byte) error {
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var (
+		logger = logging.FromContext(ctx).With("upstream", "budget_webhook")
+		begin  = time.Now()
+		resp   *http.Response
+	)
+	if resp, err = client.Do(req); err != nil {
+		logger.Warn("upstream call failed", "duration", time.Since(begin), "error", err)
+		return err
+	}
+	_ = resp.Body.Close()
+	logger.Info("upstream call", "status", resp.StatusCode, "duration", time.Since(begin))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP Status not OK: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Close waits for the posts in progress, until ctx is done.
+func (n *WebhookNotifier) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return types.Canceled(ctx, "posting budget alerts")
+	}
+}
+
+// FileNotifier appends each alert as a JSON line to the file at Path.
+type FileNotifier struct {
+	Path string
+	mtx  sync.Mutex
+}
+
+func (n *FileNotifier) Notify(_ context.Context, alert record.BudgetAlert) error {
+	b, err := json.Marshal(&alert)
+	if err != nil {
+		return err
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	var f *os.File
+	if f, err = os.OpenFile(n.Path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644); err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	_, err = fmt.Fprintln(f, string(b))
+	return err
+}
@@ -0,0 +1,223 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/logging"
+	"os"
+	"sync"
+	"time"
+)
+
+// CheckInterval is how often the files are checked for a change, at most once per interval during the
+// handshakes.
+const CheckInterval = time.Second
+
+// The client certificate verification modes.
+const (
+	ClientAuthOptional = "optional"
+	ClientAuthRequired = "required"
+)
+
+// Files are the PEM files of the server certificate and its key and, for mutual TLS, of the CAs verifying
+// the client certificates.
+type Files struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Store holds the server certificate and the client CAs read from its files. They are read again when a
+// file changes on disk; a change that cannot be read, e.g. a certificate written before its key, is
+// logged and the former certificate kept until the files are consistent.
+type Store struct {
+	files Files
+
+	mtx       *sync.Mutex
+	now       func() time.Time
+	checked   time.Time
+	stamps    []stamp
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// stamp identifies a version of a file. A file that cannot be stat'ed has the stamp with missing set, so
+// that it counts as changed only once it can be.
+type stamp struct {
+	modTime time.Time
+	size    int64
+	missing bool
+}
+
+func stampOf(path string) (stamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return stamp{missing: true}, err
+	}
+
+	return stamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// NewStore reads the files, failing if any of them cannot be read.
+func NewStore(files Files) (*Store, error) {
+	s := &Store{
+		files: files,
+		mtx:   &sync.Mutex{},
+		now:   time.Now,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.checked = s.now()
+	return s, nil
+}
+
+// SetClock replaces the clock, for tests.
+func (s *Store) SetClock(now func() time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.now = now
+}
+
+// Certificate returns the server certificate and the client CAs, nil without a client CA file, reading
+// them again if a file changed.
+func (s *Store) Certificate(ctx context.Context) (*tls.Certificate, *x509.CertPool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if now := s.now(); now.Sub(s.checked) >= CheckInterval {
+		s.checked = now
+		if s.changed() {
+			if err := s.load(); err != nil {
+				logging.FromContext(ctx).Error("reloading the TLS certificate", "error", err)
+			} else {
+				logging.FromContext(ctx).Info("TLS certificate reloaded", "cert_file", s.files.CertFile)
+			}
+		}
+	}
+
+	return s.cert, s.clientCAs
+}
+
+// ServerConfig returns the TLS configuration of a server presenting the certificate of the store. The
+// version is the minimum TLS version, see ParseVersion, and clientAuth the verification of the client
+// certificates, see ParseClientAuth.
+func (s *Store) ServerConfig(version uint16, clientAuth tls.ClientAuthType) *tls.Config {
+	base := &tls.Config{
+		MinVersion: version,
+		ClientAuth: clientAuth,
+	}
+
+	return &tls.Config{
+		MinVersion: version,
+		ClientAuth: clientAuth,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := s.Certificate(hello.Context())
+
+			c := base.Clone()
+			c.Certificates = []tls.Certificate{*cert}
+			c.ClientCAs = pool
+			return c, nil
+		},
+	}
+}
+
+func (s *Store) paths() []string {
+	paths := []string{s.files.CertFile, s.files.KeyFile}
+	if s.files.ClientCAFile != "" {
+		paths = append(paths, s.files.ClientCAFile)
+	}
+
+	return paths
+}
+
+// changed reports whether a file changed since it was read, including a file that can be stat'ed again or
+// no longer.
+func (s *Store) changed() bool {
+	for i, path := range s.paths() {
+		if st, _ := stampOf(path); st != s.stamps[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// load reads the files. The stamps are taken before reading, so that a file written meanwhile is read
+// again at the next check, and kept when the files cannot be read, a failed stat included, so that the
+// failure is reported once until a file changes.
+func (s *Store) load() error {
+	var (
+		paths  = s.paths()
+		stamps = make([]stamp, len(paths))
+		errs   []error
+	)
+	for i, path := range paths {
+		var err error
+		if stamps[i], err = stampOf(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.stamps = stamps
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.files.CertFile, s.files.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading the certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if s.files.ClientCAFile != "" {
+		var b []byte
+		if b, err = os.ReadFile(s.files.ClientCAFile); err != nil {
+			return err
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("%s: no PEM encoded certificate found", s.files.ClientCAFile)
+		}
+	}
+
+	s.cert, s.clientCAs = &cert, pool
+	return nil
+}
+
+// ParseVersion returns the TLS version, "1.2" or "1.3". An empty version is TLS 1.2.
+func ParseVersion(v string) (uint16, error) {
+	switch v {
+	case "1.2", "":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, fmt.Errorf("unsupported TLS version %q, expected 1.2 or 1.3", v)
+}
+
+// ParseClientAuth returns the verification of the client certificates. With client CAs, the certificates
+// are required unless mode is ClientAuthOptional, in which case a certificate is only verified when the
+// client presents one. Without client CAs, the mode must be empty.
+func ParseClientAuth(mode string, clientCAs bool) (tls.ClientAuthType, error) {
+	switch {
+	case !clientCAs && mode != "":
+		return tls.NoClientCert, errors.New("verifying the client certificates requires client CAs")
+	case !clientCAs:
+		return tls.NoClientCert, nil
+	case mode == ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case mode == ClientAuthRequired, mode == "":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+
+	return tls.NoClientCert, fmt.Errorf("unknown client authentication %q, expected %s or %s", mode,
+		ClientAuthOptional, ClientAuthRequired)
+}
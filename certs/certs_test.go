@@ -0,0 +1,233 @@
+package certs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/certs"
+	"github.com/suyono3484/transactiondemo/logging"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issued is a generated certificate, self-signed when it has no parent.
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func issue(t *testing.T, cn string, serial int64, parent *issued) *issued {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &issued{cert: cert, key: key, der: der}
+}
+
+func (i *issued) write(t *testing.T, certFile, keyFile string) {
+	b, err := x509.MarshalECPrivateKey(i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile != "" {
+		if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (i *issued) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{i.der}, PrivateKey: i.key}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	files := certs.Files{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	ca := issue(t, "test CA", 1, nil)
+	ca.write(t, files.ClientCAFile, "")
+	issue(t, "server", 2, ca).write(t, files.CertFile, files.KeyFile)
+
+	store, err := certs.NewStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	advance := func() {
+		now = now.Add(certs.CheckInterval)
+		at := now
+		store.SetClock(func() time.Time {
+			return at
+		})
+	}
+
+	a, err := auth.Parse("ops admin cert:ops-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, err := a.Authenticate(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(k.ID))
+	}))
+	srv.TLS = store.ServerConfig(tls.VersionTLS13, tls.VerifyClientCertIfGiven)
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(cert *issued, version uint16) (*http.Response, error) {
+		c := &tls.Config{RootCAs: roots, MaxVersion: version}
+		if cert != nil {
+			c.Certificates = []tls.Certificate{cert.tlsCertificate()}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: c}}
+		return client.Get(srv.URL)
+	}
+
+	// the client certificate is mapped to the key bound to its common name
+	resp, err := get(issue(t, "ops-client", 3, ca), 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, big.NewInt(2), resp.TLS.PeerCertificates[0].SerialNumber)
+		_ = resp.Body.Close()
+	}
+
+	resp, err = get(issue(t, "stranger", 4, ca), 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	// a certificate not issued by the client CAs is not presented
+	resp, err = get(issue(t, "ops-client", 5, nil), 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	_, err = get(nil, tls.VersionTLS12)
+	assert.Error(t, err, "TLS 1.2 is below the minimum version")
+
+	// a renewed certificate is served once the files are checked again
+	issue(t, "server", 6, ca).write(t, files.CertFile, files.KeyFile)
+	advance()
+	resp, err = get(nil, 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, big.NewInt(6), resp.TLS.PeerCertificates[0].SerialNumber)
+		_ = resp.Body.Close()
+	}
+
+	// a certificate not matching its key is ignored
+	issue(t, "server", 7, ca).write(t, files.CertFile, "")
+	advance()
+	resp, err = get(nil, 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, big.NewInt(6), resp.TLS.PeerCertificates[0].SerialNumber)
+		_ = resp.Body.Close()
+	}
+
+	// a missing file is reported once, until it is back
+	logs := &bytes.Buffer{}
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewTextHandler(logs, nil)))
+	if err = os.Remove(files.KeyFile); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		advance()
+		cert, _ := store.Certificate(ctx)
+		served, err := x509.ParseCertificate(cert.Certificate[0])
+		if assert.NoError(t, err) {
+			assert.Equal(t, big.NewInt(6), served.SerialNumber)
+		}
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "reloading the TLS certificate"))
+
+	issue(t, "server", 8, ca).write(t, files.CertFile, files.KeyFile)
+	advance()
+	resp, err = get(nil, 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, big.NewInt(8), resp.TLS.PeerCertificates[0].SerialNumber)
+		_ = resp.Body.Close()
+	}
+}
+
+func TestParseClientAuth(t *testing.T) {
+	mode, err := certs.ParseClientAuth("", true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls.RequireAndVerifyClientCert, mode)
+	}
+
+	mode, err = certs.ParseClientAuth(certs.ClientAuthOptional, true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls.VerifyClientCertIfGiven, mode)
+	}
+
+	_, err = certs.ParseClientAuth(certs.ClientAuthRequired, false)
+	assert.Error(t, err)
+
+	_, err = certs.ParseVersion("1.1")
+	assert.Error(t, err)
+}
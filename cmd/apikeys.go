@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/config"
+	"os"
+	"strings"
+)
+
+// loadAuthenticator reads the keys file and the JWKS, a file or a URL, of the configuration. Without
+// either, the API is open to anyone.
+func loadAuthenticator(c config.Auth) (*auth.Authenticator, error) {
+	var (
+		a    *auth.Authenticator
+		err  error
+		path = c.APIKeysFile
+		jwks = c.JWKS
+	)
+
+	switch {
+	case path != "":
+		if a, err = auth.ReadFile(path); err != nil {
+			return nil, err
+		}
+	case jwks != "":
+		a = auth.New()
+	default:
+		return nil, nil
+	}
+
+	if jwks != "" {
+		var keys *auth.KeySet
+		if keys, err = auth.LoadKeySet(jwks, auth.DefaultJWKSTTL); err != nil {
+			return nil, err
+		}
+		a.SetJWTVerifier(auth.NewJWTVerifier(keys, c.JWTIssuer, c.JWTAudience))
+	}
+
+	return a, nil
+}
+
+// apikeyCommand prints a new API key, or HMAC secret, and its entry for the keys file.
+func apikeyCommand(args []string) int {
+	fs := flag.NewFlagSet("apikey", flag.ExitOnError)
+	id := fs.String("id", "", "key id")
+	scopes := fs.String("scopes", "read", "comma separated scopes: read, write, admin")
+	signing := fs.Bool("hmac", false, "generate an HMAC secret to sign requests instead of an API key")
+	_ = fs.Parse(args)
+
+	if *id == "" {
+		fmt.Fprintln(os.Stderr, "apikey: -id is required")
+		return 2
+	}
+
+	var list []auth.Scope
+	for _, s := range strings.Split(*scopes, ",") {
+		list = append(list, auth.Scope(strings.TrimSpace(s)))
+	}
+
+	var (
+		secret, entry string
+		err           error
+	)
+	if *signing {
+		var b []byte
+		b, entry, err = auth.GenerateHMACSecret(*id, list)
+		secret = base64.StdEncoding.EncodeToString(b)
+	} else {
+		secret, entry, err = auth.GenerateAPIKey(*id, list)
+	}
+	if err == nil {
+		// the entry is parsed back to reject unknown scopes before anything is printed
+		_, err = auth.Parse(entry)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "apikey:", err)
+		return 1
+	}
+
+	fmt.Printf("key file entry: %s\n", entry)
+	fmt.Printf("secret (shown once): %s\n", secret)
+	return 0
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/export"
+	"github.com/suyono3484/transactiondemo/importer"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"io"
+	"os"
+)
+
+// backend runs the client commands, on the transaction file or through the API of a running server.
+type backend interface {
+	add(ctx context.Context, description, date, amount string, lines []record.LineItem) (rec record.TransactionRecord, existed bool, err error)
+	get(ctx context.Context, id, currency string) (record.ConvertedTransaction, error)
+	list(ctx context.Context, filter record.Filter) ([]record.TransactionRecord, error)
+	export(ctx context.Context, w io.Writer, opts record.ExportOptions) error
+	importStatement(ctx context.Context, r io.Reader, opts record.ImportOptions) (record.ImportReport, error)
+	close() error
+}
+
+// localBackend works on the transaction file loaded by openApp.
+type localBackend struct {
+	app     *transactiondemo.App
+	release func() error
+}
+
+func (l *localBackend) add(ctx context.Context, description, date, amount string, lines []record.LineItem) (record.TransactionRecord, bool, error) {
+	rec, created, err := l.app.Transaction().Create(ctx, description, date, amount, lines...)
+	return rec, err == nil && !created, err
+}
+
+func (l *localBackend) get(ctx context.Context, id, currency string) (record.ConvertedTransaction, error) {
+	return l.app.Transaction().Get(ctx, id, currency)
+}
+
+func (l *localBackend) list(ctx context.Context, filter record.Filter) ([]record.TransactionRecord, error) {
+	recs := make([]record.TransactionRecord, 0)
+	err := l.app.Transaction().Range(ctx, filter, func(rec record.TransactionRecord) error {
+		recs = append(recs, rec)
+		return nil
+	})
+
+	return recs, err
+}
+
+func (l *localBackend) export(ctx context.Context, w io.Writer, opts record.ExportOptions) error {
+	return export.New(l.app).Export(ctx, w, opts)
+}
+
+func (l *localBackend) importStatement(ctx context.Context, r io.Reader, opts record.ImportOptions) (record.ImportReport, error) {
+	return importer.New(l.app).Import(ctx, r, opts)
+}
+
+func (l *localBackend) close() error {
+	return l.release()
+}
+
+// openApp locks the transaction file and loads it for a command that works on it directly. The lock is
+// held until release, which flushes the file, is called.
+func openApp(ctx context.Context, file, keyFile string) (app *transactiondemo.App, release func() error, err error) {
+	keys, err := loadKeyring(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	app = &transactiondemo.App{
+		AppFilePath:        file,
+		AppExchangeRateURL: types.ExchangeRateURL,
+		AppKeyring:         keys,
+	}
+	repo := repoModule.New(app)
+	if err = repo.LockFile(); err != nil {
+		return nil, nil, err
+	}
+	app.AppRepo = repo
+	release = func() error {
+		// the command is done with the file, nothing is left to wait for but the flush
+		return repo.Close(context.Background())
+	}
+
+	tx := transaction.New(app)
+	if err = tx.Load(ctx); err != nil {
+		_ = release()
+		return nil, nil, err
+	}
+	app.AppTransaction = tx
+
+	return app, release, nil
+}
+
+// closeBackend closes the backend of the command name once it is done, a failure, e.g. to flush the
+// transaction file, turns the exit code into 1.
+func closeBackend(name string, b backend, code *int) {
+	if err := b.close(); err != nil {
+		fmt.Fprintln(os.Stderr, name+":", err)
+		*code = 1
+	}
+}
+
+// closeRepo releases the transaction file locked by the command name, see closeBackend.
+func closeRepo(name string, repo *repoModule.RepoModule, code *int) {
+	if err := repo.Close(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, name+":", err)
+		*code = 1
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/auth"
+	hm "github.com/suyono3484/transactiondemo/http"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	serverEnv = "TRANSACTIONDEMO_SERVER"
+	apiKeyEnv = "TRANSACTIONDEMO_API_KEY"
+
+	defaultClientTimeout = 30 * time.Second
+)
+
+// target is where a client command works: directly on the transaction file, which it locks, or through
+// the API of the running server given by -server.
+type target struct {
+	file    string
+	keyFile string
+	server  string
+	apiKey  string
+	tenant  string
+	timeout time.Duration
+}
+
+// register adds the flags choosing the target to fs. Without remote, the command only works on the
+// transaction file.
+func (t *target) register(fs *flag.FlagSet, remote bool) {
+	fs.StringVar(&t.file, "file", "data.json", "transaction file")
+	fs.StringVar(&t.keyFile, "keys", "", "key file, defaults to $"+keyFileEnv)
+	if remote {
+		fs.StringVar(&t.server, "server", os.Getenv(serverEnv), "URL of a running server to work through, "+
+			"instead of the transaction file ($"+serverEnv+")")
+		fs.StringVar(&t.apiKey, "api-key", os.Getenv(apiKeyEnv), "API key sent to the server ($"+apiKeyEnv+")")
+		fs.StringVar(&t.tenant, "tenant", "", "tenant to work on through the server")
+		fs.DurationVar(&t.timeout, "timeout", defaultClientTimeout, "time limit of each request to the server, "+
+			"the response included")
+	}
+}
+
+// backend returns the client of the server API or, without -server, the transaction file once locked and
+// loaded. The lock is held until the backend is closed.
+func (t *target) backend(ctx context.Context) (backend, error) {
+	if t.server == "" {
+		app, release, err := openApp(ctx, t.file, t.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &localBackend{app: app, release: release}, nil
+	}
+
+	return t.client()
+}
+
+// client returns the client of the server API.
+func (t *target) client() (*apiClient, error) {
+	base, err := url.Parse(strings.TrimSuffix(t.server, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", t.server)
+	}
+
+	if t.timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout %s", t.timeout)
+	}
+
+	return &apiClient{
+		base:   base,
+		apiKey: t.apiKey,
+		tenant: t.tenant,
+		http:   &http.Client{Timeout: t.timeout},
+	}, nil
+}
+
+// apiClient sends the requests of a client command to the API of a running server.
+type apiClient struct {
+	base   *url.URL
+	apiKey string
+	tenant string
+	http   *http.Client
+}
+
+// do sends a request and returns the response when its status is a success. Otherwise, the problem in the
+// response body is returned as the error.
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		req.Header.Set(auth.APIKeyHeader, c.apiKey)
+	}
+	if c.tenant != "" {
+		req.Header.Set(hm.TenantHeader, c.tenant)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var p hm.Problem
+	if err = json.NewDecoder(resp.Body).Decode(&p); err != nil || p.Title == "" {
+		return nil, fmt.Errorf("server answered %s", resp.Status)
+	}
+	return nil, &problemError{p}
+}
+
+// problemError is the problem a server answered with.
+type problemError struct {
+	hm.Problem
+}
+
+func (e *problemError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("server answered %d %s: %s", e.Status, e.Title, e.Detail)
+	}
+	return fmt.Sprintf("server answered %d %s", e.Status, e.Title)
+}
+
+// call sends in, unless nil, as a JSON body and decodes the JSON response into out.
+func (c *apiClient) call(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var (
+		body        io.Reader
+		contentType string
+	)
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body, contentType = bytes.NewReader(b), "application/json"
+	}
+
+	resp, err := c.do(ctx, method, path, query, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("reading the response: %w", err)
+	}
+	return nil
+}
+
+// add creates the transaction. The server answers 200 instead of 201 when it existed already.
+func (c *apiClient) add(ctx context.Context, description, date, amount string, lines []record.LineItem) (record.TransactionRecord, bool, error) {
+	req := hm.AddRequest{Description: description, Date: date, Amount: hm.Amount(amount)}
+	for _, l := range lines {
+		req.Lines = append(req.Lines, hm.LineItemRequest{
+			Description: l.Description,
+			Category:    l.Category,
+			Tags:        l.Tags,
+			Amount:      hm.Amount(strconv.FormatFloat(l.Amount, 'f', -1, 64)),
+		})
+	}
+
+	b, err := json.Marshal(&req)
+	if err != nil {
+		return record.TransactionRecord{}, false, err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/v1/transactions", nil, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return record.TransactionRecord{}, false, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var rec record.TransactionRecord
+	if err = json.NewDecoder(resp.Body).Decode(&rec); err != nil {
+		return rec, false, fmt.Errorf("reading the response: %w", err)
+	}
+	return rec, resp.StatusCode == http.StatusOK, nil
+}
+
+func (c *apiClient) get(ctx context.Context, id, currency string) (record.ConvertedTransaction, error) {
+	var outRec record.ConvertedTransaction
+	err := c.call(ctx, http.MethodGet, "/v1/transactions/"+url.PathEscape(id), url.Values{"target": {currency}}, nil, &outRec)
+	return outRec, err
+}
+
+func (c *apiClient) list(ctx context.Context, filter record.Filter) ([]record.TransactionRecord, error) {
+	var recs []record.TransactionRecord
+	err := c.call(ctx, http.MethodGet, "/v1/transactions", filterQuery(filter), nil, &recs)
+	return recs, err
+}
+
+func (c *apiClient) export(ctx context.Context, w io.Writer, opts record.ExportOptions) error {
+	query := filterQuery(opts.Filter)
+	if opts.Format != "" {
+		query.Set("format", string(opts.Format))
+	}
+	for _, currency := range opts.Currencies {
+		query.Add("currency", currency)
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/v1/exports", query, "", nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *apiClient) importStatement(ctx context.Context, r io.Reader, opts record.ImportOptions) (record.ImportReport, error) {
+	var (
+		body   bytes.Buffer
+		report record.ImportReport
+		mw     = multipart.NewWriter(&body)
+	)
+
+	var delimiter string
+	if opts.Mapping.Delimiter != 0 {
+		delimiter = string(opts.Mapping.Delimiter)
+	}
+	for _, f := range []struct{ name, value string }{
+		{"format", string(opts.Format)},
+		{"dry_run", strconv.FormatBool(opts.DryRun)},
+		{"date_column", opts.Mapping.Date},
+		{"description_column", opts.Mapping.Description},
+		{"amount_column", opts.Mapping.Amount},
+		{"date_format", opts.Mapping.DateFormat},
+		{"delimiter", delimiter},
+		{"decimal_comma", strconv.FormatBool(opts.Mapping.DecimalComma)},
+		{"no_header", strconv.FormatBool(opts.Mapping.NoHeader)},
+	} {
+		if f.value != "" {
+			_ = mw.WriteField(f.name, f.value)
+		}
+	}
+
+	part, err := mw.CreateFormFile("file", filepath.Base(opts.Name))
+	if err != nil {
+		return report, err
+	}
+	if _, err = io.Copy(part, r); err != nil {
+		return report, err
+	}
+	if err = mw.Close(); err != nil {
+		return report, err
+	}
+
+	var resp *http.Response
+	if resp, err = c.do(ctx, http.MethodPost, "/v1/imports", nil, mw.FormDataContentType(), &body); err != nil {
+		// an import stopped halfway reports the entries imported before the error
+		var p *problemError
+		if errors.As(err, &p) && p.Import != nil {
+			report = *p.Import
+		}
+		return report, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if err = json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return report, fmt.Errorf("reading the response: %w", err)
+	}
+	return report, nil
+}
+
+func (c *apiClient) close() error {
+	return nil
+}
+
+// filterQuery returns the query parameters of the filter of the list and export endpoints.
+func filterQuery(filter record.Filter) url.Values {
+	query := url.Values{}
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.Format(record.FiscalDateFormat))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.Format(record.FiscalDateFormat))
+	}
+	if filter.Category != "" {
+		query.Set("category", filter.Category)
+	}
+	if filter.Tag != "" {
+		query.Set("tag", filter.Tag)
+	}
+
+	return query
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"os"
+	"strconv"
+)
+
+// compactResult is the outcome of compactCommand.
+type compactResult struct {
+	Kept    int `json:"kept"`
+	Dropped int `json:"dropped"`
+}
+
+// compactCommand rewrites the transaction file without the lines repeating a transaction.
+func compactCommand(args []string) (code int) {
+	var t target
+
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	t.register(fs, false)
+	output := outputFlag(fs)
+	_ = fs.Parse(args)
+
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintln(os.Stderr, "compact:", err)
+		return 2
+	}
+
+	k, err := loadKeyring(t.keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "compact:", err)
+		return 1
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	repo := repoModule.New(&transactiondemo.App{AppFilePath: t.file, AppKeyring: k})
+	if err = repo.LockFile(); err != nil {
+		fmt.Fprintln(os.Stderr, "compact:", err)
+		return 1
+	}
+	defer closeRepo("compact", repo, &code)
+
+	var result compactResult
+	if result.Kept, result.Dropped, err = repo.Compact(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "compact:", err)
+		return 1
+	}
+
+	_ = writeOutput(os.Stdout, *output, &result, func() table {
+		tbl := table{header: []string{"KEPT", "DROPPED"}}
+		tbl.add(strconv.Itoa(result.Kept), strconv.Itoa(result.Dropped))
+		return tbl
+	})
+	return 0
+}
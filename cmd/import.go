@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"unicode/utf8"
+)
+
+// importCommand imports a bank statement into the transaction file, or through a server, and prints the
+// report.
+func importCommand(args []string) (code int) {
+	var (
+		opts record.ImportOptions
+		t    target
+	)
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	t.register(fs, true)
+	output := outputFlag(fs)
+	format := fs.String("format", "", "statement format: csv, ofx or qif; detected when empty")
+	delimiter := fs.String("delimiter", "", "CSV field delimiter")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "report duplicates and invalid rows without importing")
+	fs.StringVar(&opts.Mapping.Date, "date-column", "", "CSV date column, header name or 1-based index")
+	fs.StringVar(&opts.Mapping.Description, "description-column", "", "CSV description column")
+	fs.StringVar(&opts.Mapping.Amount, "amount-column", "", "CSV amount column")
+	fs.StringVar(&opts.Mapping.DateFormat, "date-format", "", "CSV date layout in Go notation, e.g. 02/01/2006")
+	fs.BoolVar(&opts.Mapping.DecimalComma, "decimal-comma", false, "CSV amounts use a decimal comma")
+	fs.BoolVar(&opts.Mapping.NoHeader, "no-header", false, "CSV has no header row")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: import [flags] statement")
+		return 2
+	}
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 2
+	}
+	opts.Name = fs.Arg(0)
+	opts.Format = record.ImportFormat(*format)
+	if *delimiter != "" {
+		opts.Mapping.Delimiter, _ = utf8.DecodeRuneInString(*delimiter)
+	}
+
+	statement, err := os.Open(opts.Name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+	defer func() {
+		_ = statement.Close()
+	}()
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	b, err := t.backend(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+	defer closeBackend("import", b, &code)
+
+	// the report of an import stopped halfway tells the rows already imported
+	report, err := b.importStatement(ctx, statement, opts)
+	if err == nil || report.Rows > 0 {
+		printImportReport(*output, report)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+	return 0
+}
+
+func printImportReport(output string, report record.ImportReport) {
+	if output == outputJSON {
+		_ = writeOutput(os.Stdout, output, &report, nil)
+		return
+	}
+
+	summary := table{header: []string{"FORMAT", "DRY RUN", "ROWS", "IMPORTED", "DUPLICATES", "INVALID"}}
+	summary.add(string(report.Format), strconv.FormatBool(report.DryRun), strconv.Itoa(report.Rows),
+		strconv.Itoa(report.Imported), strconv.Itoa(len(report.Duplicates)), strconv.Itoa(len(report.Invalid)))
+	_ = writeTable(os.Stdout, summary)
+
+	if len(report.Duplicates)+len(report.Invalid) > 0 {
+		rows := table{header: []string{"ROW", "STATUS", "ID", "DATE", "DESCRIPTION", "AMOUNT", "ERROR"}}
+		for _, r := range append(report.Duplicates, report.Invalid...) {
+			status := "duplicate"
+			if r.Error != "" {
+				status = "invalid"
+			}
+			rows.add(strconv.Itoa(r.Row), status, r.ID, r.Date, r.Description, r.Amount, r.Error)
+		}
+		fmt.Println()
+		_ = writeTable(os.Stdout, rows)
+	}
+}
+
+// cliContext carries the operating system user as the principal of the changes made by a command. It is
+// canceled on an interrupt, so the command stops at the next record and leaves the files as they were.
+func cliContext() (context.Context, context.CancelFunc) {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	ctx := types.WithPrincipal(context.Background(), types.Principal{
+		Name:   name,
+		Source: types.SourceCLI,
+	})
+	return signal.NotifyContext(ctx, os.Interrupt)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/keyring"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"os"
+)
+
+const (
+	keysEnv    = "TRANSACTIONDEMO_ENCRYPTION_KEYS"
+	keyFileEnv = "TRANSACTIONDEMO_KEY_FILE"
+)
+
+// loadKeyring reads the encryption keys from the environment, or from the key file given by flag or by
+// environment.
+func loadKeyring(keyFile string) (*keyring.Keyring, error) {
+	if keyFile == "" {
+		keyFile = os.Getenv(keyFileEnv)
+	}
+
+	return keyring.Load(os.Getenv(keysEnv), keyFile)
+}
+
+// keygenCommand prints a new key entry. Prepending it to the key file makes it the primary key.
+func keygenCommand(args []string) int {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	id := fs.String("id", "", "key id")
+	_ = fs.Parse(args)
+
+	if *id == "" {
+		fmt.Fprintln(os.Stderr, "keygen: -id is required")
+		return 2
+	}
+
+	entry, err := keyring.Generate(*id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "keygen:", err)
+		return 1
+	}
+
+	fmt.Println(entry)
+	return 0
+}
+
+// reencryptCommand rewrites the transaction file with the primary key.
+func reencryptCommand(args []string) (code int) {
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	file := fs.String("file", "data.json", "transaction file")
+	keyFile := fs.String("keys", "", "key file, defaults to $"+keyFileEnv)
+	_ = fs.Parse(args)
+
+	k, err := loadKeyring(*keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reencrypt:", err)
+		return 1
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	repo := repoModule.New(&transactiondemo.App{AppFilePath: *file, AppKeyring: k})
+	if err = repo.LockFile(); err != nil {
+		fmt.Fprintln(os.Stderr, "reencrypt:", err)
+		return 1
+	}
+	defer closeRepo("reencrypt", repo, &code)
+
+	var n int
+	if n, err = repo.Reencrypt(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "reencrypt:", err)
+		return 1
+	}
+
+	if k == nil {
+		fmt.Printf("%d records written in cleartext\n", n)
+	} else {
+		fmt.Printf("%d records encrypted with key %s\n", n, k.Primary())
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/logging"
+	"log/slog"
+	"os"
+)
+
+// loadLogger returns the logger of the server writing to stderr, in the format and from the level of the
+// configuration. It becomes the default logger, so the standard log package writes through it too.
+func loadLogger(c config.Log) (*logging.Logger, error) {
+	level, err := logging.ParseLevel(c.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	logger, err := logging.New(os.Stderr, c.Format, level)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.SetDefault(logger.Logger)
+	return logger, nil
+}
+
+// fatal logs the error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
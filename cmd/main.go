@@ -2,61 +2,194 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"flag"
+	"fmt"
 	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/config"
 	hm "github.com/suyono3484/transactiondemo/http"
+	"github.com/suyono3484/transactiondemo/metrics"
 	repoModule "github.com/suyono3484/transactiondemo/repository"
-	"github.com/suyono3484/transactiondemo/transaction"
-	"github.com/suyono3484/transactiondemo/types"
-	"log"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
+	"runtime/debug"
 )
 
+// version is set when building, with -ldflags "-X main.version=v1.2.3".
+var version string
+
+// buildVersion returns version, or the version of the module when it was not set.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "unknown"
+}
+
 func main() {
+	// the server runs by default, serve only names it
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "serve":
+			args = args[1:]
+		case "add":
+			os.Exit(addCommand(args[1:]))
+		case "get":
+			os.Exit(getCommand(args[1:]))
+		case "list":
+			os.Exit(listCommand(args[1:]))
+		case "convert":
+			os.Exit(convertCommand(args[1:]))
+		case "export":
+			os.Exit(exportCommand(args[1:]))
+		case "compact":
+			os.Exit(compactCommand(args[1:]))
+		case "rates":
+			os.Exit(ratesCommand(args[1:]))
+		case "verify":
+			os.Exit(verifyCommand(args[1:]))
+		case "checkpoint":
+			os.Exit(checkpointCommand(args[1:]))
+		case "keygen":
+			os.Exit(keygenCommand(args[1:]))
+		case "reencrypt":
+			os.Exit(reencryptCommand(args[1:]))
+		case "import":
+			os.Exit(importCommand(args[1:]))
+		case "apikey":
+			os.Exit(apikeyCommand(args[1:]))
+		}
+	}
+
+	cfg, err := config.Load(os.Args[0], args, os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+
+	logger, err := loadLogger(cfg.Log)
+	if err != nil {
+		fatal("configuring the log", err)
+	}
+
+	keys, err := loadKeyring(cfg.Storage.KeyFile)
+	if err != nil {
+		fatal("loading encryption keys", err)
+	}
+
+	authenticator, err := loadAuthenticator(cfg.Auth)
+	if err != nil {
+		fatal("loading API keys", err)
+	}
+	if authenticator == nil {
+		slog.Warn("serving the API, the admin API included, without authentication", "setting", "auth.insecure_no_auth")
+	}
+
+	limiter, err := loadRateLimiter(cfg.Auth.RateLimits)
+	if err != nil {
+		fatal("reading rate limits", err)
+	}
+
+	tracer, err := loadTracer(cfg.Tracing)
+	if err != nil {
+		fatal("opening the trace exporter", err)
+	}
+
+	tlsConfig, err := loadTLS(cfg.TLS)
+	if err != nil {
+		fatal("loading the TLS certificate", err)
+	}
+
 	app := &transactiondemo.App{
-		AppFilePath:        "data.json",
+		AppFilePath:        cfg.Storage.File,
 		AppSkipFile:        false,
-		AppExchangeRateURL: types.ExchangeRateURL,
+		AppExchangeRateURL: cfg.ExchangeRates.URL,
+		AppCacheTTL:        cfg.ExchangeRates.CacheTTL,
+		AppUpstreamTimeout: cfg.ExchangeRates.Timeout,
+		AppKeyring:         keys,
+		AppAuthenticator:   authenticator,
+		AppRateLimiter:     limiter,
+		AppMetrics:         metrics.NewRegistry(),
+		AppTracer:          tracer,
+		AppNotifier:        loadNotifier(cfg.Alerts),
+		AppLogger:          logger,
+		AppVersion:         buildVersion(),
 	}
 	repo := repoModule.New(app)
-	app.AppRepo = repo
-	tx := transaction.New(app)
-	err := tx.Load()
+	if err = repo.LockFile(); err != nil {
+		fatal("locking the transaction file", err)
+	}
+
+	tenants, quota, err := newTenants(app, repo, cfg.Storage)
 	if err != nil {
-		log.Fatal("loading persistence data:", err)
+		fatal("reading tenant quotas", err)
 	}
-	app.AppTransaction = tx
+
+	m, err := assemble(context.Background(), app, repo, quota)
+	if err != nil {
+		fatal("loading the data", err)
+	}
+
+	if err = tenants.LoadAll(); err != nil {
+		fatal("loading tenants", err)
+	}
+	app.AppTenants = tenants
+	registerDataMetrics(app.AppMetrics, app, tenants)
+
+	app.AppReloader = config.NewReloader(cfg, func() (config.Config, error) {
+		return config.Load(os.Args[0], args, os.Getenv, io.Discard)
+	}, prepareReload(app, logger))
+	reloadOnHangup(app.AppReloader)
+
 	httpModule := hm.New(app)
 
 	srv := &http.Server{
-		Handler: httpModule.Router(),
+		Handler:           httpModule.Router(),
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
-	idleConnClosed := make(chan any)
 
 	var l net.Listener
-	l, err = net.Listen("tcp", "")
+	l, err = net.Listen("tcp", cfg.Listen)
 	if err != nil {
-		log.Fatal(err)
+		fatal("listening", err)
 	}
-
-	go func() {
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, os.Interrupt)
-		<-sig
-
-		if err := srv.Shutdown(context.Background()); err != nil {
-			log.Printf("shutting down: %v\n", err)
-		}
-		close(idleConnClosed)
-	}()
-
-	log.Printf("HTTP server is listening on %v", l.Addr())
-	if err = srv.Serve(l); errors.Is(err, http.ErrServerClosed) {
-		log.Fatal(err)
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
 	}
 
-	<-idleConnClosed
+	slog.Info("HTTP server is listening", "address", l.Addr().String(), "tls", tlsConfig != nil,
+		"version", app.AppVersion)
+	os.Exit(serve(srv, l, cfg.Timeouts.Shutdown,
+		stopStep{name: "stopping the scheduler", stop: func(context.Context) error {
+			m.scheduler.Stop()
+			return nil
+		}},
+		stopStep{name: "stopping tenants", stop: tenants.Close},
+		stopStep{name: "evaluating budgets", stop: m.budgets.Close},
+		stopStep{name: "posting budget alerts", stop: func(ctx context.Context) error {
+			if c, ok := app.AppNotifier.(interface{ Close(context.Context) error }); ok {
+				return c.Close(ctx)
+			}
+			return nil
+		}},
+		stopStep{name: "flushing the transaction file", stop: repo.Close},
+		stopStep{name: "exporting spans", stop: tracer.Shutdown},
+	))
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/metrics"
+	"github.com/suyono3484/transactiondemo/tenant"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+)
+
+// registerDataMetrics registers the gauges of the transaction count and the transaction file size of the
+// default tenant and of the tenants loaded so far.
+func registerDataMetrics(reg *metrics.Registry, app *transactiondemo.App, tenants *tenant.Registry) {
+	apps := func() map[string]*transactiondemo.App {
+		all := map[string]*transactiondemo.App{types.DefaultTenant: app}
+		for _, name := range tenants.Names() {
+			if t, err := tenants.Get(name); err == nil {
+				all[name] = t.(*tenantApp).App
+			}
+		}
+		return all
+	}
+
+	reg.GaugeFunc("transactiondemo_transactions", "Transactions stored.", []string{"tenant"},
+		func() []metrics.Sample {
+			var samples []metrics.Sample
+			for name, a := range apps() {
+				if n, err := a.Transaction().Count(context.Background()); err == nil {
+					samples = append(samples, metrics.Sample{Labels: []string{name}, Value: float64(n)})
+				}
+			}
+			return samples
+		})
+
+	reg.GaugeFunc("transactiondemo_data_file_bytes", "Size of the transaction file.", []string{"tenant"},
+		func() []metrics.Sample {
+			var samples []metrics.Sample
+			for name, a := range apps() {
+				if info, err := os.Stat(a.FilePath()); err == nil {
+					samples = append(samples, metrics.Sample{Labels: []string{name}, Value: float64(info.Size())})
+				}
+			}
+			return samples
+		})
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/suyono3484/transactiondemo/budget"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+)
+
+// loadNotifier returns the notifier posting the budget alerts to the webhook, or else appending them to the
+// file, of the configuration. Without either, the alerts are logged.
+func loadNotifier(c config.Alerts) types.Notifier {
+	if c.WebhookURL != "" {
+		return &budget.WebhookNotifier{URL: c.WebhookURL, Client: &http.Client{Timeout: c.Timeout}}
+	}
+
+	if c.File != "" {
+		return &budget.FileNotifier{Path: c.File}
+	}
+
+	return &budget.LogNotifier{}
+}
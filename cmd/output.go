@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// The output formats of the client commands.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// table is the output of a command in the table format, the header followed by a row per item.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// outputFlag adds the flag choosing the output format to fs.
+func outputFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", outputTable, "output format: table or json")
+}
+
+// checkOutput rejects an unknown output format before the command does anything.
+func checkOutput(format string) error {
+	if format != outputTable && format != outputJSON {
+		return fmt.Errorf("unknown output format %q, expected %s or %s", format, outputTable, outputJSON)
+	}
+
+	return nil
+}
+
+// writeOutput writes v as indented JSON or, in the table format, the table built by toTable from v.
+func writeOutput(w io.Writer, format string, v any, toTable func() table) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	return writeTable(w, toTable())
+}
+
+func writeTable(w io.Writer, t table) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+	for _, row := range t.rows {
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/suyono3484/transactiondemo/ratelimit"
+)
+
+// loadRateLimiter reads the rate limits of the spec, e.g. "read=10:20,write=1:5". The limiter is created
+// even without limits, so that they can be set at runtime.
+func loadRateLimiter(spec string) (*ratelimit.Limiter, error) {
+	config, err := ratelimit.ParseConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return ratelimit.New(config)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ratesCommand prints the exchange rates of a currency that a transaction of a date may be converted
+// with, those effective in the six months before it, the latest first. It asks the rate provider directly.
+func ratesCommand(args []string) int {
+	fs := flag.NewFlagSet("rates", flag.ExitOnError)
+	currency := fs.String("currency", "", "the Treasury country-currency, e.g. Canada-Dollar")
+	date := fs.String("date", time.Now().Format(record.FiscalDateFormat), "date of the transaction")
+	url := fs.String("exchange-rate-url", types.ExchangeRateURL, "exchange rate provider")
+	output := outputFlag(fs)
+	_ = fs.Parse(args)
+
+	if *currency == "" {
+		fmt.Fprintln(os.Stderr, "rates: -currency is required")
+		return 2
+	}
+	txDate, err := time.Parse(record.FiscalDateFormat, *date)
+	if err == nil {
+		err = checkOutput(*output)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rates:", err)
+		return 2
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	repo := repoModule.New(&transactiondemo.App{AppSkipFile: true, AppExchangeRateURL: *url})
+	var rates []record.FiscalRecord
+	if rates, err = repo.FetchFiscalData(ctx, *currency, txDate.AddDate(0, -6, 0), txDate); err != nil {
+		fmt.Fprintln(os.Stderr, "rates:", err)
+		return 1
+	}
+	if rates == nil {
+		rates = []record.FiscalRecord{}
+	}
+
+	_ = writeOutput(os.Stdout, *output, rates, func() table {
+		tbl := table{header: []string{"EFFECTIVE DATE", "RECORD DATE", "CURRENCY", "RATE"}}
+		for _, r := range rates {
+			tbl.add(formatDate(r.EffectiveDate), formatDate(r.RecordDate), r.CountryCurrencyDesc,
+				strconv.FormatFloat(r.ExchangeRate.Float(), 'f', -1, 64))
+		}
+		return tbl
+	})
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/ratelimit"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// prepareReload returns the config.Prepare of the server. The API keys file and the JWKS are read again
+// even when they are named as before, so that SIGHUP picks up a rotated key. The rate limits, the log level
+// and the exchange rate URL are only applied when the configuration changes them, the values set through
+// the admin API are kept otherwise.
+func prepareReload(app *transactiondemo.App, logger *logging.Logger) config.Prepare {
+	return func(current, next config.Config) (func(), error) {
+		authenticator, err := loadAuthenticator(next.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("loading API keys: %w", err)
+		}
+		if (authenticator == nil) != (app.AppAuthenticator == nil) {
+			return nil, errors.New("enabling or disabling the authentication requires a restart")
+		}
+
+		var limits ratelimit.Config
+		if limits, err = ratelimit.ParseConfig(next.Auth.RateLimits); err != nil {
+			return nil, fmt.Errorf("reading rate limits: %w", err)
+		}
+		if err = limits.Validate(); err != nil {
+			return nil, fmt.Errorf("reading rate limits: %w", err)
+		}
+
+		var level slog.Level
+		if level, err = logging.ParseLevel(next.Log.Level); err != nil {
+			return nil, err
+		}
+
+		return func() {
+			if authenticator != nil {
+				app.AppAuthenticator.Replace(authenticator)
+			}
+			if next.Auth.RateLimits != current.Auth.RateLimits {
+				_ = app.AppRateLimiter.SetConfig(limits)
+			}
+			if next.Log.Level != current.Log.Level {
+				logger.SetLevel(level)
+			}
+			if next.ExchangeRates.URL != current.ExchangeRates.URL {
+				app.SetExchangeRateURL(next.ExchangeRates.URL)
+			}
+		}, nil
+	}
+}
+
+// reloadOnHangup reloads the configuration on every SIGHUP. A rejected configuration is logged and the
+// current one kept.
+func reloadOnHangup(r *config.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			report, err := r.Reload()
+			if err != nil {
+				slog.Error("configuration reload rejected", "error", err)
+				continue
+			}
+
+			slog.Info("configuration reloaded", "applied", report.Applied, "restart_required", report.RestartRequired)
+		}
+	}()
+}
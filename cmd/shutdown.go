@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// stopStep is a step of the shutdown, run after the HTTP server stopped serving.
+type stopStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// serve serves srv on l until SIGINT or SIGTERM, or until it fails, then shuts the server down and runs the
+// steps in order. The whole shutdown is given timeout: the requests in progress complete, the connections
+// still open are closed and the steps run within the same deadline, a step gets what the ones before left.
+// It returns the exit code: 0 when everything stopped cleanly, 1 otherwise. A second signal during the
+// shutdown terminates the process.
+func serve(srv *http.Server, l net.Listener, timeout time.Duration, steps ...stopStep) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	code := 0
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-served:
+		// Serve always returns an error, http.ErrServerClosed only after Shutdown, which did not happen
+		slog.Error("serving", "error", err)
+		code = 1
+	case <-ctx.Done():
+		stop()
+		slog.Info("shutting down", "timeout", timeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("draining the requests", "error", err)
+		_ = srv.Close()
+		code = 1
+	}
+
+	for _, s := range steps {
+		if err := s.stop(shutdownCtx); err != nil {
+			slog.Error(s.name, "error", err)
+			code = 1
+		}
+	}
+
+	if code == 0 {
+		slog.Info("stopped")
+	}
+	return code
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/budget"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/export"
+	"github.com/suyono3484/transactiondemo/importer"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/schedule"
+	"github.com/suyono3484/transactiondemo/tenant"
+	"github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/types"
+	"path/filepath"
+	"time"
+)
+
+// tenantApp is the app of a tenant other than the default one, with the scheduler to stop, the budget
+// evaluations to wait for and the repository to flush on Close.
+type tenantApp struct {
+	*transactiondemo.App
+	scheduler *schedule.Module
+	budgets   *budget.Module
+	repo      *repoModule.RepoModule
+}
+
+func (t *tenantApp) Close(ctx context.Context) error {
+	t.scheduler.Stop()
+	return errors.Join(t.budgets.Close(ctx), t.repo.Close(ctx))
+}
+
+// modules are the modules of an app that have to be stopped on shutdown.
+type modules struct {
+	scheduler *schedule.Module
+	budgets   *budget.Module
+}
+
+// assemble loads the modules of app from its transaction file, through repo, and starts its scheduler. It
+// returns the modules to stop on shutdown. The budget alerts are logged unless app has its notifier.
+func assemble(ctx context.Context, app *transactiondemo.App, repo *repoModule.RepoModule, quota tenant.Quota) (m modules, err error) {
+	app.AppRepo = repo
+	tx := transaction.New(app)
+	if err = tx.Load(ctx); err != nil {
+		return m, fmt.Errorf("loading transactions: %w", err)
+	}
+	tx.SetQuota(quota.MaxTransactions)
+	app.AppTransaction = tx
+
+	if app.AppNotifier == nil {
+		app.AppNotifier = &budget.LogNotifier{}
+	}
+	m.budgets = budget.New(app)
+	if err = m.budgets.Load(ctx); err != nil {
+		return m, fmt.Errorf("loading budgets: %w", err)
+	}
+	app.AppBudget = m.budgets
+	tx.OnAdd(m.budgets.Evaluate)
+
+	app.AppImporter = importer.New(app)
+	app.AppExporter = export.New(app)
+
+	m.scheduler = schedule.New(app)
+	if err = m.scheduler.Load(ctx); err != nil {
+		return m, fmt.Errorf("loading schedules: %w", err)
+	}
+	app.AppScheduler = m.scheduler
+	m.scheduler.Start(time.Hour)
+
+	return m, nil
+}
+
+// newTenants returns the registry of the tenants other than the default one, kept in the tenants directory
+// next to the transaction file of app unless the storage configuration names another. The tenants share
+// the exchange rate cache, the logger and the tracer of app. It also returns the quota of the default
+// tenant.
+func newTenants(app *transactiondemo.App, repo *repoModule.RepoModule, c config.Storage) (*tenant.Registry, tenant.Quota, error) {
+	defaultQuota, quotas, err := tenant.ParseQuotas(c.TenantQuotas)
+	if err != nil {
+		return nil, tenant.Quota{}, err
+	}
+
+	dir := c.TenantsDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(app.AppFilePath), "tenants")
+	}
+
+	build := func(name, filePath string, quota tenant.Quota) (types.TenantI, error) {
+		t := &transactiondemo.App{
+			AppFilePath:        filePath,
+			AppSkipFile:        app.AppSkipFile,
+			AppExchangeRateURL: app.AppExchangeRateURL,
+			AppCacheTTL:        app.AppCacheTTL,
+			AppUpstreamTimeout: app.AppUpstreamTimeout,
+			AppKeyring:         app.AppKeyring,
+			AppMetrics:         app.AppMetrics,
+			AppTracer:          app.AppTracer,
+			AppNotifier:        app.AppNotifier,
+			AppLogger:          app.AppLogger,
+		}
+
+		// the tenant is shared by the requests, so its loading is not tied to the request that needs it first
+		tenantRepo := repo.ForTenant(t)
+		if err := tenantRepo.LockFile(); err != nil {
+			return nil, err
+		}
+		m, err := assemble(context.Background(), t, tenantRepo, quota)
+		if err != nil {
+			_ = tenantRepo.Close(context.Background())
+			return nil, err
+		}
+
+		return &tenantApp{App: t, scheduler: m.scheduler, budgets: m.budgets, repo: tenantRepo}, nil
+	}
+
+	quota, ok := quotas[types.DefaultTenant]
+	if !ok {
+		quota = defaultQuota
+	}
+
+	tenants := tenant.New(dir, build, defaultQuota, quotas)
+	tenants.SetMax(c.MaxTenants)
+
+	return tenants, quota, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"crypto/tls"
+	"github.com/suyono3484/transactiondemo/certs"
+	"github.com/suyono3484/transactiondemo/config"
+)
+
+// loadTLS returns the TLS configuration of the server, nil without a certificate. The certificate, its key
+// and the client CAs are read again when they change on disk.
+func loadTLS(c config.TLS) (*tls.Config, error) {
+	if c.CertFile == "" {
+		return nil, nil
+	}
+
+	version, err := certs.ParseVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientAuth tls.ClientAuthType
+	if clientAuth, err = certs.ParseClientAuth(c.ClientAuth, c.ClientCAFile != ""); err != nil {
+		return nil, err
+	}
+
+	var store *certs.Store
+	if store, err = certs.NewStore(certs.Files{
+		CertFile:     c.CertFile,
+		KeyFile:      c.KeyFile,
+		ClientCAFile: c.ClientCAFile,
+	}); err != nil {
+		return nil, err
+	}
+
+	return store.ServerConfig(version, clientAuth), nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/tracing"
+)
+
+const serviceName = "transactiondemo"
+
+// loadTracer returns the tracer exporting to the OTLP collector, or else to the file, of the configuration.
+// Without either, requests are not traced.
+func loadTracer(c config.Tracing) (*tracing.Tracer, error) {
+	if endpoint := c.OTLPEndpoint; endpoint != "" {
+		return tracing.NewTracer(tracing.NewOTLPExporter(endpoint, serviceName)), nil
+	}
+
+	if path := c.File; path != "" {
+		exporter, err := tracing.NewFileExporter(path)
+		if err != nil {
+			return nil, err
+		}
+		return tracing.NewTracer(exporter), nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lineFlags collects the line items of a split transaction, one JSON object per -line flag.
+type lineFlags []record.LineItem
+
+func (l *lineFlags) String() string {
+	return ""
+}
+
+func (l *lineFlags) Set(v string) error {
+	var item record.LineItem
+	if err := json.Unmarshal([]byte(v), &item); err != nil {
+		return err
+	}
+
+	*l = append(*l, item)
+	return nil
+}
+
+// filterFlags adds the flags of a transaction filter to fs.
+func filterFlags(fs *flag.FlagSet) func() (record.Filter, error) {
+	from := fs.String("from", "", "first date, e.g. 2023-09-01")
+	to := fs.String("to", "", "last date, e.g. 2023-09-30")
+	category := fs.String("category", "", "only the transactions with a line item in the category")
+	tag := fs.String("tag", "", "only the transactions with a line item having the tag")
+
+	return func() (filter record.Filter, err error) {
+		filter.Category, filter.Tag = *category, *tag
+		if *from != "" {
+			if filter.From, err = time.Parse(record.FiscalDateFormat, *from); err != nil {
+				return filter, fmt.Errorf("invalid -from date: %w", err)
+			}
+		}
+		if *to != "" {
+			if filter.To, err = time.Parse(record.FiscalDateFormat, *to); err != nil {
+				return filter, fmt.Errorf("invalid -to date: %w", err)
+			}
+		}
+
+		return filter, nil
+	}
+}
+
+// addCommand adds a transaction and prints it, or the stored one when the transaction exists already.
+func addCommand(args []string) (code int) {
+	var (
+		t     target
+		lines lineFlags
+	)
+
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	t.register(fs, true)
+	output := outputFlag(fs)
+	description := fs.String("description", "", "description")
+	date := fs.String("date", "", "date, e.g. 2023-09-12")
+	amount := fs.String("amount", "", "amount in US dollars")
+	fs.Var(&lines, "line", `line item of a split transaction, may be repeated, e.g. `+
+		`{"amount":12.5,"category":"food","tags":["trip"],"description":"lunch"}`)
+	_ = fs.Parse(args)
+
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintln(os.Stderr, "add:", err)
+		return 2
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	b, err := t.backend(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "add:", err)
+		return 1
+	}
+	defer closeBackend("add", b, &code)
+
+	rec, existed, err := b.add(ctx, *description, *date, *amount, lines)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "add:", err)
+		return 1
+	}
+	if existed {
+		fmt.Fprintf(os.Stderr, "add: transaction %s exists already, nothing was added\n", rec.ID)
+	}
+
+	_ = writeOutput(os.Stdout, *output, &rec, func() table {
+		return transactionTable(rec)
+	})
+	return 0
+}
+
+// getCommand prints a transaction and its line items.
+func getCommand(args []string) (code int) {
+	var t target
+
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	t.register(fs, true)
+	output := outputFlag(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: get [flags] id")
+		return 2
+	}
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintln(os.Stderr, "get:", err)
+		return 2
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	b, err := t.backend(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "get:", err)
+		return 1
+	}
+	defer closeBackend("get", b, &code)
+
+	// the default currency needs no exchange rate
+	outRec, err := b.get(ctx, fs.Arg(0), types.DefaultCurrency)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "get:", err)
+		return 1
+	}
+
+	_ = writeOutput(os.Stdout, *output, &outRec.TransactionRecord, func() table {
+		return transactionTable(outRec.TransactionRecord)
+	})
+	return 0
+}
+
+// listCommand prints the transactions matching a filter, by date.
+func listCommand(args []string) (code int) {
+	var t target
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	t.register(fs, true)
+	output := outputFlag(fs)
+	parseFilter := filterFlags(fs)
+	_ = fs.Parse(args)
+
+	filter, err := parseFilter()
+	if err == nil {
+		err = checkOutput(*output)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "list:", err)
+		return 2
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	b, err := t.backend(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "list:", err)
+		return 1
+	}
+	defer closeBackend("list", b, &code)
+
+	recs, err := b.list(ctx, filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "list:", err)
+		return 1
+	}
+
+	_ = writeOutput(os.Stdout, *output, recs, func() table {
+		tbl := table{header: []string{"ID", "DATE", "DESCRIPTION", "AMOUNT", "LINES"}}
+		for _, rec := range recs {
+			tbl.add(rec.ID, formatDate(rec.Date), rec.Description, formatAmount(rec.Amount), strconv.Itoa(len(rec.Lines)))
+		}
+		return tbl
+	})
+	return 0
+}
+
+// convertCommand prints transactions converted to a currency with the exchange rate of their date.
+func convertCommand(args []string) (code int) {
+	var t target
+
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	t.register(fs, true)
+	output := outputFlag(fs)
+	currency := fs.String("to", "", "the Treasury country-currency, e.g. Canada-Dollar")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 || *currency == "" {
+		fmt.Fprintln(os.Stderr, "usage: convert -to currency [flags] id...")
+		return 2
+	}
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintln(os.Stderr, "convert:", err)
+		return 2
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	b, err := t.backend(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "convert:", err)
+		return 1
+	}
+	defer closeBackend("convert", b, &code)
+
+	outRecs := make([]record.ConvertedTransaction, 0, fs.NArg())
+	for _, id := range fs.Args() {
+		var outRec record.ConvertedTransaction
+		if outRec, err = b.get(ctx, id, *currency); err != nil {
+			fmt.Fprintf(os.Stderr, "convert: %s: %v\n", id, err)
+			return 1
+		}
+		outRecs = append(outRecs, outRec)
+	}
+
+	_ = writeOutput(os.Stdout, *output, outRecs, func() table {
+		tbl := table{header: []string{"ID", "DATE", "DESCRIPTION", "AMOUNT", "RATE", "EFFECTIVE DATE", "CONVERTED"}}
+		for _, outRec := range outRecs {
+			effective := ""
+			if outRec.EffectiveDate != nil {
+				effective = formatDate(*outRec.EffectiveDate)
+			}
+			tbl.add(outRec.ID, formatDate(outRec.Date), outRec.Description, formatAmount(outRec.Amount),
+				strconv.FormatFloat(outRec.Rate, 'f', -1, 64), effective, formatAmount(outRec.Converted))
+		}
+		return tbl
+	})
+	return 0
+}
+
+// exportCommand writes the transactions matching a filter to stdout as CSV, CSV for Excel or JSON lines.
+func exportCommand(args []string) (code int) {
+	var (
+		t    target
+		opts record.ExportOptions
+	)
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	t.register(fs, true)
+	format := fs.String("format", string(record.ExportCSV), "csv, excel or json (JSON lines)")
+	currencies := fs.String("currency", "", "comma separated currencies to convert to")
+	parseFilter := filterFlags(fs)
+	_ = fs.Parse(args)
+
+	var err error
+	if opts.Filter, err = parseFilter(); err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 2
+	}
+	opts.Format = record.ExportFormat(*format)
+	switch opts.Format {
+	case record.ExportCSV, record.ExportExcel, record.ExportJSON:
+	default:
+		fmt.Fprintf(os.Stderr, "export: unknown format %q\n", *format)
+		return 2
+	}
+	for _, c := range strings.Split(*currencies, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			opts.Currencies = append(opts.Currencies, c)
+		}
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	b, err := t.backend(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 1
+	}
+	defer closeBackend("export", b, &code)
+
+	if err = b.export(ctx, os.Stdout, opts); err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 1
+	}
+	return 0
+}
+
+// transactionTable returns a transaction as a table, followed by its line items.
+func transactionTable(rec record.TransactionRecord) table {
+	tbl := table{header: []string{"ID", "DATE", "DESCRIPTION", "AMOUNT", "CATEGORY", "TAGS"}}
+	tbl.add(rec.ID, formatDate(rec.Date), rec.Description, formatAmount(rec.Amount), "", "")
+	for _, l := range rec.Lines {
+		tbl.add("", "", "  "+l.Description, formatAmount(l.Amount), l.Category, strings.Join(l.Tags, ","))
+	}
+
+	return tbl
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func formatDate(date record.FiscalDate) string {
+	return date.Date().Format(record.FiscalDateFormat)
+}
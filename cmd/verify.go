@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"os"
+)
+
+// verifyCommand checks the hash chain of the transaction file and, optionally, that the file still matches
+// an archived checkpoint.
+func verifyCommand(args []string) (code int) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	file := fs.String("file", "data.json", "transaction file")
+	checkpoint := fs.String("checkpoint", "", "checkpoint file to verify against")
+	pub := fs.String("pub", "", "hex encoded public key of the checkpoint signer")
+	key := fs.String("key", "", "signing key file, used to derive the public key when -pub is not given")
+	_ = fs.Parse(args)
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	repo := repoModule.New(&transactiondemo.App{AppFilePath: *file})
+	if err := repo.LockFile(); err != nil {
+		fmt.Fprintln(os.Stderr, "verify:", err)
+		return 1
+	}
+	defer closeRepo("verify", repo, &code)
+
+	cp, err := repo.Verify(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verify:", err)
+		return 1
+	}
+	if cp.Chained {
+		fmt.Printf("chain intact: %d lines, head %s\n", cp.Lines, cp.Head)
+	} else {
+		fmt.Printf("file not chained: %d lines, written before the chain or stripped of it, compact chains it\n", cp.Lines)
+	}
+
+	if *checkpoint == "" {
+		return 0
+	}
+
+	var (
+		b         []byte
+		archived  record.Checkpoint
+		publicKey ed25519.PublicKey
+	)
+	if b, err = os.ReadFile(*checkpoint); err == nil {
+		err = json.Unmarshal(b, &archived)
+	}
+	if err == nil {
+		publicKey, err = checkpointPublicKey(*pub, *key)
+	}
+	if err == nil {
+		err = repo.VerifyCheckpoint(ctx, archived, publicKey)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verify:", err)
+		return 1
+	}
+
+	fmt.Printf("checkpoint of %s matches at line %d\n", archived.Time, archived.Lines)
+	return 0
+}
+
+// checkpointCommand writes a signed checkpoint of the transaction file to stdout. The signing key file is
+// created when it does not exist.
+func checkpointCommand(args []string) (code int) {
+	fs := flag.NewFlagSet("checkpoint", flag.ExitOnError)
+	file := fs.String("file", "data.json", "transaction file")
+	key := fs.String("key", "checkpoint.key", "signing key file")
+	_ = fs.Parse(args)
+
+	signingKey, err := repoModule.ReadSigningKey(*key, true)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "checkpoint:", err)
+		return 1
+	}
+
+	ctx, stop := cliContext()
+	defer stop()
+
+	repo := repoModule.New(&transactiondemo.App{AppFilePath: *file})
+	if err = repo.LockFile(); err != nil {
+		fmt.Fprintln(os.Stderr, "checkpoint:", err)
+		return 1
+	}
+	defer closeRepo("checkpoint", repo, &code)
+
+	var cp record.Checkpoint
+	if cp, err = repo.Checkpoint(ctx, signingKey); err != nil {
+		fmt.Fprintln(os.Stderr, "checkpoint:", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(&cp)
+	return 0
+}
+
+func checkpointPublicKey(pub, key string) (ed25519.PublicKey, error) {
+	if pub != "" {
+		b, err := hex.DecodeString(pub)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key")
+		}
+		return b, nil
+	}
+
+	if key == "" {
+		return nil, fmt.Errorf("either -pub or -key is required to verify a checkpoint")
+	}
+
+	signingKey, err := repoModule.ReadSigningKey(key, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return signingKey.Public().(ed25519.PublicKey), nil
+}
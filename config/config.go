@@ -0,0 +1,501 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/certs"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/ratelimit"
+	"github.com/suyono3484/transactiondemo/tenant"
+	"github.com/suyono3484/transactiondemo/types"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the configuration file when the -config flag does not.
+const FileEnv = "TRANSACTIONDEMO_CONFIG"
+
+// Config is the configuration of the server. The YAML keys are given by the field tags.
+type Config struct {
+	Listen        string        `yaml:"listen"`
+	TLS           TLS           `yaml:"tls"`
+	Storage       Storage       `yaml:"storage"`
+	ExchangeRates ExchangeRates `yaml:"exchange_rates"`
+	Timeouts      Timeouts      `yaml:"timeouts"`
+	Auth          Auth          `yaml:"auth"`
+	Log           Log           `yaml:"log"`
+	Tracing       Tracing       `yaml:"tracing"`
+	Alerts        Alerts        `yaml:"alerts"`
+}
+
+// TLS enables HTTPS with the certificate and key files, read again when they change. With client CAs,
+// the client certificates are verified, see certs.ParseClientAuth.
+type TLS struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	ClientAuth   string `yaml:"client_auth"`
+	MinVersion   string `yaml:"min_version"`
+}
+
+// Storage locates the transaction file, its encryption keys and the files of the tenants. The encryption
+// keys themselves are only read from TRANSACTIONDEMO_ENCRYPTION_KEYS or from the key file.
+type Storage struct {
+	File         string `yaml:"file"`
+	KeyFile      string `yaml:"key_file"`
+	TenantsDir   string `yaml:"tenants_dir"`
+	TenantQuotas string `yaml:"tenant_quotas"`
+	MaxTenants   int    `yaml:"max_tenants"`
+}
+
+type ExchangeRates struct {
+	URL      string        `yaml:"url"`
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// Timeouts are the timeouts of the HTTP server, zero means none. Shutdown, which cannot be zero, bounds the
+// whole stop: the requests in progress to complete, then the flush of the files.
+type Timeouts struct {
+	ReadHeader time.Duration `yaml:"read_header"`
+	Read       time.Duration `yaml:"read"`
+	Write      time.Duration `yaml:"write"`
+	Idle       time.Duration `yaml:"idle"`
+	Shutdown   time.Duration `yaml:"shutdown"`
+}
+
+type Auth struct {
+	APIKeysFile string `yaml:"api_keys_file"`
+	JWKS        string `yaml:"jwks"`
+	JWTIssuer   string `yaml:"jwt_issuer"`
+	JWTAudience string `yaml:"jwt_audience"`
+	RateLimits  string `yaml:"rate_limits"`
+	// InsecureNoAuth serves the API, the admin API included, to anyone when there is neither a keys file
+	// nor a JWKS. Without it, such a configuration is rejected.
+	InsecureNoAuth bool `yaml:"insecure_no_auth"`
+}
+
+type Log struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+// Tracing names the OTLP collector or the file receiving the spans, at most one of them.
+type Tracing struct {
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	File         string `yaml:"file"`
+}
+
+// Alerts sends the budget alerts to a webhook or appends them to a file, instead of logging them.
+type Alerts struct {
+	WebhookURL string        `yaml:"webhook_url"`
+	File       string        `yaml:"file"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// setting is a value of the configuration that can be given by flag and by environment. key is its path
+// in the file; a reloadable setting can change without a restart.
+type setting struct {
+	key        string
+	flag       string
+	env        string
+	usage      string
+	reloadable bool
+	field      func(c *Config) any
+}
+
+var settings = []setting{
+	{key: "listen", flag: "listen", env: "TRANSACTIONDEMO_LISTEN",
+		usage: "address to listen on",
+		field: func(c *Config) any { return &c.Listen }},
+	{key: "tls.cert_file", flag: "tls-cert", env: "TRANSACTIONDEMO_TLS_CERT_FILE",
+		usage: "certificate file, enables HTTPS",
+		field: func(c *Config) any { return &c.TLS.CertFile }},
+	{key: "tls.key_file", flag: "tls-key", env: "TRANSACTIONDEMO_TLS_KEY_FILE",
+		usage: "key file of the certificate",
+		field: func(c *Config) any { return &c.TLS.KeyFile }},
+	{key: "tls.client_ca_file", flag: "tls-client-ca", env: "TRANSACTIONDEMO_TLS_CLIENT_CA_FILE",
+		usage: "CAs verifying the client certificates, enables mutual TLS",
+		field: func(c *Config) any { return &c.TLS.ClientCAFile }},
+	{key: "tls.client_auth", flag: "tls-client-auth", env: "TRANSACTIONDEMO_TLS_CLIENT_AUTH",
+		usage: "client certificates, required or optional",
+		field: func(c *Config) any { return &c.TLS.ClientAuth }},
+	{key: "tls.min_version", flag: "tls-min-version", env: "TRANSACTIONDEMO_TLS_MIN_VERSION",
+		usage: "minimum TLS version, 1.2 or 1.3",
+		field: func(c *Config) any { return &c.TLS.MinVersion }},
+	{key: "storage.file", flag: "file", env: "TRANSACTIONDEMO_DATA_FILE",
+		usage: "transaction file",
+		field: func(c *Config) any { return &c.Storage.File }},
+	{key: "storage.key_file", flag: "key-file", env: "TRANSACTIONDEMO_KEY_FILE",
+		usage: "encryption key file",
+		field: func(c *Config) any { return &c.Storage.KeyFile }},
+	{key: "storage.tenants_dir", flag: "tenants-dir", env: "TRANSACTIONDEMO_TENANTS_DIR",
+		usage: "directory of the tenant files, next to the transaction file by default",
+		field: func(c *Config) any { return &c.Storage.TenantsDir }},
+	{key: "storage.tenant_quotas", flag: "tenant-quotas", env: "TRANSACTIONDEMO_TENANT_QUOTAS",
+		usage: "tenant quotas, e.g. \"*=1000,acme=5000\"",
+		field: func(c *Config) any { return &c.Storage.TenantQuotas }},
+	{key: "storage.max_tenants", flag: "max-tenants", env: "TRANSACTIONDEMO_MAX_TENANTS",
+		usage: "maximum number of tenants besides those of the tenant quotas, 0 for no limit",
+		field: func(c *Config) any { return &c.Storage.MaxTenants }},
+	{key: "exchange_rates.url", flag: "exchange-rate-url", env: "TRANSACTIONDEMO_EXCHANGE_RATE_URL",
+		usage: "URL of the exchange rate provider", reloadable: true,
+		field: func(c *Config) any { return &c.ExchangeRates.URL }},
+	{key: "exchange_rates.cache_ttl", flag: "cache-ttl", env: "TRANSACTIONDEMO_CACHE_TTL",
+		usage: "time the exchange rates are cached for",
+		field: func(c *Config) any { return &c.ExchangeRates.CacheTTL }},
+	{key: "exchange_rates.timeout", flag: "upstream-timeout", env: "TRANSACTIONDEMO_UPSTREAM_TIMEOUT",
+		usage: "timeout of a request to the exchange rate provider",
+		field: func(c *Config) any { return &c.ExchangeRates.Timeout }},
+	{key: "timeouts.read_header", flag: "read-header-timeout", env: "TRANSACTIONDEMO_READ_HEADER_TIMEOUT",
+		usage: "timeout to read the headers of a request",
+		field: func(c *Config) any { return &c.Timeouts.ReadHeader }},
+	{key: "timeouts.read", flag: "read-timeout", env: "TRANSACTIONDEMO_READ_TIMEOUT",
+		usage: "timeout to read a request, 0 for none",
+		field: func(c *Config) any { return &c.Timeouts.Read }},
+	{key: "timeouts.write", flag: "write-timeout", env: "TRANSACTIONDEMO_WRITE_TIMEOUT",
+		usage: "timeout to write a response, 0 for none",
+		field: func(c *Config) any { return &c.Timeouts.Write }},
+	{key: "timeouts.idle", flag: "idle-timeout", env: "TRANSACTIONDEMO_IDLE_TIMEOUT",
+		usage: "time an idle connection is kept open",
+		field: func(c *Config) any { return &c.Timeouts.Idle }},
+	{key: "timeouts.shutdown", flag: "shutdown-timeout", env: "TRANSACTIONDEMO_SHUTDOWN_TIMEOUT",
+		usage: "time left to the requests in progress and the flush of the files when the server stops",
+		field: func(c *Config) any { return &c.Timeouts.Shutdown }},
+	{key: "auth.api_keys_file", flag: "api-keys-file", env: "TRANSACTIONDEMO_API_KEYS_FILE",
+		usage: "API keys file", reloadable: true,
+		field: func(c *Config) any { return &c.Auth.APIKeysFile }},
+	{key: "auth.jwks", flag: "jwks", env: "TRANSACTIONDEMO_JWKS",
+		usage: "JWKS file or URL verifying the bearer tokens", reloadable: true,
+		field: func(c *Config) any { return &c.Auth.JWKS }},
+	{key: "auth.jwt_issuer", flag: "jwt-issuer", env: "TRANSACTIONDEMO_JWT_ISSUER",
+		usage: "required issuer of the bearer tokens", reloadable: true,
+		field: func(c *Config) any { return &c.Auth.JWTIssuer }},
+	{key: "auth.jwt_audience", flag: "jwt-audience", env: "TRANSACTIONDEMO_JWT_AUDIENCE",
+		usage: "required audience of the bearer tokens", reloadable: true,
+		field: func(c *Config) any { return &c.Auth.JWTAudience }},
+	{key: "auth.rate_limits", flag: "rate-limits", env: "TRANSACTIONDEMO_RATE_LIMITS",
+		usage: "rate limits, e.g. \"read=10:20,write=1:5\"", reloadable: true,
+		field: func(c *Config) any { return &c.Auth.RateLimits }},
+	{key: "auth.insecure_no_auth", flag: "insecure-no-auth", env: "TRANSACTIONDEMO_INSECURE_NO_AUTH",
+		usage: "serve the API without authentication when there are neither API keys nor a JWKS",
+		field: func(c *Config) any { return &c.Auth.InsecureNoAuth }},
+	{key: "log.format", flag: "log-format", env: "TRANSACTIONDEMO_LOG_FORMAT",
+		usage: "log format, text or json",
+		field: func(c *Config) any { return &c.Log.Format }},
+	{key: "log.level", flag: "log-level", env: "TRANSACTIONDEMO_LOG_LEVEL",
+		usage: "log level, debug, info, warn or error", reloadable: true,
+		field: func(c *Config) any { return &c.Log.Level }},
+	{key: "tracing.otlp_endpoint", flag: "otlp-endpoint", env: "TRANSACTIONDEMO_OTLP_ENDPOINT",
+		usage: "OpenTelemetry collector receiving the spans",
+		field: func(c *Config) any { return &c.Tracing.OTLPEndpoint }},
+	{key: "tracing.file", flag: "trace-file", env: "TRANSACTIONDEMO_TRACE_FILE",
+		usage: "file receiving the spans as JSON lines",
+		field: func(c *Config) any { return &c.Tracing.File }},
+	{key: "alerts.webhook_url", flag: "alert-webhook-url", env: "TRANSACTIONDEMO_ALERT_WEBHOOK_URL",
+		usage: "URL the budget alerts are posted to",
+		field: func(c *Config) any { return &c.Alerts.WebhookURL }},
+	{key: "alerts.file", flag: "alert-file", env: "TRANSACTIONDEMO_ALERT_FILE",
+		usage: "file receiving the budget alerts as JSON lines",
+		field: func(c *Config) any { return &c.Alerts.File }},
+	{key: "alerts.timeout", flag: "alert-timeout", env: "TRANSACTIONDEMO_ALERT_TIMEOUT",
+		usage: "timeout of a post to the alert webhook",
+		field: func(c *Config) any { return &c.Alerts.Timeout }},
+}
+
+// Default returns the configuration used for the values given neither by file, by environment nor by flag.
+func Default() Config {
+	return Config{
+		Listen: ":8080",
+		TLS: TLS{
+			MinVersion: "1.2",
+		},
+		Storage: Storage{
+			File:       "data.json",
+			MaxTenants: 100,
+		},
+		ExchangeRates: ExchangeRates{
+			URL:      types.ExchangeRateURL,
+			CacheTTL: types.DefaultCacheTTL,
+			Timeout:  types.DefaultUpstreamTimeout,
+		},
+		Timeouts: Timeouts{
+			ReadHeader: 10 * time.Second,
+			Idle:       2 * time.Minute,
+			Shutdown:   30 * time.Second,
+		},
+		Log: Log{
+			Format: logging.FormatText,
+			Level:  "info",
+		},
+		Alerts: Alerts{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Load returns the validated configuration. The file, named by the -config flag or by FileEnv, overrides the
+// defaults, the environment overrides the file and the flags override the environment. A -h flag returns
+// flag.ErrHelp once the usage is printed to output.
+func Load(name string, args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	var (
+		c     = Default()
+		flags = make(map[string]string)
+		fs    = flag.NewFlagSet(name, flag.ContinueOnError)
+	)
+
+	fs.SetOutput(output)
+	path := fs.String("config", "", "YAML configuration file, .yaml or .yml ($"+FileEnv+")")
+	for _, s := range settings {
+		s := s
+		usage := fmt.Sprintf("%s ($%s)", s.usage, s.env)
+		parse := func(v string) error {
+			// the flags are applied once the file and the environment are, the value is only checked here
+			flags[s.flag] = v
+			return set(s.field(&Config{}), v)
+		}
+		if _, ok := s.field(&Config{}).(*bool); ok {
+			fs.BoolFunc(s.flag, usage, parse)
+		} else {
+			fs.Func(s.flag, usage, parse)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *path == "" {
+		*path = getenv(FileEnv)
+	}
+	if *path != "" {
+		if err := ReadFile(*path, &c); err != nil {
+			return c, err
+		}
+	}
+
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := set(s.field(&c), v); err != nil {
+				return c, fmt.Errorf("$%s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := flags[s.flag]; ok {
+			_ = set(s.field(&c), v)
+		}
+	}
+
+	return c, c.Validate()
+}
+
+// ReadFile reads the YAML file into c, overriding the values it gives. Unknown keys are rejected, they are
+// most likely misspelled. YAML is the only format: a file whose extension is neither .yaml nor .yml, e.g. a
+// TOML or JSON file, is rejected before it is read.
+func ReadFile(path string, c *Config) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("%s: unsupported config format %q, expected a YAML file ending in .yaml or .yml", path, ext)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err = dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+func set(field any, v string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = v
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*f = d
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*f = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*f = b
+	default:
+		panic(fmt.Sprintf("config: unsupported field type %T", field))
+	}
+
+	return nil
+}
+
+// Validate checks every value of the configuration and reports all the invalid ones.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", key, err))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		invalid("listen", err)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		invalid("tls", errors.New("cert_file and key_file go together"))
+	}
+	if c.TLS.CertFile == "" && c.TLS.ClientCAFile != "" {
+		invalid("tls.client_ca_file", errors.New("requires cert_file"))
+	}
+	for _, f := range []struct{ key, path string }{
+		{"tls.cert_file", c.TLS.CertFile},
+		{"tls.key_file", c.TLS.KeyFile},
+		{"tls.client_ca_file", c.TLS.ClientCAFile},
+	} {
+		if err := readable(f.path); err != nil {
+			invalid(f.key, err)
+		}
+	}
+	if _, err := certs.ParseClientAuth(c.TLS.ClientAuth, c.TLS.ClientCAFile != ""); err != nil {
+		invalid("tls.client_auth", err)
+	}
+	if _, err := certs.ParseVersion(c.TLS.MinVersion); err != nil {
+		invalid("tls.min_version", err)
+	}
+
+	if c.Storage.File == "" {
+		invalid("storage.file", errors.New("is required"))
+	}
+	if err := readable(c.Storage.KeyFile); err != nil {
+		invalid("storage.key_file", err)
+	}
+	if _, _, err := tenant.ParseQuotas(c.Storage.TenantQuotas); err != nil {
+		invalid("storage.tenant_quotas", err)
+	}
+	if c.Storage.MaxTenants < 0 {
+		invalid("storage.max_tenants", errors.New("must not be negative"))
+	}
+
+	if err := checkURL(c.ExchangeRates.URL); err != nil {
+		invalid("exchange_rates.url", err)
+	}
+	if c.ExchangeRates.CacheTTL <= 0 {
+		invalid("exchange_rates.cache_ttl", errors.New("must be positive"))
+	}
+	if c.ExchangeRates.Timeout <= 0 {
+		invalid("exchange_rates.timeout", errors.New("must be positive"))
+	}
+
+	for _, t := range []struct {
+		key string
+		d   time.Duration
+	}{
+		{"timeouts.read_header", c.Timeouts.ReadHeader},
+		{"timeouts.read", c.Timeouts.Read},
+		{"timeouts.write", c.Timeouts.Write},
+		{"timeouts.idle", c.Timeouts.Idle},
+	} {
+		if t.d < 0 {
+			invalid(t.key, errors.New("must not be negative"))
+		}
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		invalid("timeouts.shutdown", errors.New("must be positive"))
+	}
+
+	if c.Auth.APIKeysFile == "" && c.Auth.JWKS == "" && !c.Auth.InsecureNoAuth {
+		invalid("auth", errors.New("api_keys_file or jwks is required, set insecure_no_auth (-insecure-no-auth) "+
+			"to serve the API without authentication"))
+	}
+	if err := readable(c.Auth.APIKeysFile); err != nil {
+		invalid("auth.api_keys_file", err)
+	}
+	if c.Auth.JWKS == "" && (c.Auth.JWTIssuer != "" || c.Auth.JWTAudience != "") {
+		invalid("auth.jwks", errors.New("is required to check the issuer or the audience of the tokens"))
+	}
+	if _, err := ratelimit.ParseConfig(c.Auth.RateLimits); err != nil {
+		invalid("auth.rate_limits", err)
+	}
+
+	if _, err := logging.New(io.Discard, c.Log.Format, 0); err != nil {
+		invalid("log.format", err)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		invalid("log.level", err)
+	}
+
+	if c.Tracing.OTLPEndpoint != "" && c.Tracing.File != "" {
+		invalid("tracing", errors.New("otlp_endpoint and file are exclusive"))
+	}
+	if c.Tracing.OTLPEndpoint != "" {
+		if err := checkURL(c.Tracing.OTLPEndpoint); err != nil {
+			invalid("tracing.otlp_endpoint", err)
+		}
+	}
+
+	if c.Alerts.WebhookURL != "" && c.Alerts.File != "" {
+		invalid("alerts", errors.New("webhook_url and file are exclusive"))
+	}
+	if c.Alerts.WebhookURL != "" {
+		if err := checkURL(c.Alerts.WebhookURL); err != nil {
+			invalid("alerts.webhook_url", err)
+		}
+	}
+	if c.Alerts.Timeout <= 0 {
+		invalid("alerts.timeout", errors.New("must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// readable checks that the optional file can be read.
+func readable(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", s)
+	}
+
+	return nil
+}
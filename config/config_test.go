@@ -0,0 +1,109 @@
+package config_test
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/config"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+listen: ":9000"
+storage:
+  file: /var/lib/demo/data.json
+exchange_rates:
+  cache_ttl: 1h
+  timeout: 5s
+log:
+  level: debug
+`), 0o600))
+
+	env := map[string]string{
+		config.FileEnv:                      path,
+		"TRANSACTIONDEMO_CACHE_TTL":         "2h",
+		"TRANSACTIONDEMO_LOG_LEVEL":         "warn",
+		"TRANSACTIONDEMO_EXCHANGE_RATE_URL": "http://rates.local/v1",
+	}
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	c, err := config.Load("demo", []string{"-log-level", "error", "-write-timeout", "1m", "-insecure-no-auth"}, getenv, io.Discard)
+	if assert.NoError(t, err) {
+		// the file overrides the defaults
+		assert.Equal(t, ":9000", c.Listen)
+		assert.Equal(t, "/var/lib/demo/data.json", c.Storage.File)
+		assert.Equal(t, 5*time.Second, c.ExchangeRates.Timeout)
+		// the environment overrides the file
+		assert.Equal(t, 2*time.Hour, c.ExchangeRates.CacheTTL)
+		assert.Equal(t, "http://rates.local/v1", c.ExchangeRates.URL)
+		// the flags override the environment
+		assert.Equal(t, "error", c.Log.Level)
+		assert.Equal(t, time.Minute, c.Timeouts.Write)
+		assert.True(t, c.Auth.InsecureNoAuth)
+		// the defaults remain
+		assert.Equal(t, config.Default().Timeouts.ReadHeader, c.Timeouts.ReadHeader)
+	}
+
+	_, err = config.Load("demo", []string{"-config", path, "-cache-ttl", "soon"}, getenv, io.Discard)
+	assert.Error(t, err)
+
+	// neither API keys nor a JWKS
+	_, err = config.Load("demo", nil, getenv, io.Discard)
+	assert.ErrorContains(t, err, "insecure_no_auth")
+
+	_, err = config.Load("demo", []string{"-h"}, getenv, io.Discard)
+	assert.ErrorIs(t, err, flag.ErrHelp)
+
+	assert.NoError(t, os.WriteFile(path, []byte("storage:\n  fiel: data.json\n"), 0o600))
+	_, err = config.Load("demo", nil, getenv, io.Discard)
+	assert.ErrorContains(t, err, "fiel")
+
+	// YAML is the only format
+	toml := filepath.Join(dir, "config.toml")
+	assert.NoError(t, os.WriteFile(toml, []byte("listen = \":9000\"\n"), 0o600))
+	_, err = config.Load("demo", []string{"-config", toml}, getenv, io.Discard)
+	assert.ErrorContains(t, err, "unsupported config format")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := config.Default()
+	c.Auth.InsecureNoAuth = true
+	assert.NoError(t, c.Validate())
+
+	c.Listen = "8080"
+	c.TLS.KeyFile = filepath.Join(os.TempDir(), "missing-key")
+	c.TLS.MinVersion = "1.1"
+	c.Storage.File = ""
+	c.ExchangeRates.URL = "ftp://rates.local"
+	c.ExchangeRates.Timeout = 0
+	c.Timeouts.Idle = -time.Second
+	c.Timeouts.Shutdown = 0
+	c.Auth.APIKeysFile = filepath.Join(os.TempDir(), "missing-keys")
+	c.Auth.JWTIssuer = "https://issuer.local"
+	c.Auth.RateLimits = "read=fast"
+	c.Log.Format = "xml"
+	c.Tracing = config.Tracing{OTLPEndpoint: "http://localhost:4318", File: "spans.jsonl"}
+	c.Alerts = config.Alerts{WebhookURL: "alerts.local", File: "alerts.jsonl"}
+
+	err := c.Validate()
+	for _, key := range []string{"listen", "tls", "tls.key_file", "tls.min_version", "storage.file", "exchange_rates.url", "exchange_rates.timeout",
+		"timeouts.idle", "timeouts.shutdown", "auth.api_keys_file", "auth.jwks", "auth.rate_limits", "log.format", "tracing",
+		"alerts", "alerts.webhook_url", "alerts.timeout"} {
+		assert.ErrorContains(t, err, key+":")
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"github.com/suyono3484/transactiondemo/types"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Prepare checks the settings of next that can change at runtime, current being the configuration in
+// force, and returns the function applying them. It must not change anything itself, so that a rejected
+// configuration leaves the current one running.
+type Prepare func(current, next Config) (apply func(), err error)
+
+// Report tells the settings a reload changed, by key, and the changed settings ignored until a restart.
+type Report struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Reloader loads the configuration again, on SIGHUP or through the admin API, and applies the settings
+// that can change at runtime.
+type Reloader struct {
+	mtx     *sync.Mutex
+	current Config
+	load    func() (Config, error)
+	prepare Prepare
+}
+
+// NewReloader returns a reloader of the current configuration, loading the new one with load.
+func NewReloader(current Config, load func() (Config, error), prepare Prepare) *Reloader {
+	return &Reloader{
+		mtx:     &sync.Mutex{},
+		current: current,
+		load:    load,
+		prepare: prepare,
+	}
+}
+
+// Current returns the configuration in force.
+func (r *Reloader) Current() Config {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.current
+}
+
+// Reload loads the configuration and applies its reloadable settings, all of them or, when one is
+// invalid, none. A rejected configuration is a types.InvalidInputError.
+func (r *Reloader) Reload() (Report, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		return Report{}, fmt.Errorf("%w: the configuration is rejected: %w", types.InvalidInputError, err)
+	}
+
+	apply, err := r.prepare(r.current, next)
+	if err != nil {
+		return Report{}, fmt.Errorf("%w: the configuration is rejected: %w", types.InvalidInputError, err)
+	}
+	apply()
+
+	report := Report{Applied: []string{}, RestartRequired: []string{}}
+	for _, s := range settings {
+		from, to := s.field(&r.current), s.field(&next)
+		if value(from) == value(to) {
+			continue
+		}
+
+		if !s.reloadable {
+			report.RestartRequired = append(report.RestartRequired, s.key)
+			continue
+		}
+
+		report.Applied = append(report.Applied, s.key)
+		_ = set(from, value(to))
+	}
+
+	return report, nil
+}
+
+func value(field any) string {
+	switch f := field.(type) {
+	case *string:
+		return *f
+	case *time.Duration:
+		return f.String()
+	case *int:
+		return strconv.Itoa(*f)
+	case *bool:
+		return strconv.FormatBool(*f)
+	default:
+		panic(fmt.Sprintf("config: unsupported field type %T", field))
+	}
+}
@@ -0,0 +1,52 @@
+package config_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/types"
+	"testing"
+)
+
+func TestReloader(t *testing.T) {
+	var (
+		next    = config.Default()
+		loadErr error
+		applied []config.Config
+	)
+
+	current := config.Default()
+	r := config.NewReloader(current, func() (config.Config, error) {
+		return next, loadErr
+	}, func(current, next config.Config) (func(), error) {
+		if next.Auth.RateLimits == "read=0:0" {
+			return nil, errors.New("no request would pass")
+		}
+		return func() {
+			applied = append(applied, next)
+		}, nil
+	})
+
+	next.Log.Level = "debug"
+	next.ExchangeRates.URL = "http://rates.local"
+	next.Listen = ":9000"
+	report, err := r.Reload()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"exchange_rates.url", "log.level"}, report.Applied)
+		assert.Equal(t, []string{"listen"}, report.RestartRequired)
+		assert.Len(t, applied, 1)
+	}
+	assert.Equal(t, "debug", r.Current().Log.Level)
+	assert.Equal(t, current.Listen, r.Current().Listen)
+
+	next.Auth.RateLimits = "read=0:0"
+	_, err = r.Reload()
+	assert.ErrorIs(t, err, types.InvalidInputError)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "", r.Current().Auth.RateLimits)
+
+	loadErr = errors.New("log.level: unknown log level")
+	_, err = r.Reload()
+	assert.ErrorIs(t, err, types.InvalidInputError)
+	assert.Len(t, applied, 1)
+}
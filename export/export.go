@@ -0,0 +1,248 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// flushEvery is the number of rows written between two flushes of the underlying writer.
+const flushEvery = 100
+
+type Config interface {
+	Transaction() types.TxI
+}
+
+type Module struct {
+	config Config
+}
+
+// flusher is implemented by writers that buffer, like http.ResponseWriter.
+type flusher interface {
+	Flush()
+}
+
+type rowWriter interface {
+	header(currencies []string) error
+	row(row record.ExportRow) error
+	flush() error
+}
+
+func New(config Config) *Module {
+	return &Module{
+		config: config,
+	}
+}
+
+// Export writes the transactions matching the filter one row at a time. Each row carries the amount
+// converted to each currency in the options, with the rate and its effective date, or the error of the
+// conversion. A rate is looked up once per currency and transaction date, see rateMemo. When ctx is done,
+// the export stops with a types.CanceledError after the last complete row.
+func (m *Module) Export(ctx context.Context, w io.Writer, opts record.ExportOptions) error {
+	var rw rowWriter
+	switch opts.Format {
+	case record.ExportCSV, "":
+		rw = newCSVWriter(w, false)
+	case record.ExportExcel:
+		rw = newCSVWriter(w, true)
+	case record.ExportJSON:
+		rw = &jsonWriter{enc: json.NewEncoder(w)}
+	default:
+		return fmt.Errorf("%w: unknown export format %q", types.InvalidInputError, opts.Format)
+	}
+
+	if err := rw.header(opts.Currencies); err != nil {
+		return err
+	}
+
+	var (
+		n     int
+		rates = make(rateMemo)
+	)
+	err := m.config.Transaction().Range(ctx, opts.Filter, func(rec record.TransactionRecord) error {
+		row := record.ExportRow{TransactionRecord: rec}
+		for _, currency := range opts.Currencies {
+			c, err := m.convert(ctx, rates, rec, currency)
+			if err != nil {
+				return err
+			}
+			row.Conversions = append(row.Conversions, c)
+		}
+
+		if err := rw.row(row); err != nil {
+			return err
+		}
+
+		if n++; n%flushEvery == 0 {
+			return flush(w, rw)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush(w, rw)
+}
+
+// rateKey is the rate of a currency for the transactions of a date, the rates of one date are the same for
+// every transaction.
+type rateKey struct {
+	currency string
+	date     record.FiscalDate
+}
+
+// rateMemo holds the conversions of one export by currency and date, the failed ones included, so that the
+// rate provider is asked once per currency and date at most. A failure of the rate provider itself is held
+// under the currency alone, with a zero date: the rest of the export does not ask it again for the currency.
+type rateMemo map[rateKey]record.Conversion
+
+// convert converts the transaction to the currency. A failed conversion is reported in the row, only a
+// cancellation fails the export.
+func (m *Module) convert(ctx context.Context, rates rateMemo, rec record.TransactionRecord, currency string) (record.Conversion, error) {
+	k := rateKey{currency: currency, date: rec.Date}
+	c, ok := rates[rateKey{currency: currency}]
+	if !ok {
+		c, ok = rates[k]
+	}
+	if !ok {
+		c = record.Conversion{Currency: currency}
+
+		outRec, err := m.config.Transaction().Get(ctx, rec.ID, currency)
+		if errors.Is(err, types.CanceledError) {
+			return c, err
+		}
+		if err != nil {
+			c.Error = err.Error()
+			if !errors.Is(err, types.TargetCurrencyUnavailable) && !errors.Is(err, types.RecordNotFound) {
+				rates[rateKey{currency: currency}] = c
+			}
+		} else {
+			c.Rate = outRec.Rate
+			c.EffectiveDate = outRec.EffectiveDate
+		}
+		rates[k] = c
+	}
+
+	if c.Error == "" {
+		// rounded as TxI.Get does
+		c.Converted = math.Round(rec.Amount*c.Rate*100) / 100
+	}
+	return c, nil
+}
+
+func flush(w io.Writer, rw rowWriter) error {
+	if err := rw.flush(); err != nil {
+		return err
+	}
+
+	if f, ok := w.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonWriter) header([]string) error {
+	return nil
+}
+
+func (j *jsonWriter) row(row record.ExportRow) error {
+	return j.enc.Encode(&row)
+}
+
+func (j *jsonWriter) flush() error {
+	return nil
+}
+
+// csvWriter writes one line per transaction. The excel flavour starts with a byte order mark, ends lines
+// with CRLF and keeps spreadsheets from evaluating text cells as formulas.
+type csvWriter struct {
+	w     *csv.Writer
+	excel bool
+}
+
+func newCSVWriter(w io.Writer, excel bool) *csvWriter {
+	c := &csvWriter{
+		w:     csv.NewWriter(w),
+		excel: excel,
+	}
+	c.w.UseCRLF = excel
+
+	return c
+}
+
+func (c *csvWriter) header(currencies []string) error {
+	fields := []string{"id", "date", "description", "amount", "categories", "tags"}
+	if c.excel {
+		fields[0] = "\ufeff" + fields[0]
+	}
+
+	for _, currency := range currencies {
+		fields = append(fields, currency+" rate", currency+" effective date", currency+" amount", currency+" error")
+	}
+
+	return c.w.Write(fields)
+}
+
+func (c *csvWriter) row(row record.ExportRow) error {
+	var categories, tags []string
+	for _, line := range row.Lines {
+		if line.Category != "" {
+			categories = append(categories, line.Category)
+		}
+		tags = append(tags, line.Tags...)
+	}
+
+	fields := []string{
+		row.ID,
+		row.Date.Date().Format(record.FiscalDateFormat),
+		c.text(row.Description),
+		strconv.FormatFloat(row.Amount, 'f', 2, 64),
+		c.text(strings.Join(categories, ",")),
+		c.text(strings.Join(tags, ",")),
+	}
+
+	for _, conversion := range row.Conversions {
+		if conversion.Error != "" {
+			fields = append(fields, "", "", "", c.text(conversion.Error))
+			continue
+		}
+
+		var effective string
+		if conversion.EffectiveDate != nil {
+			effective = conversion.EffectiveDate.Date().Format(record.FiscalDateFormat)
+		}
+		fields = append(fields,
+			strconv.FormatFloat(conversion.Rate, 'f', -1, 64),
+			effective,
+			strconv.FormatFloat(conversion.Converted, 'f', 2, 64),
+			"")
+	}
+
+	return c.w.Write(fields)
+}
+
+func (c *csvWriter) text(s string) string {
+	if c.excel && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
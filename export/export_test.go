@@ -0,0 +1,141 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/export"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	tx "github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newApp(t *testing.T) *transactiondemo.App {
+	app := &transactiondemo.App{
+		AppSkipFile: true,
+	}
+	repo := repoModule.New(app)
+	app.AppRepo = repo
+	transaction := tx.New(app)
+	app.AppTransaction = transaction
+
+	ctx := context.Background()
+	for _, add := range [][]string{
+		{"=cmd|' /C calc'!A0", "2023-09-02", "10"},
+		{"transaction 1", "2023-09-01", "12.15"},
+		{"transaction 3", "2023-10-01", "1"},
+	} {
+		if err := transaction.Add(ctx, add[0], add[1], add[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rateDate := time.Date(2023, time.August, 31, 0, 0, 0, 0, time.UTC)
+	repo.CacheSetExchangeRate("Canada-Dollar", record.FiscalDate(rateDate), 1.5)
+
+	return app
+}
+
+func TestModule_ExportCSV(t *testing.T) {
+	app := newApp(t)
+	buf := &bytes.Buffer{}
+
+	err := export.New(app).Export(context.Background(), buf, record.ExportOptions{
+		Format:     record.ExportExcel,
+		Currencies: []string{types.DefaultCurrency, "Canada-Dollar"},
+		Filter: record.Filter{
+			From: time.Date(2023, time.September, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2023, time.September, 30, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, strings.HasPrefix(buf.String(), "\ufeffid,"))
+	assert.Contains(t, buf.String(), "\r\n")
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, rows, 3) {
+		assert.Len(t, rows[0], 14)
+		assert.Equal(t, "transaction 1", rows[1][2])
+		assert.Equal(t, []string{"1.5", "2023-08-31", "18.23", ""}, rows[1][10:])
+		assert.Equal(t, "'=cmd|' /C calc'!A0", rows[2][2])
+	}
+}
+
+func TestModule_ExportJSON(t *testing.T) {
+	app := newApp(t)
+	buf := &bytes.Buffer{}
+
+	err := export.New(app).Export(context.Background(), buf, record.ExportOptions{
+		Format:     record.ExportJSON,
+		Currencies: []string{"Canada-Dollar"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(buf)
+	var rows []record.ExportRow
+	for dec.More() {
+		var row record.ExportRow
+		if err = dec.Decode(&row); err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "transaction 1", rows[0].Description)
+		assert.Equal(t, 18.23, rows[0].Conversions[0].Converted)
+		assert.Equal(t, "transaction 3", rows[2].Description)
+	}
+}
+
+func TestModule_ExportRateFailures(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	app := newApp(t)
+	app.SetExchangeRateURL(srv.URL)
+	buf := &bytes.Buffer{}
+
+	err := export.New(app).Export(context.Background(), buf, record.ExportOptions{
+		Currencies: []string{"Euro-Zone-Euro"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rate provider is asked once, every row tells the failure
+	assert.Equal(t, int32(1), hits.Load())
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, rows, 4) {
+		assert.Equal(t, "Euro-Zone-Euro error", rows[0][9])
+		for _, row := range rows[1:] {
+			assert.Equal(t, []string{"", "", ""}, row[6:9])
+			assert.NotEmpty(t, row[9])
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+)
+
+// authorize guards the handler of a route requiring the scope. The request must be authenticated by a key
+// having the scope; the key becomes the principal of the request. Without an authenticator, which the
+// configuration only allows with auth.insecure_no_auth, every request is let through as anonymous.
+func (h *Module) authorize(scope auth.Scope, next httprouter.Handle) httprouter.Handle {
+	if scope == "" {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		a := h.config.Authenticator()
+		if a == nil {
+			next(w, r, params)
+			return
+		}
+
+		k, err := a.Authenticate(r)
+		if err != nil {
+			if errors.Is(err, types.UnauthenticatedError) {
+				w.Header().Set("WWW-Authenticate", auth.HMACScheme)
+				w.Header().Add("WWW-Authenticate", auth.BearerScheme)
+			}
+			writeProblem(w, r, err)
+			return
+		}
+
+		if !k.Allows(scope) {
+			writeProblem(w, r, fmt.Errorf("%w: the key %s does not have the %s scope", types.ForbiddenError, k.ID, scope))
+			return
+		}
+
+		scopes := make([]string, len(k.Scopes))
+		for i := range k.Scopes {
+			scopes[i] = string(k.Scopes[i])
+		}
+
+		next(w, r.WithContext(types.WithPrincipal(r.Context(), types.Principal{
+			Name:   k.ID,
+			Source: types.SourceHTTP,
+			Scopes: scopes,
+			Tenant: k.Tenant,
+		})), params)
+	}
+}
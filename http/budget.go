@@ -0,0 +1,97 @@
+package http
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"time"
+)
+
+// AddBudgetEndpoint adds a budget from a form or, with the application/json content type, from a
+// BudgetRequest.
+func (h *Module) AddBudgetEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	req, err := decodeBudgetRequest(w, r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	var (
+		verr types.ValidationError
+		b    = record.Budget{
+			Category: req.Category,
+			Tag:      req.Tag,
+			Currency: req.Currency,
+		}
+	)
+	b.Amount = parseAmount(&verr, "amount", req.Amount)
+
+	if err = verr.Err(); err == nil {
+		b, err = h.tenant(r).Budget().Add(r.Context(), b)
+	}
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, &b)
+}
+
+// ListBudgetsEndpoint reports the status of every budget for the month in the month query parameter
+// (e.g. 2023-09), or the current month.
+func (h *Module) ListBudgetsEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	month, err := parseMonth(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	var statuses []record.BudgetStatus
+	if statuses, err = h.tenant(r).Budget().Statuses(r.Context(), month); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, statuses)
+}
+
+func (h *Module) GetBudgetEndpoint(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	month, err := parseMonth(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	var status record.BudgetStatus
+	if status, err = h.tenant(r).Budget().Status(r.Context(), params.ByName("id"), month); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, &status)
+}
+
+func (h *Module) DeleteBudgetEndpoint(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if err := h.tenant(r).Budget().Delete(r.Context(), params.ByName("id")); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseMonth(r *http.Request) (time.Time, error) {
+	v := r.URL.Query().Get("month")
+	if v == "" {
+		return time.Now(), nil
+	}
+
+	month, err := time.Parse(record.BudgetMonthFormat, v)
+	if err != nil {
+		return month, fmt.Errorf("%w: invalid month %w", types.InvalidInputError, err)
+	}
+
+	return month, nil
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ListEndpoint returns the transactions matching the from, to, category and tag query parameters.
+func (h *Module) ListEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	recs := make([]record.TransactionRecord, 0)
+	err = h.tenant(r).Transaction().Range(r.Context(), filter, func(rec record.TransactionRecord) error {
+		recs = append(recs, rec)
+		return nil
+	})
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, recs)
+}
+
+// ExportEndpoint streams the transactions matching the same filter as ListEndpoint. The format query
+// parameter is csv (default), excel or json (JSON lines); each currency parameter adds the converted amount,
+// rate and effective date to every row.
+func (h *Module) ExportEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var (
+		opts record.ExportOptions
+		err  error
+	)
+
+	if opts.Filter, err = parseFilter(r); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	v := r.URL.Query()
+	opts.Format = record.ExportFormat(v.Get("format"))
+	for _, currency := range v["currency"] {
+		for _, c := range strings.Split(currency, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				opts.Currencies = append(opts.Currencies, c)
+			}
+		}
+	}
+
+	switch opts.Format {
+	case record.ExportCSV, record.ExportExcel, "":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="transactions.csv"`)
+	case record.ExportJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="transactions.jsonl"`)
+	default:
+		writeProblem(w, r, fmt.Errorf("%w: unknown export format %q", types.InvalidInputError, opts.Format))
+		return
+	}
+
+	// the status line is out once the first row is written, a failure afterward, e.g. when the client goes
+	// away, only cuts the stream short
+	_ = h.tenant(r).Exporter().Export(r.Context(), w, opts)
+}
+
+func parseFilter(r *http.Request) (filter record.Filter, err error) {
+	v := r.URL.Query()
+	filter.Category = v.Get("category")
+	filter.Tag = v.Get("tag")
+
+	if s := v.Get("from"); s != "" {
+		if filter.From, err = time.Parse(record.FiscalDateFormat, s); err != nil {
+			err = fmt.Errorf("%w: invalid from date %w", types.InvalidInputError, err)
+			return
+		}
+	}
+
+	if s := v.Get("to"); s != "" {
+		if filter.To, err = time.Parse(record.FiscalDateFormat, s); err != nil {
+			err = fmt.Errorf("%w: invalid to date %w", types.InvalidInputError, err)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// probeTimeout bounds the request sent to the rate provider by a readiness check.
+	probeTimeout = 2 * time.Second
+	// probeTTL is how long the outcome of a probe answers the readiness checks, so that the unauthenticated
+	// readiness endpoint cannot relay a request to the rate provider for each of its own.
+	probeTTL = 10 * time.Second
+)
+
+// rateProbe is the outcome of the last probe of the rate provider.
+type rateProbe struct {
+	mtx   sync.Mutex
+	check Check
+	at    time.Time
+}
+
+// Check is the outcome of a readiness check.
+type Check struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type Readiness struct {
+	Ready  bool    `json:"ready"`
+	Checks []Check `json:"checks"`
+}
+
+// Status is the detailed status of the application.
+type Status struct {
+	Version       string                `json:"version"`
+	Readiness     Readiness             `json:"readiness"`
+	DataFile      record.DataFileStatus `json:"data_file"`
+	Records       int                   `json:"records"`
+	ExchangeRates record.RateStatus     `json:"exchange_rates"`
+}
+
+// HealthzEndpoint answers as long as the process serves requests.
+func (h *Module) HealthzEndpoint(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// ReadyzEndpoint answers 200 when the application can serve the API: the transactions are loaded, the data
+// file is writable and the exchange rates are cached or the rate provider is reachable. It answers 503
+// otherwise, the body tells which check failed.
+func (h *Module) ReadyzEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	readiness := h.readiness(r.Context())
+
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSONResponse(w, status, &readiness)
+}
+
+// StatusEndpoint reports the version, the data file, the transactions, the exchange rates and the
+// readiness checks.
+func (h *Module) StatusEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var (
+		s   = Status{Version: h.config.Version()}
+		err error
+	)
+
+	if s.Records, err = h.config.Transaction().Count(r.Context()); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	if s.DataFile, err = h.config.Repo().DataFileStatus(); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	s.Readiness = h.readiness(r.Context())
+	s.ExchangeRates = h.config.Repo().RateStatus()
+	writeJSONResponse(w, http.StatusOK, &s)
+}
+
+func (h *Module) readiness(ctx context.Context) Readiness {
+	checks := []Check{
+		{Name: "transactions", OK: h.config.Transaction().Loaded()},
+		newCheck("data_file", h.config.Repo().CheckWritable()),
+		h.checkExchangeRates(ctx),
+	}
+	if !checks[0].OK {
+		checks[0].Error = "the transactions are not loaded yet"
+	}
+
+	readiness := Readiness{Ready: true, Checks: checks}
+	for _, c := range checks {
+		readiness.Ready = readiness.Ready && c.OK
+	}
+
+	return readiness
+}
+
+// checkExchangeRates passes when the cache is warm or the last request to the rate provider succeeded.
+// Otherwise, the rate provider is probed, at most once per probeTTL; the checks in the meantime get the
+// outcome of the last probe.
+func (h *Module) checkExchangeRates(ctx context.Context) Check {
+	const name = "exchange_rates"
+
+	s := h.config.Repo().RateStatus()
+	if s.CacheWarm || s.Reachable() {
+		return Check{Name: name, OK: true}
+	}
+
+	// the concurrent checks wait for the probe in progress instead of sending their own
+	h.probe.mtx.Lock()
+	defer h.probe.mtx.Unlock()
+
+	if !h.probe.at.IsZero() && time.Since(h.probe.at) < probeTTL {
+		return h.probe.check
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	check := newCheck(name, h.config.Repo().ProbeRateProvider(probeCtx))
+	// a probe cut short by the caller says nothing about the rate provider
+	if ctx.Err() == nil {
+		h.probe.check, h.probe.at = check, time.Now()
+	}
+
+	return check
+}
+
+func newCheck(name string, err error) Check {
+	if err != nil {
+		return Check{Name: name, Error: err.Error()}
+	}
+
+	return Check{Name: name, OK: true}
+}
@@ -1,23 +1,48 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/metrics"
+	"github.com/suyono3484/transactiondemo/ratelimit"
+	"github.com/suyono3484/transactiondemo/tracing"
+	"github.com/suyono3484/transactiondemo/transaction/record"
 	"github.com/suyono3484/transactiondemo/types"
+	"net"
 	"net/http"
+	"sync"
 )
 
 type Config interface {
+	Repo() types.RepoI
 	Transaction() types.TxI
+	Scheduler() types.ScheduleI
+	Budget() types.BudgetI
+	Importer() types.ImporterI
+	Exporter() types.ExporterI
+	Tenant(name string) (types.TenantI, error)
+	Authenticator() *auth.Authenticator
+	RateLimiter() *ratelimit.Limiter
+	Metrics() *metrics.Registry
+	Tracer() *tracing.Tracer
+	Logger() *logging.Logger
+	Version() string
+	Reloader() *config.Reloader
 }
 
 type Module struct {
 	config Config
-}
 
-type ErrorMessage struct {
-	Error string `json:"error"`
+	openAPIOnce sync.Once
+	openAPI     []byte
+	openAPIErr  error
+
+	probe rateProbe
 }
 
 func New(config Config) *Module {
@@ -26,30 +51,69 @@ func New(config Config) *Module {
 	}
 }
 
+// Router serves the routes of Routes, each under its path and its aliases, behind the rate limit of the
+// client IP, the scope check, the rate limit of the client and the tenant resolution. The requests of each
+// path are measured when metrics are enabled, traced when a tracer is configured, and logged.
 func (h *Module) Router() http.Handler {
 	router := httprouter.New()
+	m := newHTTPMetrics(h.config.Metrics())
+
+	for _, route := range h.Routes() {
+		handle := h.limitIP(h.authorize(route.Scope, h.limit(route.Method, h.withTenant(route.Handle))))
+		router.Handle(route.Method, route.Path, m.instrument(route.Method, route.Path,
+			h.trace(route.Method, route.Path, h.logRequests(route.Method, route.Path, handle))))
+		for _, alias := range route.Aliases {
+			router.Handle(route.Method, alias, m.instrument(route.Method, alias,
+				h.trace(route.Method, alias, h.logRequests(route.Method, alias, handle))))
+		}
+	}
+	router.PanicHandler = func(w http.ResponseWriter, r *http.Request, v any) {
+		writeProblem(w, r, fmt.Errorf("%w: panic: %v", types.ServerError, v))
+	}
 
-	router.POST("/add", h.AddEndpoint)
-	router.GET("/get/:id", h.GetEndpoint)
-
-	return router
+	return h.withRequestID(router)
 }
 
+// AddEndpoint adds a transaction from a form or, with the application/json content type, from an
+// AddRequest.
 func (h *Module) AddEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	description := r.PostFormValue("description")
-	date := r.PostFormValue("date")
-	amount := r.PostFormValue("amount")
+	if _, _, err := h.addTransaction(w, r); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+}
 
-	err := h.config.Transaction().Add(description, date, amount)
+// CreateTransactionEndpoint adds a transaction like AddEndpoint and responds with the transaction and its
+// location. When the same transaction exists already, it responds with 200 and the stored transaction.
+func (h *Module) CreateTransactionEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	rec, created, err := h.addTransaction(w, r)
 	if err != nil {
-		if errors.Is(err, types.InvalidInputError) {
-			w.WriteHeader(http.StatusBadRequest)
-			writeErrorResponse(w, err.Error())
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, err)
 		return
 	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Location", "/v1/transactions/"+rec.ID)
+	writeJSONResponse(w, status, &rec)
+}
+
+func (h *Module) addTransaction(w http.ResponseWriter, r *http.Request) (rec record.TransactionRecord, created bool, err error) {
+	var req AddRequest
+	if req, err = decodeAddRequest(w, r); err != nil {
+		return
+	}
+
+	var verr types.ValidationError
+	lines := lineItems(&verr, req.Lines)
+	if err = verr.Err(); err != nil {
+		return
+	}
+
+	return h.tenant(r).Transaction().Create(requestContext(r), req.Description, req.Date, string(req.Amount), lines...)
 }
 
 func (h *Module) GetEndpoint(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
@@ -62,47 +126,51 @@ func (h *Module) GetEndpoint(w http.ResponseWriter, r *http.Request, params http
 		target = types.DefaultCurrency
 	}
 
-	outRec, err := h.config.Transaction().Get(params.ByName("id"), target)
+	outRec, err := h.tenant(r).Transaction().Get(r.Context(), params.ByName("id"), target)
 	if err != nil {
-		if errors.Is(err, types.RecordNotFound) {
-			w.WriteHeader(http.StatusNotFound)
-			writeErrorResponse(w, types.RecordNotFound.Error())
-			return
-		}
-
-		if errors.Is(err, types.TargetCurrencyUnavailable) {
-			w.WriteHeader(http.StatusInternalServerError)
-			writeErrorResponse(w, "the transaction cannot be converted to the target currency")
-			return
-		}
-
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, err)
 		return
 	}
 
-	var b []byte
-	if b, err = json.Marshal(&outRec); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	writeJSONResponse(w, http.StatusOK, &outRec)
+}
+
+func (h *Module) HistoryEndpoint(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	events, err := h.tenant(r).Transaction().History(r.Context(), params.ByName("id"))
+	if err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write(b)
+	writeJSONResponse(w, http.StatusOK, events)
 }
 
-func writeErrorResponse(w http.ResponseWriter, message string) {
-	var (
-		b   []byte
-		err error
-	)
+// requestContext returns the context of the request carrying the caller as the principal, anonymous when
+// the request is not authenticated.
+func requestContext(r *http.Request) context.Context {
+	p := types.PrincipalFrom(r.Context())
+	p.Source = types.SourceHTTP
+	p.ClientIP = clientIP(r)
+	return types.WithPrincipal(r.Context(), p)
+}
+
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
 
-	if b, err = json.Marshal(&ErrorMessage{
-		Error: message,
-	}); err != nil {
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	_, _ = w.Write(b)
 }
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+const maxImportSize = 32 << 20
+
+var errMultipartStatement = fmt.Errorf("%w: expecting a multipart form with the statement in the file field",
+	types.InvalidInputError)
+
+// ImportEndpoint imports the statement in the multipart field file. The optional fields are format
+// (csv, ofx, qif), dry_run, and for CSV date_column, description_column, amount_column, date_format,
+// delimiter, decimal_comma and no_header. The problem of an import stopped halfway carries its report.
+func (h *Module) ImportEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			writeProblem(w, r, fmt.Errorf("%w: a statement cannot exceed %d bytes", types.TooLargeError, sizeErr.Limit))
+			return
+		}
+		writeProblem(w, r, errMultipartStatement)
+		return
+	}
+
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		writeProblem(w, r, errMultipartStatement)
+		return
+	}
+	defer func(f multipart.File) {
+		_ = f.Close()
+	}(f)
+
+	opts := record.ImportOptions{
+		Name:   fh.Filename,
+		Format: record.ImportFormat(r.FormValue("format")),
+		Mapping: record.CSVMapping{
+			Date:        r.FormValue("date_column"),
+			Description: r.FormValue("description_column"),
+			Amount:      r.FormValue("amount_column"),
+			DateFormat:  r.FormValue("date_format"),
+		},
+	}
+	opts.DryRun, _ = strconv.ParseBool(r.FormValue("dry_run"))
+	opts.Mapping.DecimalComma, _ = strconv.ParseBool(r.FormValue("decimal_comma"))
+	opts.Mapping.NoHeader, _ = strconv.ParseBool(r.FormValue("no_header"))
+	if d := r.FormValue("delimiter"); d != "" {
+		opts.Mapping.Delimiter, _ = utf8.DecodeRuneInString(d)
+	}
+
+	var report record.ImportReport
+	if report, err = h.tenant(r).Importer().Import(requestContext(r), f, opts); err != nil {
+		p := ProblemFor(err)
+		// the entries imported before the error stay imported, the client is told which ones
+		if report.Imported > 0 {
+			p.Import = &report
+		}
+		writeProblemDetails(w, r, err, p)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, &report)
+}
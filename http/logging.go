@@ -0,0 +1,96 @@
+package http
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/tracing"
+	"github.com/suyono3484/transactiondemo/types"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LogLevel is the body of the log level endpoints.
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// logger returns the logger of the application, or the default logger when none is configured.
+func (h *Module) logger() *slog.Logger {
+	if l := h.config.Logger(); l != nil {
+		return l.Logger
+	}
+
+	return slog.Default()
+}
+
+// logRequests logs each request of the route once it is served. The handlers and the modules they call log
+// through the logger of the request context, which carries the request ID and, when the request is traced,
+// the trace ID.
+func (h *Module) logRequests(method, route string, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := logging.FromContext(r.Context()).With("method", method, "route", route)
+		if sc := tracing.SpanFromContext(r.Context()).SpanContext(); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID.String())
+		}
+
+		var (
+			begin     = time.Now()
+			rec       = &statusRecorder{ResponseWriter: w}
+			completed bool
+		)
+		defer func() {
+			status := rec.status
+			switch {
+			case !completed:
+				status = http.StatusInternalServerError
+			case status == 0:
+				status = http.StatusOK
+			}
+			logger.Info("request served", "path", r.URL.Path, "status", status,
+				"duration", time.Since(begin), "client_ip", clientIP(r))
+		}()
+
+		next(rec, r.WithContext(logging.WithLogger(r.Context(), logger)), params)
+		completed = true
+	}
+}
+
+// GetLogLevelEndpoint returns the level of the log.
+func (h *Module) GetLogLevelEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	l := h.config.Logger()
+	if l == nil {
+		writeProblem(w, r, fmt.Errorf("%w: the log is not configured", types.RecordNotFound))
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, &LogLevel{Level: l.Level().String()})
+}
+
+// SetLogLevelEndpoint changes the level of the log, e.g. to debug while investigating an issue.
+func (h *Module) SetLogLevelEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	l := h.config.Logger()
+	if l == nil {
+		writeProblem(w, r, fmt.Errorf("%w: the log is not configured", types.RecordNotFound))
+		return
+	}
+
+	var req LogLevel
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	level, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("%w: %w", types.InvalidInputError, err))
+		return
+	}
+
+	if level != l.Level() {
+		logging.FromContext(r.Context()).Warn("log level changed", "from", l.Level().String(), "to", level.String())
+		l.SetLevel(level)
+	}
+	writeJSONResponse(w, http.StatusOK, &LogLevel{Level: l.Level().String()})
+}
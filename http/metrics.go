@@ -0,0 +1,98 @@
+package http
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/metrics"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// httpMetrics are the metrics of the requests, labelled by method, route pattern and status.
+type httpMetrics struct {
+	requests *metrics.Counter
+	duration *metrics.Histogram
+}
+
+func newHTTPMetrics(reg *metrics.Registry) httpMetrics {
+	return httpMetrics{
+		requests: reg.Counter("transactiondemo_http_requests_total",
+			"HTTP requests served.", "method", "route", "status"),
+		duration: reg.Histogram("transactiondemo_http_request_duration_seconds",
+			"Duration of the HTTP requests.", nil, "method", "route", "status"),
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush lets the streamed responses, e.g. the exports, through.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrument counts and times the requests of the route. A handler that panics is recorded as a 500, the
+// status the panic handler answers with.
+func (m httpMetrics) instrument(method, route string, next httprouter.Handle) httprouter.Handle {
+	if m.requests == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		var (
+			begin     = time.Now()
+			rec       = &statusRecorder{ResponseWriter: w}
+			completed bool
+		)
+
+		defer func() {
+			status := rec.status
+			switch {
+			case !completed:
+				status = http.StatusInternalServerError
+			case status == 0:
+				status = http.StatusOK
+			}
+
+			code := strconv.Itoa(status)
+			m.requests.Inc(method, route, code)
+			m.duration.Observe(time.Since(begin).Seconds(), method, route, code)
+		}()
+
+		next(rec, r, params)
+		completed = true
+	}
+}
+
+// MetricsEndpoint serves the metrics in the Prometheus text format.
+func (h *Module) MetricsEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	reg := h.config.Metrics()
+	if reg == nil {
+		writeProblem(w, r, fmt.Errorf("%w: metrics are not enabled", types.RecordNotFound))
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	_ = reg.Write(w)
+}
@@ -0,0 +1,396 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	APIVersion     = "1.0.0"
+	openAPIVersion = "3.0.3"
+)
+
+// OpenAPI generates the OpenAPI 3 document of the routes. The schemas are derived from the Go types of the
+// request and response bodies, following their JSON encoding.
+func (h *Module) OpenAPI() map[string]any {
+	var (
+		g     = schemaGenerator{schemas: make(map[string]any)}
+		paths = make(map[string]map[string]any)
+	)
+
+	problem := g.schema(reflect.TypeOf(Problem{}))
+	for _, route := range h.Routes() {
+		op := g.operation(route, problem)
+		addOperation(paths, route.Path, route.Method, op)
+
+		for _, alias := range route.Aliases {
+			legacy := make(map[string]any, len(op)+1)
+			for k, v := range op {
+				legacy[k] = v
+			}
+			legacy["operationId"] = route.OperationID + "Legacy"
+			legacy["deprecated"] = true
+			addOperation(paths, alias, route.Method, legacy)
+		}
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   "Transaction Demo API",
+			"version": APIVersion,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{
+					"type": "apiKey",
+					"in":   "header",
+					"name": auth.APIKeyHeader,
+				},
+				"bearer": map[string]any{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+				"hmac": map[string]any{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "Authorization",
+					"description": auth.HMACScheme + " KeyId=<id>, Timestamp=<unix seconds>, Signature=<hex>; " +
+						"the signature is the HMAC-SHA256 of METHOD\\nREQUEST-URI\\nTIMESTAMP\\nhex(sha256(body))",
+				},
+			},
+		},
+	}
+}
+
+// OpenAPIEndpoint serves the OpenAPI document.
+func (h *Module) OpenAPIEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	h.openAPIOnce.Do(func() {
+		h.openAPI, h.openAPIErr = json.Marshal(h.OpenAPI())
+	})
+
+	if h.openAPIErr != nil {
+		writeProblem(w, r, h.openAPIErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(h.openAPI)
+}
+
+// OpenAPIPath converts a path in the httprouter syntax to the OpenAPI syntax, e.g. /v1/transactions/:id to
+// /v1/transactions/{id}. It also returns the names of the path parameters.
+func OpenAPIPath(path string) (string, []string) {
+	var (
+		names    []string
+		segments = strings.Split(path, "/")
+	)
+
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			names = append(names, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), names
+}
+
+func addOperation(paths map[string]map[string]any, path, method string, op map[string]any) {
+	path, _ = OpenAPIPath(path)
+	if paths[path] == nil {
+		paths[path] = make(map[string]any)
+	}
+	paths[path][strings.ToLower(method)] = op
+}
+
+func (g *schemaGenerator) operation(route Route, problem map[string]any) map[string]any {
+	var (
+		params    = make([]any, 0)
+		responses = make(map[string]any)
+	)
+
+	_, names := OpenAPIPath(route.Path)
+	for _, name := range names {
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+
+	for _, q := range route.Query {
+		p := map[string]any{
+			"name":   q.Name,
+			"in":     "query",
+			"schema": parameterSchema(q),
+		}
+		if q.Description != "" {
+			p["description"] = q.Description
+		}
+		params = append(params, p)
+	}
+
+	if route.Scope != "" {
+		params = append(params, map[string]any{
+			"name": TenantHeader,
+			"in":   "header",
+			"description": "the tenant to work on; only keys with the admin scope may name another tenant " +
+				"than their own",
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+
+	for _, resp := range route.Responses {
+		r := map[string]any{"description": resp.Description}
+
+		switch {
+		case resp.Schema != nil:
+			schema := g.schema(reflect.TypeOf(resp.Schema))
+			types := resp.ContentType
+			if len(types) == 0 {
+				types = []string{"application/json"}
+			}
+
+			content := make(map[string]any)
+			for _, t := range types {
+				content[t] = map[string]any{"schema": schema}
+			}
+			r["content"] = content
+		case resp.Status >= http.StatusBadRequest:
+			r["content"] = map[string]any{ProblemContentType: map[string]any{"schema": problem}}
+		}
+
+		responses[strconv.Itoa(resp.Status)] = r
+	}
+	if route.Scope != "" {
+		for status, description := range map[int]string{
+			http.StatusUnauthorized: "missing or invalid credentials",
+			http.StatusForbidden:    "the key does not have the " + string(route.Scope) + " scope",
+		} {
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": description,
+				"content":     map[string]any{ProblemContentType: map[string]any{"schema": problem}},
+			}
+		}
+	}
+	if route.Body != nil {
+		responses[strconv.Itoa(http.StatusRequestEntityTooLarge)] = map[string]any{
+			"description": "the body is too large, or too large to check its signature",
+			"content":     map[string]any{ProblemContentType: map[string]any{"schema": problem}},
+		}
+	}
+	responses[strconv.Itoa(http.StatusTooManyRequests)] = map[string]any{
+		"description": "the client exceeded its rate limit, see Retry-After",
+		"headers": map[string]any{
+			"Retry-After": map[string]any{
+				"description": "the seconds to wait",
+				"schema":      map[string]any{"type": "integer"},
+			},
+		},
+		"content": map[string]any{ProblemContentType: map[string]any{"schema": problem}},
+	}
+	responses["default"] = map[string]any{
+		"description": "an error",
+		"content":     map[string]any{ProblemContentType: map[string]any{"schema": problem}},
+	}
+
+	op := map[string]any{
+		"operationId": route.OperationID,
+		"summary":     route.Summary,
+		"parameters":  params,
+		"responses":   responses,
+	}
+	if route.Deprecated {
+		op["deprecated"] = true
+	}
+	if route.Scope != "" {
+		op["security"] = []any{
+			map[string]any{"apiKey": []string{}},
+			map[string]any{"hmac": []string{}},
+			map[string]any{"bearer": []string{}},
+		}
+		op["x-scope"] = route.Scope
+	}
+	if route.Body != nil {
+		op["requestBody"] = g.requestBody(route.Body)
+	}
+
+	return op
+}
+
+func (g *schemaGenerator) requestBody(body *Body) map[string]any {
+	content := make(map[string]any)
+
+	if body.Schema != nil {
+		content["application/json"] = map[string]any{"schema": g.schema(reflect.TypeOf(body.Schema))}
+	}
+
+	if len(body.Form) > 0 {
+		props := make(map[string]any, len(body.Form))
+		for _, f := range body.Form {
+			props[f.Name] = parameterSchema(f)
+		}
+		if body.Multipart {
+			props["file"] = map[string]any{"type": "string", "format": "binary"}
+			content["multipart/form-data"] = map[string]any{
+				"schema": map[string]any{"type": "object", "properties": props, "required": []string{"file"}},
+			}
+		} else {
+			content["application/x-www-form-urlencoded"] = map[string]any{
+				"schema": map[string]any{"type": "object", "properties": props},
+			}
+		}
+	}
+
+	return map[string]any{"required": true, "content": content}
+}
+
+func parameterSchema(p Parameter) map[string]any {
+	s := map[string]any{"type": "string"}
+	if p.Description != "" {
+		s["description"] = p.Description
+	}
+	if p.Repeated {
+		s = map[string]any{"type": "array", "items": s}
+	}
+
+	return s
+}
+
+// schemaGenerator derives the schemas of Go types. A named struct becomes a component referenced by name.
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+var (
+	amountType       = reflect.TypeOf(Amount(""))
+	fiscalDateType   = reflect.TypeOf(record.FiscalDate{})
+	exchangeRateType = reflect.TypeOf(record.ExchangeRate(0))
+	timeType         = reflect.TypeOf(time.Time{})
+)
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch t {
+	case amountType:
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "number"},
+			map[string]any{"type": "string"},
+		}}
+	case fiscalDateType:
+		return map[string]any{"type": "string", "format": "date"}
+	case exchangeRateType:
+		return map[string]any{"type": "number"}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// registered before the fields, a recursive type refers to itself
+			g.schemas[t.Name()] = nil
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object builds the schema of a struct like encoding/json encodes it: the fields of an embedded struct are
+// promoted unless the outer struct has a field of the same name.
+func (g *schemaGenerator) object(t reflect.Type) map[string]any {
+	var (
+		props    = make(map[string]any)
+		required = make([]string, 0)
+		embedded []reflect.Type
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	for _, et := range embedded {
+		inner := g.object(et)
+		innerRequired, _ := inner["required"].([]string)
+		for name, p := range inner["properties"].(map[string]any) {
+			if _, ok := props[name]; ok {
+				continue
+			}
+
+			props[name] = p
+			for _, r := range innerRequired {
+				if r == name {
+					required = append(required, name)
+				}
+			}
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+
+	return schema
+}
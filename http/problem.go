@@ -0,0 +1,234 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	RequestIDHeader    = "X-Request-ID"
+
+	problemTypePrefix = "urn:transactiondemo:problem:"
+	maxRequestIDLen   = 128
+)
+
+// Problem is an RFC 7807 problem details response. Code is the stable machine-readable code of the error;
+// Type is derived from it. Import is the report of an import stopped by the error, telling the entries
+// imported before it.
+type Problem struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail,omitempty"`
+	Instance  string               `json:"instance,omitempty"`
+	Code      string               `json:"code"`
+	RequestID string               `json:"request_id,omitempty"`
+	Fields    []types.FieldError   `json:"fields,omitempty"`
+	Import    *record.ImportReport `json:"import,omitempty"`
+}
+
+// ProblemType describes the response to an error. When Detail is empty, the error message is the detail.
+type ProblemType struct {
+	Err    error
+	Code   string
+	Status int
+	Title  string
+	Detail string
+}
+
+// ProblemTypes maps every sentinel of types.Errors to its response. The first entry matching with errors.Is
+// wins, so the more specific errors come first. An error matching none of them is an UnknownProblem.
+var ProblemTypes = []ProblemType{
+	{
+		Err:    types.InvalidInputError,
+		Code:   "invalid_input",
+		Status: http.StatusBadRequest,
+		Title:  "The request is invalid",
+	},
+	{
+		Err:    types.UnauthenticatedError,
+		Code:   "unauthenticated",
+		Status: http.StatusUnauthorized,
+		Title:  "The request is not authenticated",
+	},
+	{
+		Err:    types.ForbiddenError,
+		Code:   "forbidden",
+		Status: http.StatusForbidden,
+		Title:  "The credentials do not grant access",
+	},
+	{
+		Err:    types.QuotaExceededError,
+		Code:   "quota_exceeded",
+		Status: http.StatusForbidden,
+		Title:  "The tenant quota is exhausted",
+	},
+	{
+		Err:    types.TooManyRequestsError,
+		Code:   "rate_limited",
+		Status: http.StatusTooManyRequests,
+		Title:  "Too many requests",
+	},
+	{
+		Err:    types.TooLargeError,
+		Code:   "too_large",
+		Status: http.StatusRequestEntityTooLarge,
+		Title:  "The request is too large",
+	},
+	{
+		Err:    types.CanceledError,
+		Code:   "canceled",
+		Status: http.StatusServiceUnavailable,
+		Title:  "The request was canceled before it completed",
+	},
+	{
+		Err:    types.RecordNotFound,
+		Code:   "not_found",
+		Status: http.StatusNotFound,
+		Title:  "The record does not exist",
+	},
+	{
+		Err:    types.TargetCurrencyUnavailable,
+		Code:   "currency_unavailable",
+		Status: http.StatusUnprocessableEntity,
+		Title:  "No exchange rate to the target currency",
+		Detail: "there is no exchange rate to the target currency within 6 months before the transaction date",
+	},
+	{
+		Err:    types.ChainBrokenError,
+		Code:   "chain_broken",
+		Status: http.StatusInternalServerError,
+		Title:  "The transaction file failed its integrity check",
+		Detail: "the hash chain of the transaction file is broken",
+	},
+	{
+		Err:    types.CacheNoDataError,
+		Code:   "rate_unavailable",
+		Status: http.StatusServiceUnavailable,
+		Title:  "The exchange rate is unavailable",
+		Detail: "the exchange rate is neither cached nor available from the rate provider",
+	},
+	{
+		Err:    types.ServerError,
+		Code:   "server_error",
+		Status: http.StatusInternalServerError,
+		Title:  "The server failed to process the request",
+		Detail: "the server failed to process the request",
+	},
+}
+
+// ValidationProblem is the response to a *types.ValidationError, which carries the failing fields.
+var ValidationProblem = ProblemType{
+	Code:   "validation_failed",
+	Status: http.StatusBadRequest,
+	Title:  "The request has invalid fields",
+}
+
+// UnknownProblem is the response to an error without a ProblemType. Its detail does not leak the error.
+var UnknownProblem = ProblemType{
+	Code:   "internal_error",
+	Status: http.StatusInternalServerError,
+	Title:  "Internal server error",
+	Detail: "an unexpected error occurred",
+}
+
+// ProblemFor builds the problem details of err, without the request specific members.
+func ProblemFor(err error) Problem {
+	var (
+		verr *types.ValidationError
+		pt   = UnknownProblem
+	)
+
+	if errors.As(err, &verr) {
+		p := ValidationProblem.problem(err)
+		p.Fields = verr.Fields
+		return p
+	}
+
+	for _, t := range ProblemTypes {
+		if errors.Is(err, t.Err) {
+			pt = t
+			break
+		}
+	}
+
+	return pt.problem(err)
+}
+
+func (t ProblemType) problem(err error) Problem {
+	p := Problem{
+		Type:   problemTypePrefix + t.Code,
+		Title:  t.Title,
+		Status: t.Status,
+		Detail: t.Detail,
+		Code:   t.Code,
+	}
+	if p.Detail == "" && err != nil {
+		p.Detail = err.Error()
+	}
+
+	return p
+}
+
+// writeProblem responds to err with its problem details. A failure of the server is logged, the client
+// gets the request ID to report it.
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblemDetails(w, r, err, ProblemFor(err))
+}
+
+// writeProblemDetails writes p, the problem of err with members set by the caller.
+func writeProblemDetails(w http.ResponseWriter, r *http.Request, err error, p Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = requestID(r.Context())
+	if p.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("request failed", "code", p.Code, "error", err)
+	}
+
+	b, merr := json.Marshal(&p)
+	if merr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(b)
+}
+
+type requestIDKey struct{}
+
+// withRequestID takes the request ID from the X-Request-ID header, or generates one, and passes it to the
+// handler in the request context and back to the client in the response header. The request context also
+// carries a logger with the request ID, see logging.FromContext.
+func (h *Module) withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logging.WithLogger(ctx, h.logger().With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
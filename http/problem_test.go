@@ -0,0 +1,45 @@
+package http_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	hm "github.com/suyono3484/transactiondemo/http"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"testing"
+)
+
+func TestProblemFor(t *testing.T) {
+	codes := make(map[string]error)
+
+	for _, sentinel := range types.Errors {
+		t.Run(sentinel.Error(), func(t *testing.T) {
+			p := hm.ProblemFor(fmt.Errorf("wrapped: %w", sentinel))
+			assert.NotEqual(t, hm.UnknownProblem.Code, p.Code, "the sentinel has no problem type")
+			assert.NotEmpty(t, p.Title)
+			assert.NotEmpty(t, p.Detail)
+			assert.Equal(t, "urn:transactiondemo:problem:"+p.Code, p.Type)
+
+			if other, ok := codes[p.Code]; ok {
+				t.Errorf("%q shares the code %s with %q", sentinel, p.Code, other)
+			}
+			codes[p.Code] = sentinel
+		})
+	}
+
+	verr := types.ValidationError{}
+	verr.Add("amount", "is not a number")
+	p := hm.ProblemFor(fmt.Errorf("wrapped: %w", verr.Err()))
+	assert.Equal(t, hm.ValidationProblem.Code, p.Code)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, verr.Fields, p.Fields)
+
+	p = hm.ProblemFor(errors.New("disk on fire"))
+	assert.Equal(t, hm.UnknownProblem.Code, p.Code)
+	assert.Equal(t, http.StatusInternalServerError, p.Status)
+	assert.NotContains(t, p.Detail, "disk on fire")
+
+	p = hm.ProblemFor(fmt.Errorf("%w: open data.json: permission denied", types.ServerError))
+	assert.NotContains(t, p.Detail, "data.json")
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/ratelimit"
+	"github.com/suyono3484/transactiondemo/types"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// limit applies the rate limit of the class of the method to the client, the authenticated key or else the
+// client IP. Every limited response tells the state of the bucket in the RateLimit-* headers; a denied
+// request is answered with 429 and Retry-After.
+func (h *Module) limit(method string, next httprouter.Handle) httprouter.Handle {
+	class := ratelimit.Write
+	if method == http.MethodGet || method == http.MethodHead {
+		class = ratelimit.Read
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		l := h.config.RateLimiter()
+		if l == nil {
+			next(w, r, params)
+			return
+		}
+
+		client := "ip:" + clientIP(r)
+		if p := types.PrincipalFrom(r.Context()); p.Source != "" {
+			client = "key:" + p.Name
+		}
+
+		d := l.Allow(client, class)
+		if d.Limited {
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(d.Limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(d.Remaining))
+			w.Header().Set(RateLimitResetHeader, ceilSeconds(d.Reset))
+		}
+
+		if !d.Allowed {
+			w.Header().Set("Retry-After", ceilSeconds(d.RetryAfter))
+			writeProblem(w, r, fmt.Errorf("%w: the %s limit of %d requests is used, retry in %s seconds",
+				types.TooManyRequestsError, class, d.Limit, ceilSeconds(d.RetryAfter)))
+			return
+		}
+
+		next(w, r, params)
+	}
+}
+
+// limitIP applies the ip rate limit to the client IP of every request, before it is authenticated, so that
+// a flood of requests with missing or invalid credentials is limited too. The limit of the authenticated key
+// is applied later, by limit.
+func (h *Module) limitIP(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		l := h.config.RateLimiter()
+		if l == nil {
+			next(w, r, params)
+			return
+		}
+
+		if d := l.Allow("ip:"+clientIP(r), ratelimit.IP); !d.Allowed {
+			w.Header().Set("Retry-After", ceilSeconds(d.RetryAfter))
+			writeProblem(w, r, fmt.Errorf("%w: the limit of %d requests of the client IP is used, retry in %s seconds",
+				types.TooManyRequestsError, d.Limit, ceilSeconds(d.RetryAfter)))
+			return
+		}
+
+		next(w, r, params)
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// GetRateLimitsEndpoint returns the rate limits in force.
+func (h *Module) GetRateLimitsEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	l := h.config.RateLimiter()
+	if l == nil {
+		writeProblem(w, r, fmt.Errorf("%w: rate limiting is not enabled", types.RecordNotFound))
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, l.Config())
+}
+
+// SetRateLimitsEndpoint replaces the rate limits. An invalid configuration leaves the current one in
+// force.
+func (h *Module) SetRateLimitsEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	l := h.config.RateLimiter()
+	if l == nil {
+		writeProblem(w, r, fmt.Errorf("%w: rate limiting is not enabled", types.RecordNotFound))
+		return
+	}
+
+	var c ratelimit.Config
+	if err := decodeJSON(w, r, &c); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	if err := l.SetConfig(c); err != nil {
+		writeProblem(w, r, fmt.Errorf("%w: %w", types.InvalidInputError, err))
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, l.Config())
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+)
+
+// ReloadEndpoint loads the configuration again, like SIGHUP, and applies the settings that can change at
+// runtime. An invalid configuration is rejected and the current one is kept.
+func (h *Module) ReloadEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	reloader := h.config.Reloader()
+	if reloader == nil {
+		writeProblem(w, r, fmt.Errorf("%w: the configuration cannot be reloaded", types.RecordNotFound))
+		return
+	}
+
+	report, err := reloader.Reload()
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("configuration reload rejected", "error", err)
+		writeProblem(w, r, err)
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("configuration reloaded",
+		"applied", report.Applied, "restart_required", report.RestartRequired)
+	writeJSONResponse(w, http.StatusOK, &report)
+}
@@ -0,0 +1,250 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxJSONBodySize = 1 << 20
+
+// Amount is an amount in a JSON body, given either as a number or as a string.
+type Amount string
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*a = Amount(s)
+		return nil
+	}
+
+	// anything else is kept as is, so that a non-number is reported against its field by the validation
+	*a = Amount(b)
+	return nil
+}
+
+type LineItemRequest struct {
+	Description string   `json:"description,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Amount      Amount   `json:"amount"`
+}
+
+type AddRequest struct {
+	Description string            `json:"description"`
+	Date        string            `json:"date"`
+	Amount      Amount            `json:"amount"`
+	Lines       []LineItemRequest `json:"lines,omitempty"`
+}
+
+type ScheduleRequest struct {
+	Description string            `json:"description"`
+	Amount      Amount            `json:"amount"`
+	Lines       []LineItemRequest `json:"lines,omitempty"`
+	Frequency   string            `json:"frequency"`
+	Interval    int               `json:"interval,omitempty"`
+	Cron        string            `json:"cron,omitempty"`
+	Start       string            `json:"start"`
+	End         string            `json:"end,omitempty"`
+}
+
+type BudgetRequest struct {
+	Category string `json:"category,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Amount   Amount `json:"amount"`
+}
+
+func isJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeJSON reads a single JSON value from the body. Unknown fields and values of the wrong type are
+// reported as field errors, a body over maxJSONBodySize as a types.TooLargeError.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodySize)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		if dec.More() {
+			return fmt.Errorf("%w: unexpected data after the JSON body", types.InvalidInputError)
+		}
+		return nil
+	}
+
+	var (
+		verr    types.ValidationError
+		typeErr *json.UnmarshalTypeError
+		sizeErr *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &sizeErr):
+		return fmt.Errorf("%w: the JSON body cannot exceed %d bytes", types.TooLargeError, sizeErr.Limit)
+	case errors.As(err, &typeErr):
+		verr.Add(typeErr.Field, "has the wrong type")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		verr.Add(field, "is not a known field")
+	default:
+		return fmt.Errorf("%w: malformed JSON body: %w", types.InvalidInputError, err)
+	}
+
+	return verr.Err()
+}
+
+func decodeAddRequest(w http.ResponseWriter, r *http.Request) (req AddRequest, err error) {
+	if isJSON(r) {
+		err = decodeJSON(w, r, &req)
+		return
+	}
+
+	req.Description = r.PostFormValue("description")
+	req.Date = r.PostFormValue("date")
+	req.Amount = Amount(r.PostFormValue("amount"))
+	req.Lines, err = parseLineItems(r)
+	return
+}
+
+func decodeScheduleRequest(w http.ResponseWriter, r *http.Request) (req ScheduleRequest, err error) {
+	if isJSON(r) {
+		err = decodeJSON(w, r, &req)
+		return
+	}
+
+	req.Description = r.PostFormValue("description")
+	req.Amount = Amount(r.PostFormValue("amount"))
+	req.Frequency = r.PostFormValue("frequency")
+	req.Cron = r.PostFormValue("cron")
+	req.Start = r.PostFormValue("start")
+	req.End = r.PostFormValue("end")
+
+	if v := r.PostFormValue("interval"); v != "" {
+		if req.Interval, err = strconv.Atoi(v); err != nil {
+			verr := types.ValidationError{}
+			verr.Add("interval", "is not a whole number")
+			err = verr.Err()
+			return
+		}
+	}
+
+	req.Lines, err = parseLineItems(r)
+	return
+}
+
+func decodeBudgetRequest(w http.ResponseWriter, r *http.Request) (req BudgetRequest, err error) {
+	if isJSON(r) {
+		err = decodeJSON(w, r, &req)
+		return
+	}
+
+	req.Category = r.PostFormValue("category")
+	req.Tag = r.PostFormValue("tag")
+	req.Currency = r.PostFormValue("currency")
+	req.Amount = Amount(r.PostFormValue("amount"))
+	return
+}
+
+// parseLineItems reads the split of a transaction from the repeated line_amount, line_description,
+// line_category and line_tags form fields. The n-th value of each field belongs to the n-th line item, so a
+// field other than line_amount is either omitted or given once per line item, empty values included.
+// line_tags is a comma separated list.
+func parseLineItems(r *http.Request) ([]LineItemRequest, error) {
+	amounts := r.PostForm["line_amount"]
+	if len(amounts) == 0 {
+		return nil, nil
+	}
+
+	var verr types.ValidationError
+	descriptions := r.PostForm["line_description"]
+	categories := r.PostForm["line_category"]
+	tags := r.PostForm["line_tags"]
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{
+		{"line_description", descriptions},
+		{"line_category", categories},
+		{"line_tags", tags},
+	} {
+		if len(field.values) > 0 && len(field.values) != len(amounts) {
+			verr.Add(field.name, fmt.Sprintf("has %d values for %d line amounts", len(field.values), len(amounts)))
+		}
+	}
+	if err := verr.Err(); err != nil {
+		return nil, err
+	}
+
+	lines := make([]LineItemRequest, len(amounts))
+	for i, amount := range amounts {
+		lines[i].Amount = Amount(amount)
+		if i < len(descriptions) {
+			lines[i].Description = descriptions[i]
+		}
+		if i < len(categories) {
+			lines[i].Category = categories[i]
+		}
+		if i < len(tags) && tags[i] != "" {
+			lines[i].Tags = strings.Split(tags[i], ",")
+		}
+	}
+
+	return lines, nil
+}
+
+func lineItems(verr *types.ValidationError, lines []LineItemRequest) []record.LineItem {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var (
+		err   error
+		items = make([]record.LineItem, len(lines))
+	)
+	for i, line := range lines {
+		items[i] = record.LineItem{
+			Description: line.Description,
+			Category:    line.Category,
+			Tags:        line.Tags,
+		}
+		if items[i].Amount, err = strconv.ParseFloat(string(line.Amount), 64); err != nil {
+			verr.Add(fmt.Sprintf("lines[%d].amount", i), "is not a number")
+		}
+	}
+
+	return items
+}
+
+func parseAmount(verr *types.ValidationError, field string, amount Amount) float64 {
+	f, err := strconv.ParseFloat(string(amount), 64)
+	if err != nil {
+		verr.Add(field, "is not a number")
+	}
+
+	return f
+}
+
+func parseDate(verr *types.ValidationError, field, date string) record.FiscalDate {
+	t, err := time.Parse(record.FiscalDateFormat, date)
+	if err != nil {
+		verr.Add(field, fmt.Sprintf("is not a date in the %s format", record.FiscalDateFormat))
+	}
+
+	return record.FiscalDate(t)
+}
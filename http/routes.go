@@ -0,0 +1,438 @@
+package http
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/ratelimit"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"net/http"
+)
+
+// Route is an endpoint of the API. Path uses the httprouter syntax (/v1/transactions/:id); Aliases are the
+// legacy paths served by the same handler, documented as deprecated. A route with a Scope requires a key
+// having it. The remaining fields describe the endpoint in the OpenAPI document.
+type Route struct {
+	Method      string
+	Path        string
+	Aliases     []string
+	OperationID string
+	Scope       auth.Scope
+	Summary     string
+	Deprecated  bool
+	Query       []Parameter
+	Body        *Body
+	Responses   []Response
+	Handle      httprouter.Handle
+}
+
+type Parameter struct {
+	Name        string
+	Description string
+	Repeated    bool
+}
+
+// Body is a request body. Schema is a value of the Go type describing a JSON body; Form lists the fields
+// accepted instead as a form, and Multipart tells the form is multipart/form-data.
+type Body struct {
+	Schema    any
+	Form      []Parameter
+	Multipart bool
+}
+
+// Response is a response of an endpoint. A response without Schema has no body, except the error statuses
+// (4xx, 5xx) which always carry a Problem.
+type Response struct {
+	Status      int
+	Description string
+	Schema      any
+	ContentType []string
+}
+
+var (
+	monthParam = Parameter{Name: "month", Description: "the month of the status, e.g. 2023-09; defaults to the current month"}
+	filter     = []Parameter{
+		{Name: "from", Description: "the first date, e.g. 2023-09-01"},
+		{Name: "to", Description: "the last date, e.g. 2023-09-30"},
+		{Name: "category", Description: "only the transactions with a line item in the category"},
+		{Name: "tag", Description: "only the transactions with a line item having the tag"},
+	}
+	lineForm = []Parameter{
+		{Name: "line_amount", Repeated: true},
+		{Name: "line_description", Description: "none or one per line_amount", Repeated: true},
+		{Name: "line_category", Description: "none or one per line_amount", Repeated: true},
+		{Name: "line_tags", Description: "a comma separated list, none or one per line_amount", Repeated: true},
+	}
+)
+
+// Routes returns the routes of the API.
+func (h *Module) Routes() []Route {
+	return []Route{
+		{
+			Method:      http.MethodPost,
+			Path:        "/v1/transactions",
+			OperationID: "createTransaction",
+			Scope:       auth.ScopeWrite,
+			Summary:     "Add a transaction",
+			Body: &Body{
+				Schema: AddRequest{},
+				Form:   append([]Parameter{{Name: "description"}, {Name: "date"}, {Name: "amount"}}, lineForm...),
+			},
+			Responses: []Response{
+				{Status: http.StatusCreated, Description: "the transaction", Schema: record.TransactionRecord{}},
+				{Status: http.StatusOK, Description: "the transaction existed already", Schema: record.TransactionRecord{}},
+				{Status: http.StatusBadRequest, Description: "invalid input"},
+			},
+			Handle: h.CreateTransactionEndpoint,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/add",
+			OperationID: "addTransaction",
+			Scope:       auth.ScopeWrite,
+			Summary:     "Add a transaction",
+			Deprecated:  true,
+			Body: &Body{
+				Schema: AddRequest{},
+				Form:   append([]Parameter{{Name: "description"}, {Name: "date"}, {Name: "amount"}}, lineForm...),
+			},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the transaction is added"},
+				{Status: http.StatusBadRequest, Description: "invalid input"},
+			},
+			Handle: h.AddEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/transactions",
+			Aliases:     []string{"/transactions"},
+			OperationID: "listTransactions",
+			Scope:       auth.ScopeRead,
+			Summary:     "List the transactions",
+			Query:       filter,
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the transactions by date", Schema: []record.TransactionRecord{}},
+				{Status: http.StatusBadRequest, Description: "invalid filter"},
+			},
+			Handle: h.ListEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/transactions/:id",
+			Aliases:     []string{"/get/:id"},
+			OperationID: "getTransaction",
+			Scope:       auth.ScopeRead,
+			Summary:     "Get a transaction converted to a currency",
+			Query: []Parameter{
+				{Name: "target", Description: "the Treasury country-currency, e.g. Canada-Dollar; defaults to the US dollar"},
+			},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the converted transaction", Schema: record.ConvertedTransaction{}},
+				{Status: http.StatusNotFound, Description: "no such transaction"},
+				{Status: http.StatusUnprocessableEntity, Description: "no exchange rate to the target currency"},
+				{Status: http.StatusServiceUnavailable, Description: "the exchange rate is unavailable"},
+			},
+			Handle: h.GetEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/transactions/:id/history",
+			Aliases:     []string{"/transactions/:id/history"},
+			OperationID: "getTransactionHistory",
+			Scope:       auth.ScopeRead,
+			Summary:     "Get the audit events of a transaction",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the audit events, oldest first", Schema: []record.AuditEvent{}},
+				{Status: http.StatusNotFound, Description: "no such transaction"},
+			},
+			Handle: h.HistoryEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/exports",
+			Aliases:     []string{"/export"},
+			OperationID: "exportTransactions",
+			Scope:       auth.ScopeRead,
+			Summary:     "Stream the transactions",
+			Query: append([]Parameter{
+				{Name: "format", Description: "csv (default), excel or json (JSON lines)"},
+				{Name: "currency", Description: "a currency to convert to, may be repeated", Repeated: true},
+			}, filter...),
+			Responses: []Response{
+				{
+					Status:      http.StatusOK,
+					Description: "the transactions",
+					Schema:      record.ExportRow{},
+					ContentType: []string{"text/csv", "application/x-ndjson"},
+				},
+				{Status: http.StatusBadRequest, Description: "invalid filter or format"},
+			},
+			Handle: h.ExportEndpoint,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/v1/imports",
+			Aliases:     []string{"/import"},
+			OperationID: "importStatement",
+			Scope:       auth.ScopeWrite,
+			Summary:     "Import a bank statement",
+			Body: &Body{
+				Multipart: true,
+				Form: []Parameter{
+					{Name: "file", Description: "the statement"},
+					{Name: "format", Description: "csv, ofx or qif; detected when missing"},
+					{Name: "dry_run"},
+					{Name: "date_column"},
+					{Name: "description_column"},
+					{Name: "amount_column"},
+					{Name: "date_format"},
+					{Name: "delimiter"},
+					{Name: "decimal_comma"},
+					{Name: "no_header"},
+				},
+			},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the import report", Schema: record.ImportReport{}},
+				{Status: http.StatusBadRequest, Description: "invalid statement"},
+			},
+			Handle: h.ImportEndpoint,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/v1/schedules",
+			Aliases:     []string{"/schedules"},
+			OperationID: "createSchedule",
+			Scope:       auth.ScopeWrite,
+			Summary:     "Add a recurring transaction",
+			Body: &Body{
+				Schema: ScheduleRequest{},
+				Form: append([]Parameter{
+					{Name: "description"}, {Name: "amount"}, {Name: "frequency"}, {Name: "interval"}, {Name: "cron"},
+					{Name: "start"}, {Name: "end"},
+				}, lineForm...),
+			},
+			Responses: []Response{
+				{Status: http.StatusCreated, Description: "the schedule", Schema: record.Schedule{}},
+				{Status: http.StatusBadRequest, Description: "invalid input"},
+			},
+			Handle: h.AddScheduleEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/schedules",
+			Aliases:     []string{"/schedules"},
+			OperationID: "listSchedules",
+			Scope:       auth.ScopeRead,
+			Summary:     "List the recurring transactions",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the schedules", Schema: []record.Schedule{}},
+			},
+			Handle: h.ListSchedulesEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/schedules/:id",
+			Aliases:     []string{"/schedules/:id"},
+			OperationID: "getSchedule",
+			Scope:       auth.ScopeRead,
+			Summary:     "Get a recurring transaction",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the schedule", Schema: record.Schedule{}},
+				{Status: http.StatusNotFound, Description: "no such schedule"},
+			},
+			Handle: h.GetScheduleEndpoint,
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/v1/schedules/:id",
+			Aliases:     []string{"/schedules/:id"},
+			OperationID: "deleteSchedule",
+			Scope:       auth.ScopeWrite,
+			Summary:     "Delete a recurring transaction",
+			Responses: []Response{
+				{Status: http.StatusNoContent, Description: "the schedule is deleted"},
+				{Status: http.StatusNotFound, Description: "no such schedule"},
+			},
+			Handle: h.DeleteScheduleEndpoint,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/v1/budgets",
+			Aliases:     []string{"/budgets"},
+			OperationID: "createBudget",
+			Scope:       auth.ScopeWrite,
+			Summary:     "Add or replace a monthly budget",
+			Body: &Body{
+				Schema: BudgetRequest{},
+				Form:   []Parameter{{Name: "category"}, {Name: "tag"}, {Name: "currency"}, {Name: "amount"}},
+			},
+			Responses: []Response{
+				{Status: http.StatusCreated, Description: "the budget", Schema: record.Budget{}},
+				{Status: http.StatusBadRequest, Description: "invalid input"},
+			},
+			Handle: h.AddBudgetEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/budgets",
+			Aliases:     []string{"/budgets"},
+			OperationID: "listBudgets",
+			Scope:       auth.ScopeRead,
+			Summary:     "Get the status of every budget",
+			Query:       []Parameter{monthParam},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the budget statuses", Schema: []record.BudgetStatus{}},
+				{Status: http.StatusBadRequest, Description: "invalid month"},
+				{Status: http.StatusUnprocessableEntity, Description: "no exchange rate to a budget currency"},
+			},
+			Handle: h.ListBudgetsEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/budgets/:id",
+			Aliases:     []string{"/budgets/:id"},
+			OperationID: "getBudget",
+			Scope:       auth.ScopeRead,
+			Summary:     "Get the status of a budget",
+			Query:       []Parameter{monthParam},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the budget status", Schema: record.BudgetStatus{}},
+				{Status: http.StatusBadRequest, Description: "invalid month"},
+				{Status: http.StatusNotFound, Description: "no such budget"},
+				{Status: http.StatusUnprocessableEntity, Description: "no exchange rate to the budget currency"},
+			},
+			Handle: h.GetBudgetEndpoint,
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/v1/budgets/:id",
+			Aliases:     []string{"/budgets/:id"},
+			OperationID: "deleteBudget",
+			Scope:       auth.ScopeWrite,
+			Summary:     "Delete a budget",
+			Responses: []Response{
+				{Status: http.StatusNoContent, Description: "the budget is deleted"},
+				{Status: http.StatusNotFound, Description: "no such budget"},
+			},
+			Handle: h.DeleteBudgetEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/admin/rate-limits",
+			OperationID: "getRateLimits",
+			Scope:       auth.ScopeAdmin,
+			Summary:     "Get the rate limits",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the limits of each client", Schema: ratelimit.Config{}},
+				{Status: http.StatusNotFound, Description: "rate limiting is not enabled"},
+			},
+			Handle: h.GetRateLimitsEndpoint,
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        "/v1/admin/rate-limits",
+			OperationID: "setRateLimits",
+			Scope:       auth.ScopeAdmin,
+			Summary:     "Replace the rate limits",
+			Body:        &Body{Schema: ratelimit.Config{}},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the limits now in force", Schema: ratelimit.Config{}},
+				{Status: http.StatusBadRequest, Description: "invalid limits, the current ones are kept"},
+				{Status: http.StatusNotFound, Description: "rate limiting is not enabled"},
+			},
+			Handle: h.SetRateLimitsEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/admin/log-level",
+			OperationID: "getLogLevel",
+			Scope:       auth.ScopeAdmin,
+			Summary:     "Get the level of the log",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the level", Schema: LogLevel{}},
+				{Status: http.StatusNotFound, Description: "the log is not configured"},
+			},
+			Handle: h.GetLogLevelEndpoint,
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        "/v1/admin/log-level",
+			OperationID: "setLogLevel",
+			Scope:       auth.ScopeAdmin,
+			Summary:     "Change the level of the log",
+			Body:        &Body{Schema: LogLevel{}},
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the level now in force", Schema: LogLevel{}},
+				{Status: http.StatusBadRequest, Description: "unknown level, the current one is kept"},
+				{Status: http.StatusNotFound, Description: "the log is not configured"},
+			},
+			Handle: h.SetLogLevelEndpoint,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/v1/admin/reload",
+			OperationID: "reloadConfiguration",
+			Scope:       auth.ScopeAdmin,
+			Summary:     "Reload the configuration, applying the rate limits, log level, API keys and exchange rate URL",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the settings applied and those requiring a restart", Schema: config.Report{}},
+				{Status: http.StatusBadRequest, Description: "invalid configuration, the current one is kept"},
+				{Status: http.StatusNotFound, Description: "the configuration cannot be reloaded"},
+			},
+			Handle: h.ReloadEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/metrics",
+			OperationID: "getMetrics",
+			Summary:     "Get the metrics in the Prometheus text format",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the metrics", Schema: "", ContentType: []string{"text/plain"}},
+				{Status: http.StatusNotFound, Description: "metrics are not enabled"},
+			},
+			Handle: h.MetricsEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/healthz",
+			OperationID: "getHealth",
+			Summary:     "Tell the process is alive",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the process is alive"},
+			},
+			Handle: h.HealthzEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/readyz",
+			OperationID: "getReadiness",
+			Summary:     "Tell whether the application can serve the API",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "every check passed", Schema: Readiness{}},
+				{Status: http.StatusServiceUnavailable, Description: "a check failed", Schema: Readiness{}},
+			},
+			Handle: h.ReadyzEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/status",
+			OperationID: "getStatus",
+			Scope:       auth.ScopeAdmin,
+			Summary:     "Get the detailed status of the application",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the status", Schema: Status{}},
+			},
+			Handle: h.StatusEndpoint,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/v1/openapi.json",
+			OperationID: "getOpenAPI",
+			Summary:     "Get this document",
+			Responses: []Response{
+				{Status: http.StatusOK, Description: "the OpenAPI document", Schema: map[string]any{}},
+			},
+			Handle: h.OpenAPIEndpoint,
+		},
+	}
+}
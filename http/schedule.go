@@ -0,0 +1,69 @@
+package http
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+)
+
+// AddScheduleEndpoint adds a schedule from a form or, with the application/json content type, from a
+// ScheduleRequest.
+func (h *Module) AddScheduleEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	req, err := decodeScheduleRequest(w, r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	var (
+		verr types.ValidationError
+		s    = record.Schedule{
+			Description: req.Description,
+			Frequency:   record.Frequency(req.Frequency),
+			Interval:    req.Interval,
+			Cron:        req.Cron,
+		}
+	)
+	s.Amount = parseAmount(&verr, "amount", req.Amount)
+	s.Start = parseDate(&verr, "start", req.Start)
+	if req.End != "" {
+		end := parseDate(&verr, "end", req.End)
+		s.End = &end
+	}
+	s.Lines = lineItems(&verr, req.Lines)
+
+	if err = verr.Err(); err == nil {
+		s, err = h.tenant(r).Scheduler().Add(r.Context(), s)
+	}
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, &s)
+}
+
+func (h *Module) ListSchedulesEndpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	schedules := h.tenant(r).Scheduler().List()
+	writeJSONResponse(w, http.StatusOK, schedules)
+}
+
+func (h *Module) GetScheduleEndpoint(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	s, err := h.tenant(r).Scheduler().Get(params.ByName("id"))
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, &s)
+}
+
+func (h *Module) DeleteScheduleEndpoint(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if err := h.tenant(r).Scheduler().Delete(r.Context(), params.ByName("id")); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
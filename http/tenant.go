@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+)
+
+const TenantHeader = "X-Tenant-ID"
+
+type tenantKey struct{}
+
+// withTenant resolves the tenant of the request and passes its modules to the handler. The tenant is the one
+// of the authenticated key; the X-Tenant-ID header may choose another only when authentication is off or the
+// key has the admin scope. Without either, the request belongs to the default tenant.
+func (h *Module) withTenant(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		p := types.PrincipalFrom(r.Context())
+		name := p.Tenant
+
+		if requested := r.Header.Get(TenantHeader); requested != "" && requested != name {
+			if h.config.Authenticator() != nil && !hasScope(p, auth.ScopeAdmin) {
+				writeProblem(w, r, fmt.Errorf("%w: the key %s is not allowed to use tenant %q",
+					types.ForbiddenError, p.Name, requested))
+				return
+			}
+			name = requested
+		}
+
+		t, err := h.config.Tenant(name)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, t)), params)
+	}
+}
+
+// tenant returns the modules of the tenant of the request.
+func (h *Module) tenant(r *http.Request) types.TenantI {
+	if t, ok := r.Context().Value(tenantKey{}).(types.TenantI); ok {
+		return t
+	}
+
+	return h.config
+}
+
+func hasScope(p types.Principal, scope auth.Scope) bool {
+	for _, s := range p.Scopes {
+		if auth.Scope(s) == scope {
+			return true
+		}
+	}
+
+	return false
+}
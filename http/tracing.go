@@ -0,0 +1,48 @@
+package http
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/suyono3484/transactiondemo/tracing"
+	"net/http"
+)
+
+// trace starts the server span of the requests of the route, continuing the trace of the traceparent
+// header when the client sends one. The handlers start their spans from the request context.
+func (h *Module) trace(method, route string, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		t := h.config.Tracer()
+		if t == nil {
+			next(w, r, params)
+			return
+		}
+
+		remote, _ := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader))
+		ctx, span := t.StartRemote(r.Context(), method+" "+route, tracing.KindServer, remote)
+		span.SetAttribute("http.method", method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.request_id", requestID(r.Context()))
+
+		var (
+			rec       = &statusRecorder{ResponseWriter: w}
+			completed bool
+		)
+		defer func() {
+			status := rec.status
+			switch {
+			case !completed:
+				status = http.StatusInternalServerError
+			case status == 0:
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.RecordError(fmt.Errorf("%s", http.StatusText(status)))
+			}
+			span.End()
+		}()
+
+		next(rec, r.WithContext(ctx), params)
+		completed = true
+	}
+}
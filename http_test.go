@@ -1,22 +1,46 @@
 package transactiondemo_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/budget"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/export"
 	hm "github.com/suyono3484/transactiondemo/http"
+	"github.com/suyono3484/transactiondemo/importer"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/metrics"
+	"github.com/suyono3484/transactiondemo/ratelimit"
 	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/schedule"
+	"github.com/suyono3484/transactiondemo/tenant"
+	"github.com/suyono3484/transactiondemo/tracing"
 	tx "github.com/suyono3484/transactiondemo/transaction"
 	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
 	"io"
+	"log/slog"
 	"math"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	goUrl "net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,6 +60,7 @@ var _ = Describe("Run HTTP Server", func() {
 		respCode    int
 		respString  string
 		fiscals     []record.FiscalRecord
+		traceparent string
 	)
 
 	testExchange := 1.75
@@ -57,6 +82,7 @@ var _ = Describe("Run HTTP Server", func() {
 		}
 
 		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get(tracing.TraceparentHeader)
 			rc := repoModule.RecordContainer{
 				Data: fiscals,
 			}
@@ -82,10 +108,16 @@ var _ = Describe("Run HTTP Server", func() {
 			AppFilePath:        fileName,
 			AppSkipFile:        false,
 		}
+		app.AppMetrics = metrics.NewRegistry()
 		repo = repoModule.New(app)
 		app.AppRepo = repo
 		transaction = tx.New(app)
 		app.AppTransaction = transaction
+		app.AppImporter = importer.New(app)
+		app.AppExporter = export.New(app)
+		app.AppNotifier = &budget.LogNotifier{}
+		app.AppBudget = budget.New(app)
+		app.AppScheduler = schedule.New(app)
 		httpModule = hm.New(app)
 
 		as = httptest.NewServer(httpModule.Router())
@@ -104,7 +136,8 @@ var _ = Describe("Run HTTP Server", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(respCode).To(Equal(http.StatusOK))
 
-		list = transaction.List()
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
 		Expect(list).To(HaveLen(1))
 
 		outRec, respString, err = sendGetRequest(as.URL, list[0].ID, currDesc)
@@ -113,6 +146,118 @@ var _ = Describe("Run HTTP Server", func() {
 		Expect(outRec.Converted).To(Equal(math.Round(amount*testExchange*100) / 100))
 	})
 
+	It("serves the history of a transaction", func() {
+		respCode, respString, err = sendAddRequest(as.URL, "transaction 1",
+			time.Now().Format(record.FiscalDateFormat),
+			fmt.Sprintf("%f", amount))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respCode).To(Equal(http.StatusOK))
+
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(1))
+
+		resp, err := http.Get(fmt.Sprintf("%s/transactions/%s/history", as.URL, list[0].ID))
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var events []record.AuditEvent
+		Expect(json.NewDecoder(resp.Body).Decode(&events)).To(Succeed())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Action).To(Equal(record.AuditCreate))
+		Expect(events[0].ClientIP).To(Equal("127.0.0.1"))
+	})
+
+	It("imports a statement", func() {
+		statement := "date,description,amount\n2023-09-12,transaction 1,12.15\n2023-09-13,transaction 2,bad\n"
+		var report record.ImportReport
+
+		for _, dryRun := range []string{"true", "false"} {
+			body := &bytes.Buffer{}
+			mw := multipart.NewWriter(body)
+			Expect(mw.WriteField("dry_run", dryRun)).To(Succeed())
+			fw, err := mw.CreateFormFile("file", "statement.csv")
+			Expect(err).ToNot(HaveOccurred())
+			_, _ = io.WriteString(fw, statement)
+			Expect(mw.Close()).To(Succeed())
+
+			resp, err := http.Post(as.URL+"/import", mw.FormDataContentType(), body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(json.NewDecoder(resp.Body).Decode(&report)).To(Succeed())
+			_ = resp.Body.Close()
+
+			Expect(report.Imported).To(Equal(1))
+			Expect(report.Invalid).To(HaveLen(1))
+		}
+
+		Expect(transaction.List(context.Background())).To(HaveLen(1))
+
+		// an import stopped by the quota reports the entry imported before it
+		transaction.SetQuota(2)
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		fw, err := mw.CreateFormFile("file", "statement.csv")
+		Expect(err).ToNot(HaveOccurred())
+		_, _ = io.WriteString(fw, "date,description,amount\n2023-09-14,transaction 3,1\n2023-09-15,transaction 4,2\n")
+		Expect(mw.Close()).To(Succeed())
+
+		resp, err := http.Post(as.URL+"/v1/imports", mw.FormDataContentType(), body)
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		var p hm.Problem
+		Expect(json.NewDecoder(resp.Body).Decode(&p)).To(Succeed())
+		Expect(p.Code).To(Equal("quota_exceeded"))
+		Expect(p.Import).ToNot(BeNil())
+		Expect(p.Import.Rows).To(Equal(2))
+		Expect(p.Import.Imported).To(Equal(1))
+	})
+
+	It("accepts JSON bodies with numeric or string amounts", func() {
+		date := time.Now().Format(record.FiscalDateFormat)
+		respCode, respString, err = sendJSONRequest(as.URL+"/add",
+			fmt.Sprintf(`{"description":"transaction 1","date":"%s","amount":12.15}`, date))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respCode).To(Equal(http.StatusOK))
+
+		respCode, respString, err = sendJSONRequest(as.URL+"/add",
+			fmt.Sprintf(`{"description":"transaction 2","date":"%s","amount":"20",
+				"lines":[{"category":"travel","amount":15},{"category":"meals","amount":"5"}]}`, date))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respCode).To(Equal(http.StatusOK))
+
+		Expect(transaction.List(context.Background())).To(HaveLen(2))
+	})
+
+	It("returns field errors for invalid JSON bodies", func() {
+		var msg hm.Problem
+
+		for body, field := range map[string]string{
+			`{"description":"transaction 1","date":"2023-09-12","amount":1,"currency":"Euro"}`: "currency",
+			`{"description":"transaction 1","date":"2023-09-12","amount":true}`:                "amount",
+			`{"description":"transaction 1","date":"12/09/2023","amount":1}`:                   "date",
+			`{"description":"transaction 1","date":"2023-09-12","amount":1,
+				"lines":[{"amount":"x"}]}`: "lines[0].amount",
+		} {
+			respCode, respString, err = sendJSONRequest(as.URL+"/add", body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(respCode).To(Equal(http.StatusBadRequest))
+			Expect(json.Unmarshal([]byte(respString), &msg)).To(Succeed())
+			Expect(msg.Code).To(Equal(hm.ValidationProblem.Code))
+			Expect(msg.Fields).To(HaveLen(1))
+			Expect(msg.Fields[0].Field).To(Equal(field))
+		}
+
+		Expect(transaction.List(context.Background())).To(BeEmpty())
+	})
+
 	It("returns error response for invalid input when adding", func() {
 		respCode, respString, err = sendAddRequest(as.URL, "transaction 1",
 			"invalid date",
@@ -127,6 +272,674 @@ var _ = Describe("Run HTTP Server", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("serves the v1 routes", func() {
+		date := time.Now().Format(record.FiscalDateFormat)
+		resp, rerr := http.Post(as.URL+"/v1/transactions", "application/json", bytes.NewBufferString(
+			fmt.Sprintf(`{"description":"transaction 1","date":"%s","amount":12.15}`, date)))
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		var rec record.TransactionRecord
+		Expect(json.NewDecoder(resp.Body).Decode(&rec)).To(Succeed())
+		Expect(transaction.List(context.Background())).To(ConsistOf(rec))
+		Expect(resp.Header.Get("Location")).To(Equal("/v1/transactions/" + rec.ID))
+
+		// the same transaction again is not created, the stored one is returned
+		again, rerr := http.Post(as.URL+"/v1/transactions", "application/json", bytes.NewBufferString(
+			fmt.Sprintf(`{"description":"transaction 1","date":"%s","amount":12.15}`, date)))
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = again.Body.Close()
+		}()
+
+		Expect(again.StatusCode).To(Equal(http.StatusOK))
+		var stored record.TransactionRecord
+		Expect(json.NewDecoder(again.Body).Decode(&stored)).To(Succeed())
+		Expect(stored).To(Equal(rec))
+		Expect(transaction.List(context.Background())).To(HaveLen(1))
+
+		getResp, rerr := http.Get(as.URL + "/v1/transactions/" + rec.ID + "?target=" + currDesc)
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = getResp.Body.Close()
+		}()
+
+		Expect(getResp.StatusCode).To(Equal(http.StatusOK))
+		Expect(json.NewDecoder(getResp.Body).Decode(&outRec)).To(Succeed())
+		Expect(outRec.ID).To(Equal(rec.ID))
+		Expect(outRec.Converted).To(Equal(math.Round(rec.Amount*testExchange*100) / 100))
+	})
+
+	It("serves an OpenAPI document matching the handlers", func() {
+		resp, rerr := http.Get(as.URL + "/v1/openapi.json")
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var doc struct {
+			OpenAPI string `json:"openapi"`
+			Paths   map[string]map[string]struct {
+				OperationID string `json:"operationId"`
+				Responses   map[string]struct {
+					Content map[string]any `json:"content"`
+				} `json:"responses"`
+			} `json:"paths"`
+			Components struct {
+				Schemas map[string]any `json:"schemas"`
+			} `json:"components"`
+		}
+		Expect(json.NewDecoder(resp.Body).Decode(&doc)).To(Succeed())
+		Expect(doc.OpenAPI).To(HavePrefix("3."))
+		Expect(doc.Components.Schemas).To(HaveKey("Problem"))
+
+		routes := httpModule.Routes()
+		operations := 0
+		for _, route := range routes {
+			operations += 1 + len(route.Aliases)
+		}
+
+		seen := 0
+		for path, ops := range doc.Paths {
+			for method, op := range ops {
+				seen++
+
+				// every documented operation has a handler answering with a documented status and content type
+				url := as.URL + strings.NewReplacer("{id}", "unknown").Replace(path)
+				req, rerr := http.NewRequest(strings.ToUpper(method), url, bytes.NewBufferString("{}"))
+				Expect(rerr).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				r, rerr := http.DefaultClient.Do(req)
+				Expect(rerr).ToNot(HaveOccurred())
+				_ = r.Body.Close()
+
+				documented, ok := op.Responses[strconv.Itoa(r.StatusCode)]
+				Expect(ok).To(BeTrue(), "%s %s (%s) answered the undocumented status %d",
+					method, path, op.OperationID, r.StatusCode)
+
+				if len(documented.Content) > 0 {
+					ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+					Expect(documented.Content).To(HaveKey(ct), "%s %s", method, path)
+				}
+			}
+		}
+		Expect(seen).To(Equal(operations))
+	})
+
+	It("requires one line field value per line amount", func() {
+		val := goUrl.Values{
+			"description":      {"team trip"},
+			"date":             {time.Now().Format(record.FiscalDateFormat)},
+			"amount":           {"100"},
+			"line_amount":      {"60", "40"},
+			"line_description": {"train", "dinner"},
+			"line_category":    {"meals"},
+		}
+		resp, rerr := http.PostForm(as.URL+"/v1/transactions", val)
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		var p hm.Problem
+		Expect(json.NewDecoder(resp.Body).Decode(&p)).To(Succeed())
+		Expect(p.Fields).To(HaveLen(1))
+		Expect(p.Fields[0].Field).To(Equal("line_category"))
+		Expect(transaction.List(context.Background())).To(BeEmpty())
+	})
+
+	It("rejects a JSON body over the limit", func() {
+		body := fmt.Sprintf(`{"description":"%s","date":"2023-09-01","amount":1}`, strings.Repeat("x", 2<<20))
+		resp, rerr := http.Post(as.URL+"/v1/transactions", "application/json", strings.NewReader(body))
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(resp.Header.Get("Content-Type")).To(Equal(hm.ProblemContentType))
+	})
+
+	It("enforces the scopes of the API keys", func() {
+		readKey, readEntry, kerr := auth.GenerateAPIKey("reader", []auth.Scope{auth.ScopeRead})
+		Expect(kerr).ToNot(HaveOccurred())
+		secret, writeEntry, kerr := auth.GenerateHMACSecret("batch", []auth.Scope{auth.ScopeWrite})
+		Expect(kerr).ToNot(HaveOccurred())
+		app.AppAuthenticator, err = auth.Parse(readEntry + "\n" + writeEntry)
+		Expect(err).ToNot(HaveOccurred())
+
+		body := []byte(fmt.Sprintf(`{"description":"transaction 1","date":"%s","amount":12.15}`,
+			time.Now().Format(record.FiscalDateFormat)))
+		post := func(sign func(r *http.Request)) *http.Response {
+			req, rerr := http.NewRequest(http.MethodPost, as.URL+"/v1/transactions", bytes.NewReader(body))
+			Expect(rerr).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			sign(req)
+
+			resp, rerr := http.DefaultClient.Do(req)
+			Expect(rerr).ToNot(HaveOccurred())
+			_ = resp.Body.Close()
+			return resp
+		}
+
+		resp := post(func(r *http.Request) {})
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.Header.Get("Content-Type")).To(Equal(hm.ProblemContentType))
+
+		resp = post(func(r *http.Request) { r.Header.Set(auth.APIKeyHeader, readKey) })
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+		resp = post(func(r *http.Request) { auth.Sign(r, "batch", secret, body, time.Now()) })
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(1))
+		events, herr := transaction.History(context.Background(), list[0].ID)
+		Expect(herr).ToNot(HaveOccurred())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Actor).To(Equal("batch"))
+
+		req, rerr := http.NewRequest(http.MethodGet, as.URL+"/v1/transactions", nil)
+		Expect(rerr).ToNot(HaveOccurred())
+		req.Header.Set(auth.APIKeyHeader, readKey)
+		resp, rerr = http.DefaultClient.Do(req)
+		Expect(rerr).ToNot(HaveOccurred())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		resp, rerr = http.Get(as.URL + "/v1/openapi.json")
+		Expect(rerr).ToNot(HaveOccurred())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("rejects expired bearer tokens with problem details", func() {
+		secret := make([]byte, 32)
+		keys, kerr := auth.ParseKeySet([]byte(fmt.Sprintf(`{"keys":[{"kty":"oct","kid":"hs","k":"%s"}]}`,
+			base64.RawURLEncoding.EncodeToString(secret))))
+		Expect(kerr).ToNot(HaveOccurred())
+		app.AppAuthenticator = auth.New()
+		app.AppAuthenticator.SetJWTVerifier(auth.NewJWTVerifier(keys, "", ""))
+
+		get := func(exp time.Time) (*http.Response, hm.Problem) {
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"hs"}`))
+			claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(
+				`{"sub":"alice","scope":"read","exp":%d}`, exp.Unix())))
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(header + "." + claims))
+			token := header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+			req, rerr := http.NewRequest(http.MethodGet, as.URL+"/v1/transactions", nil)
+			Expect(rerr).ToNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, rerr := http.DefaultClient.Do(req)
+			Expect(rerr).ToNot(HaveOccurred())
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			var p hm.Problem
+			if resp.StatusCode != http.StatusOK {
+				Expect(json.NewDecoder(resp.Body).Decode(&p)).To(Succeed())
+			}
+			return resp, p
+		}
+
+		resp, _ := get(time.Now().Add(time.Hour))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		resp, p := get(time.Now().Add(-time.Hour))
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.Header.Get("Content-Type")).To(Equal(hm.ProblemContentType))
+		Expect(p.Code).To(Equal("unauthenticated"))
+		Expect(p.Detail).To(ContainSubstring("token expired"))
+	})
+
+	It("isolates the transactions of the tenants", func() {
+		tenants := tenant.New(filepath.Join(dir, "tenants"),
+			func(_, filePath string, quota tenant.Quota) (types.TenantI, error) {
+				t := &transactiondemo.App{AppExchangeRateURL: ts.URL, AppFilePath: filePath}
+				t.AppRepo = repo.ForTenant(t)
+				txModule := tx.New(t)
+				txModule.SetQuota(quota.MaxTransactions)
+				t.AppTransaction = txModule
+				return t, nil
+			}, tenant.Quota{MaxTransactions: 1}, nil)
+		app.AppTenants = tenants
+
+		teamKey, teamEntry, kerr := auth.GenerateAPIKey("team-a-app", []auth.Scope{auth.ScopeRead, auth.ScopeWrite})
+		Expect(kerr).ToNot(HaveOccurred())
+		defaultKey, defaultEntry, kerr := auth.GenerateAPIKey("app", []auth.Scope{auth.ScopeRead, auth.ScopeWrite})
+		Expect(kerr).ToNot(HaveOccurred())
+		adminKey, adminEntry, kerr := auth.GenerateAPIKey("ops", []auth.Scope{auth.ScopeAdmin})
+		Expect(kerr).ToNot(HaveOccurred())
+		app.AppAuthenticator, err = auth.Parse(teamEntry + " tenant:team-a\n" + defaultEntry + "\n" + adminEntry)
+		Expect(err).ToNot(HaveOccurred())
+
+		do := func(method, key, tenantName, body string) (*http.Response, []byte) {
+			req, rerr := http.NewRequest(method, as.URL+"/v1/transactions", strings.NewReader(body))
+			Expect(rerr).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(auth.APIKeyHeader, key)
+			if tenantName != "" {
+				req.Header.Set(hm.TenantHeader, tenantName)
+			}
+
+			resp, rerr := http.DefaultClient.Do(req)
+			Expect(rerr).ToNot(HaveOccurred())
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			b, rerr := io.ReadAll(resp.Body)
+			Expect(rerr).ToNot(HaveOccurred())
+			return resp, b
+		}
+		add := func(key, description string) (*http.Response, []byte) {
+			return do(http.MethodPost, key, "", fmt.Sprintf(`{"description":"%s","date":"%s","amount":12.15}`,
+				description, time.Now().Format(record.FiscalDateFormat)))
+		}
+		count := func(key, tenantName string) int {
+			resp, b := do(http.MethodGet, key, tenantName, "")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var records []record.TransactionRecord
+			Expect(json.Unmarshal(b, &records)).To(Succeed())
+			return len(records)
+		}
+
+		resp, _ := add(teamKey, "team a 1")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		resp, _ = add(defaultKey, "default 1")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		resp, _ = add(defaultKey, "default 2")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+		resp, b := add(teamKey, "team a 2")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		var p hm.Problem
+		Expect(json.Unmarshal(b, &p)).To(Succeed())
+		Expect(p.Code).To(Equal("quota_exceeded"))
+
+		Expect(count(teamKey, "")).To(Equal(1))
+		Expect(count(defaultKey, "")).To(Equal(2))
+		Expect(count(adminKey, "team-a")).To(Equal(1))
+		Expect(transaction.List(context.Background())).To(HaveLen(2))
+		Expect(filepath.Join(dir, "tenants", "team-a", tenant.DataFileName)).To(BeAnExistingFile())
+
+		resp, _ = do(http.MethodGet, defaultKey, "team-a", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		resp, _ = do(http.MethodGet, adminKey, "../team-a", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("limits the rate of each client and reloads the limits", func() {
+		app.AppRateLimiter, err = ratelimit.New(ratelimit.Config{Read: ratelimit.Limit{Rate: 0.01, Burst: 2}})
+		Expect(err).ToNot(HaveOccurred())
+
+		get := func() *http.Response {
+			resp, rerr := http.Get(as.URL + "/v1/transactions")
+			Expect(rerr).ToNot(HaveOccurred())
+			_ = resp.Body.Close()
+			return resp
+		}
+
+		resp := get()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get(hm.RateLimitLimitHeader)).To(Equal("2"))
+		Expect(resp.Header.Get(hm.RateLimitRemainingHeader)).To(Equal("1"))
+		Expect(get().StatusCode).To(Equal(http.StatusOK))
+
+		resp = get()
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(resp.Header.Get("Content-Type")).To(Equal(hm.ProblemContentType))
+		Expect(resp.Header.Get("Retry-After")).To(Equal("100"))
+		Expect(resp.Header.Get(hm.RateLimitRemainingHeader)).To(Equal("0"))
+
+		// the write routes have their own limit
+		respCode, _, err = sendJSONRequest(as.URL+"/v1/transactions", fmt.Sprintf(
+			`{"description":"transaction 1","date":"%s","amount":12.15}`, time.Now().Format(record.FiscalDateFormat)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respCode).To(Equal(http.StatusCreated))
+
+		put := func(body string) int {
+			req, rerr := http.NewRequest(http.MethodPut, as.URL+"/v1/admin/rate-limits", strings.NewReader(body))
+			Expect(rerr).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+			r, rerr := http.DefaultClient.Do(req)
+			Expect(rerr).ToNot(HaveOccurred())
+			_ = r.Body.Close()
+			return r.StatusCode
+		}
+
+		Expect(put(`{"read":{"rate":-1,"burst":1}}`)).To(Equal(http.StatusBadRequest))
+		Expect(get().StatusCode).To(Equal(http.StatusTooManyRequests))
+
+		Expect(put(`{"read":{"rate":100,"burst":100}}`)).To(Equal(http.StatusOK))
+		Expect(app.AppRateLimiter.Config().Read.Burst).To(Equal(100))
+		Eventually(get).Should(HaveField("StatusCode", http.StatusOK))
+
+		// the requests of a client IP are limited before their credentials are checked
+		Expect(put(`{"ip":{"rate":0.01,"burst":2}}`)).To(Equal(http.StatusOK))
+		_, entry, kerr := auth.GenerateAPIKey("reader", []auth.Scope{auth.ScopeRead})
+		Expect(kerr).ToNot(HaveOccurred())
+		app.AppAuthenticator, err = auth.Parse(entry)
+		Expect(err).ToNot(HaveOccurred())
+
+		invalid := func() int {
+			req, rerr := http.NewRequest(http.MethodGet, as.URL+"/v1/transactions", nil)
+			Expect(rerr).ToNot(HaveOccurred())
+			req.Header.Set(auth.APIKeyHeader, "invalid")
+			r, rerr := http.DefaultClient.Do(req)
+			Expect(rerr).ToNot(HaveOccurred())
+			_ = r.Body.Close()
+			return r.StatusCode
+		}
+		Expect(invalid()).To(Equal(http.StatusUnauthorized))
+		Expect(invalid()).To(Equal(http.StatusUnauthorized))
+		Expect(invalid()).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("exposes metrics of the requests and the exchange rates", func() {
+		// a rate effective before the transaction, so that the second conversion finds it in the cache
+		fiscals[0].EffectiveDate = record.FiscalDate(time.Now().AddDate(0, 0, -7))
+
+		respCode, respString, err = sendAddRequest(as.URL, "transaction 1",
+			time.Now().Format(record.FiscalDateFormat), strconv.FormatFloat(amount, 'f', -1, 64))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respCode).To(Equal(http.StatusOK))
+
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(1))
+		for i := 0; i < 2; i++ {
+			_, _, err = sendGetRequest(as.URL, list[0].ID, currDesc)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		_, _, err = sendGetRequest(as.URL, "unknown", currDesc)
+		Expect(err).To(HaveOccurred())
+
+		resp, rerr := http.Get(as.URL + "/metrics")
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+
+		b, rerr := io.ReadAll(resp.Body)
+		Expect(rerr).ToNot(HaveOccurred())
+		Expect(string(b)).To(SatisfyAll(
+			ContainSubstring(`transactiondemo_http_requests_total{method="POST",route="/add",status="200"} 1`),
+			ContainSubstring(`transactiondemo_http_requests_total{method="GET",route="/get/:id",status="200"} 2`),
+			ContainSubstring(`transactiondemo_http_requests_total{method="GET",route="/get/:id",status="404"} 1`),
+			ContainSubstring(`transactiondemo_http_request_duration_seconds_count{method="GET",route="/get/:id",status="200"} 2`),
+			// the first lookup misses before the provider answered for the currency
+			ContainSubstring(`transactiondemo_rate_cache_misses_total{currency="other"} 1`),
+			ContainSubstring(`transactiondemo_rate_cache_hits_total{currency="Canada-Dollar"} 1`),
+			ContainSubstring("transactiondemo_rate_fetch_duration_seconds_count 1"),
+			ContainSubstring("transactiondemo_rate_fetch_errors_total 0"),
+		))
+	})
+
+	It("traces a conversion through the transaction and repository layers", func() {
+		spanFile := filepath.Join(dir, "spans.jsonl")
+		exporter, xerr := tracing.NewFileExporter(spanFile)
+		Expect(xerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = exporter.Close()
+		}()
+		app.AppTracer = tracing.NewTracer(exporter)
+
+		respCode, _, err = sendAddRequest(as.URL, "transaction 1",
+			time.Now().Format(record.FiscalDateFormat), strconv.FormatFloat(amount, 'f', -1, 64))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respCode).To(Equal(http.StatusOK))
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(1))
+
+		req, rerr := http.NewRequest(http.MethodGet, as.URL+"/v1/transactions/"+list[0].ID+"?target="+currDesc, nil)
+		Expect(rerr).ToNot(HaveOccurred())
+		req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		resp, rerr := http.DefaultClient.Do(req)
+		Expect(rerr).ToNot(HaveOccurred())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(app.AppTracer.Shutdown(context.Background())).To(Succeed())
+
+		spans, rerr := tracing.ReadSpanFile(spanFile)
+		Expect(rerr).ToNot(HaveOccurred())
+		byName := make(map[string]tracing.SpanData)
+		for _, s := range spans {
+			if s.TraceID == "4bf92f3577b34da6a3ce929d0e0e4736" {
+				byName[s.Name] = s
+			}
+		}
+		Expect(byName).To(HaveLen(4))
+
+		server := byName["GET /v1/transactions/:id"]
+		Expect(server.ParentID).To(Equal("00f067aa0ba902b7"))
+		Expect(server.Attributes).To(HaveKeyWithValue("http.status_code", float64(http.StatusOK)))
+		Expect(byName["TxModule.Get"].ParentID).To(Equal(server.SpanID))
+		Expect(byName["TxModule.Get"].Attributes).To(HaveKey("lock.wait_seconds"))
+		Expect(byName["RepoModule.CacheGetExchangeRate"].ParentID).To(Equal(byName["TxModule.Get"].SpanID))
+		Expect(byName["RepoModule.CacheGetExchangeRate"].Attributes).To(HaveKeyWithValue("cache.hit", false))
+
+		fetch := byName["RepoModule.FetchFiscalData"]
+		Expect(fetch.ParentID).To(Equal(byName["TxModule.Get"].SpanID))
+		Expect(traceparent).To(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-" + fetch.SpanID + "-01"))
+	})
+
+	It("logs the requests and their upstream calls with the request ID", func() {
+		var logs syncBuffer
+		logger, lerr := logging.New(&logs, logging.FormatJSON, slog.LevelInfo)
+		Expect(lerr).ToNot(HaveOccurred())
+		app.AppLogger = logger
+
+		Expect(transaction.Add(context.Background(), "transaction 1",
+			time.Now().Format(record.FiscalDateFormat), strconv.FormatFloat(amount, 'f', -1, 64))).To(Succeed())
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		req, rerr := http.NewRequest(http.MethodGet, as.URL+"/v1/transactions/"+list[0].ID+"?target="+currDesc, nil)
+		Expect(rerr).ToNot(HaveOccurred())
+		req.Header.Set(hm.RequestIDHeader, "req-log")
+		resp, rerr := http.DefaultClient.Do(req)
+		Expect(rerr).ToNot(HaveOccurred())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		records := func() map[string]map[string]any {
+			byMsg := make(map[string]map[string]any)
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				var rec map[string]any
+				if json.Unmarshal([]byte(line), &rec) == nil && rec["request_id"] == "req-log" {
+					byMsg[rec["msg"].(string)] = rec
+				}
+			}
+			return byMsg
+		}
+		Eventually(records).Should(HaveKey("request served"))
+		Expect(records()["request served"]).To(HaveKeyWithValue("route", "/v1/transactions/:id"))
+		Expect(records()["request served"]).To(HaveKeyWithValue("status", float64(http.StatusOK)))
+		Expect(records()["upstream call"]).To(HaveKeyWithValue("upstream", "exchange_rates"))
+		Expect(records()["upstream call"]).To(HaveKey("duration"))
+
+		// the level is raised through the admin API
+		body := strings.NewReader(`{"level":"warn"}`)
+		req, rerr = http.NewRequest(http.MethodPut, as.URL+"/v1/admin/log-level", body)
+		Expect(rerr).ToNot(HaveOccurred())
+		resp, rerr = http.DefaultClient.Do(req)
+		Expect(rerr).ToNot(HaveOccurred())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(logger.Level()).To(Equal(slog.LevelWarn))
+
+		req, rerr = http.NewRequest(http.MethodPut, as.URL+"/v1/admin/log-level", strings.NewReader(`{"level":"loud"}`))
+		Expect(rerr).ToNot(HaveOccurred())
+		resp, rerr = http.DefaultClient.Do(req)
+		Expect(rerr).ToNot(HaveOccurred())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(logger.Level()).To(Equal(slog.LevelWarn))
+	})
+
+	It("returns problem details with the request ID", func() {
+		req, rerr := http.NewRequest(http.MethodGet, as.URL+"/get/unknown", nil)
+		Expect(rerr).ToNot(HaveOccurred())
+		req.Header.Set(hm.RequestIDHeader, "req-1")
+
+		resp, rerr := http.DefaultClient.Do(req)
+		Expect(rerr).ToNot(HaveOccurred())
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(resp.Header.Get("Content-Type")).To(Equal(hm.ProblemContentType))
+		Expect(resp.Header.Get(hm.RequestIDHeader)).To(Equal("req-1"))
+
+		var p hm.Problem
+		Expect(json.NewDecoder(resp.Body).Decode(&p)).To(Succeed())
+		Expect(p.Code).To(Equal("not_found"))
+		Expect(p.Status).To(Equal(http.StatusNotFound))
+		Expect(p.Instance).To(Equal("/get/unknown"))
+		Expect(p.RequestID).To(Equal("req-1"))
+	})
+
+	It("probes the health, the readiness and the status", func() {
+		get := func(path string, v any) int {
+			resp, rerr := http.Get(as.URL + path)
+			Expect(rerr).ToNot(HaveOccurred())
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			if v != nil {
+				Expect(json.NewDecoder(resp.Body).Decode(v)).To(Succeed())
+			}
+			return resp.StatusCode
+		}
+
+		Expect(get("/healthz", nil)).To(Equal(http.StatusOK))
+
+		var readiness hm.Readiness
+		Expect(get("/readyz", &readiness)).To(Equal(http.StatusServiceUnavailable))
+		Expect(readiness.Ready).To(BeFalse())
+		Expect(readiness.Checks).To(ContainElement(hm.Check{Name: "transactions", OK: false,
+			Error: "the transactions are not loaded yet"}))
+
+		Expect(transaction.Load(context.Background())).To(Succeed())
+		Expect(get("/readyz", &readiness)).To(Equal(http.StatusOK))
+		Expect(readiness.Checks).To(HaveLen(3))
+
+		app.AppVersion = "v1.2.3"
+		respCode, respString, err = sendAddRequest(as.URL, "transaction 1",
+			time.Now().Format(record.FiscalDateFormat),
+			fmt.Sprintf("%f", amount))
+		Expect(err).ToNot(HaveOccurred())
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = sendGetRequest(as.URL, list[0].ID, currDesc)
+		Expect(err).ToNot(HaveOccurred())
+
+		// the cached rates keep the application ready without the rate provider
+		ts.Close()
+		var status hm.Status
+		Expect(get("/status", &status)).To(Equal(http.StatusOK))
+		Expect(status.Version).To(Equal("v1.2.3"))
+		Expect(status.Records).To(Equal(1))
+		Expect(status.DataFile.Path).To(Equal(fileName))
+		Expect(status.DataFile.SizeBytes).To(BeNumerically(">", 0))
+		Expect(status.Readiness.Ready).To(BeTrue())
+		Expect(status.ExchangeRates.CacheWarm).To(BeTrue())
+		Expect(status.ExchangeRates.LastSuccess).ToNot(BeNil())
+	})
+
+	It("probes an unreachable rate provider once for the readiness checks in a row", func() {
+		var hits atomic.Int32
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer failing.Close()
+		app.SetExchangeRateURL(failing.URL)
+		Expect(transaction.Load(context.Background())).To(Succeed())
+
+		for i := 0; i < 3; i++ {
+			resp, rerr := http.Get(as.URL + "/readyz")
+			Expect(rerr).ToNot(HaveOccurred())
+			var readiness hm.Readiness
+			Expect(json.NewDecoder(resp.Body).Decode(&readiness)).To(Succeed())
+			_ = resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(readiness.Checks).To(ContainElement(HaveField("Name", "exchange_rates")))
+		}
+		Expect(hits.Load()).To(Equal(int32(1)))
+	})
+
+	It("reloads the configuration and keeps it when the new one is invalid", func() {
+		var hits atomic.Int32
+		reloaded := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := json.Marshal(&repoModule.RecordContainer{Data: fiscals})
+			_, _ = w.Write(b)
+			hits.Add(1)
+		}))
+		defer reloaded.Close()
+
+		current := config.Default()
+		current.Auth.InsecureNoAuth = true
+		next := current
+		next.ExchangeRates.URL = reloaded.URL
+		app.AppReloader = config.NewReloader(current, func() (config.Config, error) {
+			return next, next.Validate()
+		}, func(_, next config.Config) (func(), error) {
+			return func() {
+				app.SetExchangeRateURL(next.ExchangeRates.URL)
+			}, nil
+		})
+
+		reload := func() *http.Response {
+			resp, rerr := http.Post(as.URL+"/v1/admin/reload", "application/json", nil)
+			Expect(rerr).ToNot(HaveOccurred())
+			return resp
+		}
+
+		resp := reload()
+		var report config.Report
+		Expect(json.NewDecoder(resp.Body).Decode(&report)).To(Succeed())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(report.Applied).To(Equal([]string{"exchange_rates.url"}))
+
+		// the rates now come from the reloaded URL
+		respCode, respString, err = sendAddRequest(as.URL, "transaction 1",
+			time.Now().Format(record.FiscalDateFormat),
+			fmt.Sprintf("%f", amount))
+		Expect(err).ToNot(HaveOccurred())
+		list, err = transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = sendGetRequest(as.URL, list[0].ID, currDesc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hits.Load()).To(BeNumerically(">", 0))
+
+		next.ExchangeRates.URL = "ftp://rates.local"
+		resp = reload()
+		var p hm.Problem
+		Expect(json.NewDecoder(resp.Body).Decode(&p)).To(Succeed())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(p.Detail).To(ContainSubstring("exchange_rates.url"))
+		Expect(app.ExchangeRateURL()).To(Equal(reloaded.URL))
+	})
+
 	When("no record returned from fiscal data server", func() {
 		It("returns appropriate error message", func() {
 			fiscals = []record.FiscalRecord{}
@@ -136,7 +949,8 @@ var _ = Describe("Run HTTP Server", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(respCode).To(Equal(http.StatusOK))
 
-			list = transaction.List()
+			list, err = transaction.List(context.Background())
+			Expect(err).ToNot(HaveOccurred())
 			Expect(list).To(HaveLen(1))
 
 			outRec, respString, err = sendGetRequest(as.URL, list[0].ID, currDesc)
@@ -170,6 +984,23 @@ func sendAddRequest(url, description, date, amount string) (int, string, error)
 	return resp.StatusCode, string(b), nil
 }
 
+func sendJSONRequest(url, body string) (int, string, error) {
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var b []byte
+	if b, err = io.ReadAll(resp.Body); err != nil {
+		return 0, "", err
+	}
+
+	return resp.StatusCode, string(b), nil
+}
+
 func sendGetRequest(url, id, targetCurrency string) (outRec record.ConvertedTransaction, respStr string, err error) {
 	url = fmt.Sprintf("%s/get/%s?target=%s", url, id, targetCurrency)
 	client := &http.Client{}
@@ -201,3 +1032,21 @@ func sendGetRequest(url, id, targetCurrency string) (outRec record.ConvertedTran
 	}
 	return
 }
+
+// syncBuffer is a buffer the server goroutines write logs to while the spec reads them.
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
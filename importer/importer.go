@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"io"
+)
+
+type Config interface {
+	Transaction() types.TxI
+}
+
+type Module struct {
+	config Config
+}
+
+func New(config Config) *Module {
+	return &Module{
+		config: config,
+	}
+}
+
+// Import reads a bank statement and adds its entries through TxI.Add. An entry whose ID, the hash of
+// description, date and amount, already exists in the table or earlier in the statement is reported as a
+// duplicate and skipped. With DryRun, nothing is added and the report tells what an import would do. When
+// adding an entry fails, the import stops and the report of the entries handled so far is returned with the
+// error.
+func (m *Module) Import(ctx context.Context, r io.Reader, opts record.ImportOptions) (report record.ImportReport, err error) {
+	br := bufio.NewReader(r)
+	if opts.Format == "" {
+		head, _ := br.Peek(512)
+		opts.Format = Detect(opts.Name, head)
+	}
+
+	var rows []record.ImportRow
+	switch opts.Format {
+	case record.FormatCSV:
+		rows, err = parseCSV(br, opts.Mapping)
+	case record.FormatOFX:
+		rows, err = parseOFX(br)
+	case record.FormatQIF:
+		rows, err = parseQIF(br)
+	default:
+		err = fmt.Errorf("%w: unknown format %q", types.InvalidInputError, opts.Format)
+	}
+	if err != nil {
+		return
+	}
+
+	report = record.ImportReport{
+		Format:     opts.Format,
+		DryRun:     opts.DryRun,
+		Rows:       len(rows),
+		Duplicates: make([]record.ImportRow, 0),
+		Invalid:    make([]record.ImportRow, 0),
+	}
+
+	var existing []record.TransactionRecord
+	if existing, err = m.config.Transaction().List(ctx); err != nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, rec := range existing {
+		seen[rec.ID] = true
+	}
+
+	ctx = types.WithPrincipal(ctx, importPrincipal(ctx))
+	for _, row := range rows {
+		if row.Error != "" {
+			report.Invalid = append(report.Invalid, row)
+			continue
+		}
+
+		var rec record.TransactionRecord
+		if rec, err = transaction.NewRecord(row.Description, row.Date, row.Amount); err != nil {
+			row.Error = err.Error()
+			report.Invalid = append(report.Invalid, row)
+			continue
+		}
+		row.ID = rec.ID
+
+		if seen[rec.ID] {
+			report.Duplicates = append(report.Duplicates, row)
+			continue
+		}
+		seen[rec.ID] = true
+
+		if !opts.DryRun {
+			if err = m.config.Transaction().Add(ctx, row.Description, row.Date, row.Amount); err != nil {
+				return
+			}
+		}
+		report.Imported++
+	}
+
+	err = nil
+	return
+}
+
+// importPrincipal keeps the caller as the actor and marks the source of the change as an import.
+func importPrincipal(ctx context.Context) types.Principal {
+	p := types.PrincipalFrom(ctx)
+	if p.Source == "" {
+		p.Source = types.SourceImport
+	} else {
+		p.Source = p.Source + "/" + types.SourceImport
+	}
+
+	return p
+}
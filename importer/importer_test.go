@@ -0,0 +1,230 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/importer"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	tx "github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"strings"
+	"testing"
+)
+
+func newApp() *transactiondemo.App {
+	app := &transactiondemo.App{
+		AppSkipFile: true,
+	}
+	app.AppRepo = repoModule.New(app)
+	app.AppTransaction = tx.New(app)
+
+	return app
+}
+
+const statementCSV = `Posted;Payee;Value
+12/09/2023;Coffee shop;"4,50"
+13/09/2023;Book store;"(1.234,00)"
+13/09/2023;Book store;"(1.234,00)"
+14/09/2023;Lorem ipsum dolor sit amet, consectetur adipiscing e;"1,00"
+not a date;Bakery;"2,00"
+`
+
+func TestModule_ImportCSV(t *testing.T) {
+	app := newApp()
+	m := importer.New(app)
+	opts := record.ImportOptions{
+		Name:   "statement.txt",
+		DryRun: true,
+		Mapping: record.CSVMapping{
+			Date:         "posted",
+			Description:  "2",
+			Amount:       "Value",
+			DateFormat:   "02/01/2006",
+			Delimiter:    ';',
+			DecimalComma: true,
+		},
+	}
+
+	report, err := m.Import(context.Background(), strings.NewReader(statementCSV), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, record.FormatCSV, report.Format)
+	assert.Equal(t, 5, report.Rows)
+	assert.Equal(t, 2, report.Imported)
+	assert.Len(t, report.Duplicates, 1)
+	if assert.Len(t, report.Invalid, 2) {
+		assert.Equal(t, 5, report.Invalid[0].Row)
+		assert.Equal(t, 6, report.Invalid[1].Row)
+	}
+	list, err := app.Transaction().List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	opts.DryRun = false
+	report, err = m.Import(context.Background(), strings.NewReader(statementCSV), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, report.Imported)
+
+	list, err = app.Transaction().List(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		amounts := map[float64]bool{list[0].Amount: true, list[1].Amount: true}
+		assert.Equal(t, map[float64]bool{4.5: true, -1234: true}, amounts)
+	}
+
+	opts.DryRun = true
+	report, err = m.Import(context.Background(), strings.NewReader(statementCSV), opts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, report.Imported)
+		assert.Len(t, report.Duplicates, 3)
+	}
+}
+
+const statementOFX = `OFXHEADER:100
+DATA:OFXSGML
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20230912120000[-5:EST]
+<TRNAMT>-23.45
+<FITID>1
+<NAME>Grocery
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20230915
+<TRNAMT>1000.00
+<FITID>2
+<MEMO>Salary
+</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>976.55<DTASOF>20230930</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const statementQIF = `!Type:Bank
+D9/12'23
+T-23.45
+PGrocery
+^
+D09/15/2023
+T1,000.00
+MSalary
+^
+`
+
+func TestModule_ImportOFXAndQIF(t *testing.T) {
+	for name, statement := range map[string]string{"statement.ofx": statementOFX, "statement": statementQIF} {
+		t.Run(name, func(t *testing.T) {
+			app := newApp()
+			report, err := importer.New(app).Import(context.Background(), strings.NewReader(statement),
+				record.ImportOptions{Name: name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 2, report.Imported)
+			assert.Empty(t, report.Invalid)
+
+			list, err := app.Transaction().List(context.Background())
+			assert.NoError(t, err)
+			for _, rec := range list {
+				switch rec.Description {
+				case "Grocery":
+					assert.Equal(t, -23.45, rec.Amount)
+					assert.Equal(t, "2023-09-12", rec.Date.Date().Format(record.FiscalDateFormat))
+				case "Salary":
+					assert.Equal(t, 1000.0, rec.Amount)
+				default:
+					t.Errorf("unexpected transaction %q", rec.Description)
+				}
+			}
+		})
+	}
+}
+
+const statementQIFYears = `!Type:Bank
+D1/2' 3
+T1
+PPadded
+^
+D1/2'23
+T2
+PApostrophe
+^
+D1/2/98
+T3
+PSlash
+^
+D1/2/23
+T4
+PShort
+^
+D1/2/2023
+T5
+PFull
+^
+D1/2/203
+T6
+PInvalid
+^
+`
+
+func TestModule_ImportQIFYears(t *testing.T) {
+	app := newApp()
+	report, err := importer.New(app).Import(context.Background(), strings.NewReader(statementQIFYears),
+		record.ImportOptions{Format: record.FormatQIF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, report.Invalid, 1) {
+		assert.Equal(t, "Invalid", report.Invalid[0].Description)
+	}
+
+	dates := make(map[string]string)
+	list, err := app.Transaction().List(context.Background())
+	assert.NoError(t, err)
+	for _, rec := range list {
+		dates[rec.Description] = rec.Date.Date().Format(record.FiscalDateFormat)
+	}
+	assert.Equal(t, map[string]string{
+		"Padded":     "2003-01-02",
+		"Apostrophe": "2023-01-02",
+		"Slash":      "1998-01-02",
+		"Short":      "2023-01-02",
+		"Full":       "2023-01-02",
+	}, dates)
+}
+
+// failingTransactions fails to add the transactions once limit are added.
+type failingTransactions struct {
+	types.TxI
+	limit int
+}
+
+func (f *failingTransactions) Add(ctx context.Context, description, date, amount string, lines ...record.LineItem) error {
+	if f.limit == 0 {
+		return types.AsServerError(errors.New("disk full"))
+	}
+	f.limit--
+	return f.TxI.Add(ctx, description, date, amount, lines...)
+}
+
+func TestModule_ImportPartial(t *testing.T) {
+	app := newApp()
+	app.AppTransaction = &failingTransactions{TxI: app.AppTransaction, limit: 1}
+
+	report, err := importer.New(app).Import(context.Background(), strings.NewReader(statementQIF),
+		record.ImportOptions{Format: record.FormatQIF})
+	assert.ErrorIs(t, err, types.ServerError)
+	assert.Equal(t, 2, report.Rows)
+	assert.Equal(t, 1, report.Imported)
+}
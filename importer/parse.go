@@ -0,0 +1,342 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are tried in order when the statement does not declare a date format. Day-first and
+// month-first dates are ambiguous, month-first wins as in US bank statements.
+var dateLayouts = []string{
+	record.FiscalDateFormat,
+	"2006/01/02",
+	"01/02/2006",
+	"1/2/2006",
+	"01/02/06",
+	"02.01.2006",
+	"20060102",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+	"02-Jan-2006",
+}
+
+// Detect guesses the format from the file extension and then from the content.
+func Detect(name string, head []byte) record.ImportFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ofx", ".qfx":
+		return record.FormatOFX
+	case ".qif":
+		return record.FormatQIF
+	case ".csv":
+		return record.FormatCSV
+	}
+
+	trimmed := bytes.TrimSpace(head)
+	switch {
+	case bytes.Contains(head, []byte("<OFX>")) || bytes.HasPrefix(trimmed, []byte("OFXHEADER")):
+		return record.FormatOFX
+	case bytes.HasPrefix(trimmed, []byte("!Type:")) || bytes.HasPrefix(trimmed, []byte("!Account")):
+		return record.FormatQIF
+	}
+
+	return record.FormatCSV
+}
+
+func parseDate(s, layout string) (string, error) {
+	s = strings.TrimSpace(s)
+	if layout != "" {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid date %q", types.InvalidInputError, s)
+		}
+		return t.Format(record.FiscalDateFormat), nil
+	}
+
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t.Format(record.FiscalDateFormat), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: invalid date %q", types.InvalidInputError, s)
+}
+
+// parseAmount accepts currency symbols, thousands separators, a trailing minus and parentheses for negative
+// amounts, e.g. "$1,234.56", "(12.00)" or "12.00-".
+func parseAmount(s string, decimalComma bool) (string, error) {
+	v := strings.TrimSpace(s)
+	negative := false
+
+	if strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")") {
+		negative = true
+		v = v[1 : len(v)-1]
+	}
+	if strings.HasSuffix(v, "-") {
+		negative = !negative
+		v = strings.TrimSuffix(v, "-")
+	}
+
+	v = strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',', r == '-', r == '+':
+			return r
+		}
+		return -1
+	}, v)
+
+	if decimalComma {
+		v = strings.ReplaceAll(v, ".", "")
+		v = strings.ReplaceAll(v, ",", ".")
+	} else {
+		v = strings.ReplaceAll(v, ",", "")
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid amount %q", types.InvalidInputError, s)
+	}
+
+	if negative {
+		f = -f
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
+func parseCSV(r io.Reader, m record.CSVMapping) ([]record.ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	if m.Delimiter != 0 {
+		cr.Comma = m.Delimiter
+	}
+
+	var (
+		header  []string
+		columns [3]int
+		rows    []record.ImportRow
+		err     error
+	)
+
+	if !m.NoHeader {
+		if header, err = cr.Read(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%w: %w", types.InvalidInputError, err)
+		}
+		// spreadsheets often start the file with a byte order mark
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	for i, column := range []string{m.Date, m.Description, m.Amount} {
+		if columns[i], err = columnIndex(column, header, []string{"date", "description", "amount"}[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := record.ImportRow{Row: line}
+		if err != nil {
+			row.Error = err.Error()
+			rows = append(rows, row)
+			continue
+		}
+
+		if max(columns[0], columns[1], columns[2]) >= len(fields) {
+			row.Error = "missing columns"
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Description = strings.TrimSpace(fields[columns[1]])
+		rows = append(rows, fillRow(row, fields[columns[0]], fields[columns[2]], m))
+	}
+}
+
+// columnIndex resolves a column given by header name or 1-based index. Without a mapping, the column with
+// the default name is used.
+func columnIndex(column string, header []string, defaultName string) (int, error) {
+	if n, err := strconv.Atoi(column); err == nil && n > 0 {
+		return n - 1, nil
+	}
+
+	if column == "" {
+		column = defaultName
+	}
+
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), column) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: no column %q in the statement", types.InvalidInputError, column)
+}
+
+func fillRow(row record.ImportRow, date, amount string, m record.CSVMapping) record.ImportRow {
+	var err error
+	if row.Date, err = parseDate(date, m.DateFormat); err != nil {
+		row.Date = date
+		row.Error = err.Error()
+	}
+
+	if row.Amount, err = parseAmount(amount, m.DecimalComma); err != nil {
+		row.Amount = amount
+		if row.Error == "" {
+			row.Error = err.Error()
+		}
+	}
+
+	return row
+}
+
+var ofxTag = regexp.MustCompile(`<([A-Z.]+)>([^<\r\n]*)`)
+
+// parseOFX reads the STMTTRN entries of OFX 1.x (SGML, without closing tags) and OFX 2.x (XML) files.
+func parseOFX(r io.Reader) ([]record.ImportRow, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rows    []record.ImportRow
+		current *record.ImportRow
+		fields  map[string]string
+	)
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+
+		current.Description = fields["NAME"]
+		if current.Description == "" {
+			current.Description = fields["MEMO"]
+		}
+
+		posted := fields["DTPOSTED"]
+		if len(posted) >= 8 {
+			posted = posted[:8]
+		}
+		rows = append(rows, fillRow(*current, posted, fields["TRNAMT"], record.CSVMapping{DateFormat: "20060102"}))
+		current = nil
+	}
+
+	for _, m := range ofxTag.FindAllSubmatch(b, -1) {
+		tag, value := string(m[1]), strings.TrimSpace(string(m[2]))
+		switch tag {
+		case "STMTTRN":
+			flush()
+			current = &record.ImportRow{Row: len(rows) + 1}
+			fields = make(map[string]string)
+		case "BANKTRANLIST", "LEDGERBAL":
+			flush()
+		default:
+			if current != nil && value != "" {
+				fields[tag] = value
+			}
+		}
+	}
+	flush()
+
+	return rows, nil
+}
+
+// parseQIF reads the D (date), T or U (amount), P (payee) and M (memo) fields of each entry.
+func parseQIF(r io.Reader) ([]record.ImportRow, error) {
+	var (
+		rows  []record.ImportRow
+		scan  = bufio.NewScanner(r)
+		line  int
+		start int
+		date  string
+		total string
+		payee string
+		memo  string
+	)
+
+	for scan.Scan() {
+		line++
+		text := strings.TrimSpace(scan.Text())
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+
+		if start == 0 {
+			start = line
+		}
+
+		value := text[1:]
+		switch text[0] {
+		case 'D':
+			date = qifDate(value)
+		case 'T', 'U':
+			total = value
+		case 'P':
+			payee = value
+		case 'M':
+			memo = value
+		case '^':
+			row := record.ImportRow{Row: start, Description: payee}
+			if row.Description == "" {
+				row.Description = memo
+			}
+			rows = append(rows, fillRow(row, date, total, record.CSVMapping{}))
+			start, date, total, payee, memo = 0, "", "", "", ""
+		}
+	}
+
+	return rows, scan.Err()
+}
+
+// qifDate writes the date of a QIF entry with a four-digit year. Quicken separates the years after 1999 with
+// an apostrophe, padding a single digit with a space, e.g. 1/2'23 and 1/2' 3 for 2023 and 2003, and the
+// years before with a slash, e.g. 1/2/98. A two-digit year after a slash is read as time.Parse does, 69 to
+// 99 in the 1900s and the others in the 2000s. A date of another form is returned as it is, parseDate
+// rejects it.
+func qifDate(value string) string {
+	i := strings.LastIndexAny(value, "/'")
+	if i < 0 {
+		return value
+	}
+
+	year := strings.TrimSpace(value[i+1:])
+	y, err := strconv.Atoi(year)
+	if err != nil || y < 0 {
+		return value
+	}
+
+	switch {
+	case len(year) == 4:
+	case value[i] == '\'' && len(year) <= 2:
+		y += 2000
+	case value[i] == '/' && len(year) == 2:
+		y += 1900
+		if y < 1969 {
+			y += 100
+		}
+	default:
+		return value
+	}
+
+	return fmt.Sprintf("%s/%d", strings.TrimSpace(value[:i]), y)
+}
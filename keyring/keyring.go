@@ -0,0 +1,136 @@
+package keyring
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Keyring holds the AES-256 keys used to encrypt records at rest. New data is sealed with the primary key,
+// the other keys only open data sealed before a rotation.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// Parse reads keys in the form "id:base64key", separated by commas or newlines. The first key is the
+// primary one. Empty entries and lines starting with # are ignored.
+func Parse(spec string) (*Keyring, error) {
+	k := &Keyring{
+		aeads: make(map[string]cipher.AEAD),
+	}
+
+	scan := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(spec, ",", "\n")))
+	for scan.Scan() {
+		entry := strings.TrimSpace(scan.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid key entry, expected id:base64key")
+		}
+
+		if _, exists := k.aeads[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %q is not a base64 encoded 32 byte key", id)
+		}
+
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err != nil {
+			return nil, err
+		}
+
+		if k.aeads[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+
+	if k.primary == "" {
+		return nil, fmt.Errorf("no key found")
+	}
+
+	return k, nil
+}
+
+func ReadFile(path string) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(string(b))
+}
+
+// Load returns the keyring from the spec when it is not empty, otherwise from the file at path. Without
+// either, it returns nil and records are stored in cleartext.
+func Load(spec, path string) (*Keyring, error) {
+	if spec != "" {
+		return Parse(spec)
+	}
+
+	if path != "" {
+		return ReadFile(path)
+	}
+
+	return nil, nil
+}
+
+// Generate returns a new key entry for a key file.
+func Generate(id string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%s", id, base64.StdEncoding.EncodeToString(key)), nil
+}
+
+// Seal encrypts the plaintext with the primary key. It returns the key id and the base64 encoded nonce and
+// ciphertext. The key id is authenticated along with the plaintext.
+func (k *Keyring) Seal(plaintext []byte) (string, string) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err)
+	}
+
+	return k.primary, base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(k.primary)))
+}
+
+func (k *Keyring) Open(id, sealed string) ([]byte, error) {
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(id))
+}
+
+func (k *Keyring) Primary() string {
+	return k.primary
+}
@@ -0,0 +1,43 @@
+package keyring_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/keyring"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	entry1, err := keyring.Generate("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry2, err := keyring.Generate("k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := keyring.Parse(entry1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := keyring.Parse("# rotated\n" + entry2 + "\n" + entry1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "k2", rotated.Primary())
+
+	id, sealed := old.Seal([]byte("secret"))
+	assert.Equal(t, "k1", id)
+
+	plain, err := rotated.Open(id, sealed)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "secret", string(plain))
+	}
+
+	_, err = old.Open("k2", sealed)
+	assert.Error(t, err)
+
+	_, err = keyring.Parse("k1:c2hvcnQ=")
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package lock
+
+import (
+	"context"
+	"github.com/suyono3484/transactiondemo/types"
+	"sync"
+)
+
+// Mutex is a mutual exclusion lock whose wait ends when the context is done.
+type Mutex struct {
+	ch chan struct{}
+}
+
+func NewMutex() *Mutex {
+	return &Mutex{ch: make(chan struct{}, 1)}
+}
+
+// Lock waits for the lock until ctx is done, then it fails with a types.CanceledError. It also fails when
+// ctx is done already, even if the lock is free, so an operation canceled before it started does not start.
+func (m *Mutex) Lock(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return types.Canceled(ctx, "waiting for a lock")
+	}
+
+	select {
+	case m.ch <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case m.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return types.Canceled(ctx, "waiting for a lock")
+	}
+}
+
+func (m *Mutex) Unlock() {
+	select {
+	case <-m.ch:
+	default:
+		panic("lock: unlock of unlocked mutex")
+	}
+}
+
+// RWMutex is a reader/writer lock whose waits end when the context is done. Like sync.RWMutex, a waiting
+// writer keeps new readers out, so a reader must not lock it again.
+type RWMutex struct {
+	mtx            sync.Mutex
+	readers        int
+	writer         bool
+	waitingWriters int
+	released       chan struct{}
+}
+
+func NewRWMutex() *RWMutex {
+	return &RWMutex{released: make(chan struct{})}
+}
+
+// Lock waits for the exclusive lock until ctx is done, then it fails with a types.CanceledError. Like
+// Mutex.Lock, it fails when ctx is done already.
+func (m *RWMutex) Lock(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return types.Canceled(ctx, "waiting for a lock")
+	}
+
+	m.mtx.Lock()
+	m.waitingWriters++
+	for m.writer || m.readers > 0 {
+		released := m.released
+		m.mtx.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			m.mtx.Lock()
+			m.waitingWriters--
+			// the readers held back by this writer may go on
+			m.broadcast()
+			m.mtx.Unlock()
+			return types.Canceled(ctx, "waiting for a lock")
+		}
+
+		m.mtx.Lock()
+	}
+	m.waitingWriters--
+	m.writer = true
+	m.mtx.Unlock()
+
+	return nil
+}
+
+func (m *RWMutex) Unlock() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if !m.writer {
+		panic("lock: unlock of unlocked RWMutex")
+	}
+	m.writer = false
+	m.broadcast()
+}
+
+// RLock waits for a shared lock until ctx is done, then it fails with a types.CanceledError. Like
+// Mutex.Lock, it fails when ctx is done already.
+func (m *RWMutex) RLock(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return types.Canceled(ctx, "waiting for a lock")
+	}
+
+	m.mtx.Lock()
+	for m.writer || m.waitingWriters > 0 {
+		released := m.released
+		m.mtx.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return types.Canceled(ctx, "waiting for a lock")
+		}
+
+		m.mtx.Lock()
+	}
+	m.readers++
+	m.mtx.Unlock()
+
+	return nil
+}
+
+func (m *RWMutex) RUnlock() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.readers == 0 {
+		panic("lock: runlock of unlocked RWMutex")
+	}
+	m.readers--
+	if m.readers == 0 {
+		m.broadcast()
+	}
+}
+
+// broadcast wakes every waiter up to check the lock again. The caller holds mtx.
+func (m *RWMutex) broadcast() {
+	close(m.released)
+	m.released = make(chan struct{})
+}
@@ -0,0 +1,70 @@
+package lock_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/lock"
+	"github.com/suyono3484/transactiondemo/types"
+	"testing"
+	"time"
+)
+
+func TestMutex(t *testing.T) {
+	m := lock.NewMutex()
+	assert.NoError(t, m.Lock(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := m.Lock(ctx)
+	assert.ErrorIs(t, err, types.CanceledError)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	m.Unlock()
+	assert.NoError(t, m.Lock(context.Background()))
+	m.Unlock()
+
+	// a done context fails even on a free lock
+	assert.ErrorIs(t, m.Lock(ctx), types.CanceledError)
+}
+
+func TestRWMutex(t *testing.T) {
+	m := lock.NewRWMutex()
+	assert.NoError(t, m.RLock(context.Background()))
+	assert.NoError(t, m.RLock(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	writer := make(chan error)
+	go func() {
+		writer <- m.Lock(ctx)
+	}()
+
+	// the waiting writer keeps new readers out
+	assert.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		if m.RLock(ctx) == nil {
+			m.RUnlock()
+			return false
+		}
+		return true
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	err := <-writer
+	assert.ErrorIs(t, err, types.CanceledError)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// without the writer the readers come in again
+	assert.NoError(t, m.RLock(context.Background()))
+	m.RUnlock()
+	m.RUnlock()
+	m.RUnlock()
+
+	assert.NoError(t, m.Lock(context.Background()))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Unlock()
+	}()
+	assert.NoError(t, m.RLock(context.Background()))
+	m.RUnlock()
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// The formats of the log output.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Logger is a slog.Logger whose level can be changed while it is in use, e.g. through the admin API.
+type Logger struct {
+	*slog.Logger
+	level *slog.LevelVar
+}
+
+// New returns a logger writing records of the level and above to w in the format, text or json.
+func New(w io.Writer, format string, level slog.Level) (*Logger, error) {
+	l := &Logger{level: &slog.LevelVar{}}
+	l.level.Set(level)
+
+	opts := &slog.HandlerOptions{Level: l.level}
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		l.Logger = slog.New(slog.NewTextHandler(w, opts))
+	case FormatJSON:
+		l.Logger = slog.New(slog.NewJSONHandler(w, opts))
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return l, nil
+}
+
+func (l *Logger) Level() slog.Level {
+	return l.level.Level()
+}
+
+func (l *Logger) SetLevel(level slog.Level) {
+	l.level.Set(level)
+}
+
+// ParseLevel parses a level name like debug, info, warn or error, case insensitive, with an optional offset
+// like debug-2.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("unknown log level %q", s)
+	}
+
+	return level, nil
+}
+
+type contextKey struct{}
+
+// WithLogger returns a context carrying the logger, e.g. one with the attributes of a request.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of ctx, or the default logger when ctx carries none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
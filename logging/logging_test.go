@@ -0,0 +1,54 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/logging"
+	"log/slog"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	l, err := logging.New(&b, logging.FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Debug("hidden")
+	l.SetLevel(slog.LevelDebug)
+	l.Debug("shown", "request_id", "abc")
+	assert.Equal(t, slog.LevelDebug, l.Level())
+
+	var rec map[string]any
+	if err = json.Unmarshal(b.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "shown", rec["msg"])
+	assert.Equal(t, "abc", rec["request_id"])
+
+	_, err = logging.New(&b, "xml", slog.LevelInfo)
+	assert.Error(t, err)
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := logging.ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	level, err = logging.ParseLevel("debug-2")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug-2, level)
+
+	_, err = logging.ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Same(t, slog.Default(), logging.FromContext(context.Background()))
+
+	logger := slog.Default().With("request_id", "abc")
+	assert.Same(t, logger, logging.FromContext(logging.WithLogger(context.Background(), logger)))
+}
@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format written by Registry.Write.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of a latency histogram.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample is a value of a gauge read when the metrics are written, with the values of its labels.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+type kind string
+
+const (
+	counterKind   kind = "counter"
+	gaugeKind     kind = "gauge"
+	histogramKind kind = "histogram"
+)
+
+type family struct {
+	name    string
+	help    string
+	kind    kind
+	labels  []string
+	buckets []float64
+
+	mtx    *sync.Mutex
+	series map[string]*series
+	gauge  func() []Sample
+}
+
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+// Registry holds the metrics of the application. A nil registry, and the metrics it returns, accept every
+// call and record nothing, so that the modules need no check when metrics are off.
+type Registry struct {
+	mtx      *sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		mtx:      &sync.Mutex{},
+		families: make(map[string]*family),
+	}
+}
+
+// register returns the family with the name, created on first use. Registering a name again with another
+// kind or other labels is a programming error.
+func (r *Registry) register(f *family) *family {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if existing, ok := r.families[f.name]; ok {
+		if existing.kind != f.kind || strings.Join(existing.labels, ",") != strings.Join(f.labels, ",") {
+			panic(fmt.Sprintf("metrics: %s is already registered as a %s with labels %v",
+				f.name, existing.kind, existing.labels))
+		}
+		if f.gauge != nil {
+			existing.gauge = f.gauge
+		}
+		return existing
+	}
+
+	f.mtx = &sync.Mutex{}
+	f.series = make(map[string]*series)
+	if len(f.labels) == 0 && f.kind != gaugeKind {
+		// a metric without labels is written from the start, at zero
+		f.get(nil)
+	}
+	r.families[f.name] = f
+	return f
+}
+
+// Counter is a count that only goes up, one per combination of label values.
+type Counter struct {
+	f *family
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	if r == nil {
+		return nil
+	}
+
+	return &Counter{f: r.register(&family{name: name, help: help, kind: counterKind, labels: labels})}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if c == nil {
+		return
+	}
+
+	c.f.mtx.Lock()
+	defer c.f.mtx.Unlock()
+
+	c.f.get(labelValues).value += v
+}
+
+// Histogram counts observations, e.g. latencies in seconds, in buckets.
+type Histogram struct {
+	f *family
+}
+
+// Histogram returns the histogram with the bucket upper bounds, DefaultBuckets when nil.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if r == nil {
+		return nil
+	}
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	return &Histogram{f: r.register(&family{name: name, help: help, kind: histogramKind, labels: labels,
+		buckets: buckets})}
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+
+	h.f.mtx.Lock()
+	defer h.f.mtx.Unlock()
+
+	s := h.f.get(labelValues)
+	for i, upper := range h.f.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// GaugeFunc registers a gauge whose samples are read by collect when the metrics are written. Registering
+// the name again replaces collect.
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func() []Sample) {
+	if r == nil {
+		return
+	}
+
+	r.register(&family{name: name, help: help, kind: gaugeKind, labels: labels, gauge: collect})
+}
+
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes the labels %v, got %d values", f.name, f.labels, len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), labelValues...)}
+		if f.kind == histogramKind {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+
+	return s
+}
+
+// Write writes the metrics in the Prometheus text exposition format, sorted by name and label values.
+func (r *Registry) Write(w io.Writer) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mtx.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mtx.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	var samples []series
+
+	if f.kind == gaugeKind {
+		for _, s := range f.gauge() {
+			samples = append(samples, series{labels: s.Labels, value: s.Value})
+		}
+	} else {
+		f.mtx.Lock()
+		for _, s := range f.series {
+			c := *s
+			c.counts = append([]uint64(nil), s.counts...)
+			samples = append(samples, c)
+		}
+		f.mtx.Unlock()
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].labels, "\xff") < strings.Join(samples[j].labels, "\xff")
+	})
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+	for _, s := range samples {
+		labels := f.labelPairs(s.labels)
+
+		if f.kind != histogramKind {
+			_, _ = fmt.Fprintf(w, "%s%s %s\n", f.name, braces(labels), formatFloat(s.value))
+			continue
+		}
+
+		for i, upper := range f.buckets {
+			le := append(labels, `le="`+formatFloat(upper)+`"`)
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, braces(le), s.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, braces(append(labels, `le="+Inf"`)), s.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", f.name, braces(labels), formatFloat(s.value))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", f.name, braces(labels), s.count)
+	}
+}
+
+func (f *family) labelPairs(values []string) []string {
+	pairs := make([]string, 0, len(f.labels)+1)
+	for i, name := range f.labels {
+		var v string
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs = append(pairs, name+`="`+escapeLabel(v)+`"`)
+	}
+
+	return pairs
+}
+
+func braces(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
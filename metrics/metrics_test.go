@@ -0,0 +1,75 @@
+package metrics_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/metrics"
+	"testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	requests := reg.Counter("requests_total", "Requests served.", "route", "status")
+	requests.Inc("/b", "200")
+	requests.Inc("/a", "404")
+	requests.Add(2, "/a", "200")
+	// registering the name again returns the same counter
+	reg.Counter("requests_total", "Requests served.", "route", "status").Inc("/b", "200")
+
+	reg.Counter("errors_total", "Errors.")
+	latency := reg.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	reg.GaugeFunc("items", "Items\nstored.", []string{"tenant"}, func() []metrics.Sample {
+		return []metrics.Sample{{Labels: []string{`team "a"`}, Value: 3}, {Labels: []string{"default"}, Value: 1.5}}
+	})
+
+	var b bytes.Buffer
+	if err := reg.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `# HELP errors_total Errors.
+# TYPE errors_total counter
+errors_total 0
+# HELP items Items\nstored.
+# TYPE items gauge
+items{tenant="default"} 1.5
+items{tenant="team \"a\""} 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.55
+latency_seconds_count 3
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/a",status="200"} 2
+requests_total{route="/a",status="404"} 1
+requests_total{route="/b",status="200"} 2
+`, b.String())
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := reg.Counter("requests_total", "Requests served.", "route")
+
+	assert.Panics(t, func() { c.Inc() })
+	assert.Panics(t, func() { reg.Histogram("requests_total", "Requests served.", nil, "route") })
+	assert.Panics(t, func() { reg.Counter("requests_total", "Requests served.", "path") })
+}
+
+func TestNilRegistry(t *testing.T) {
+	var reg *metrics.Registry
+
+	assert.NotPanics(t, func() {
+		reg.Counter("requests_total", "Requests served.", "route").Inc("/a")
+		reg.Histogram("latency_seconds", "Latency.", nil).Observe(1)
+		reg.GaugeFunc("items", "Items.", nil, nil)
+	})
+	assert.NoError(t, reg.Write(&bytes.Buffer{}))
+}
@@ -0,0 +1,253 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class separates the limits of the routes reading data from the routes changing it. IP is the limit of
+// every request of a client IP, checked before the request is authenticated.
+type Class int
+
+const (
+	Read Class = iota
+	Write
+	IP
+)
+
+func (c Class) String() string {
+	switch c {
+	case Write:
+		return "write"
+	case IP:
+		return "ip"
+	default:
+		return "read"
+	}
+}
+
+// pruneInterval is how often the buckets refilled to their burst, which are the same as new buckets, are
+// dropped.
+const pruneInterval = time.Minute
+
+// Limit is a token bucket: Rate tokens per second refill up to Burst tokens, a request takes one. A zero
+// Rate disables the limit.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Config holds the limits of each class of routes. The limits apply to each client on its own.
+type Config struct {
+	Read  Limit `json:"read"`
+	Write Limit `json:"write"`
+	IP    Limit `json:"ip"`
+}
+
+func (c Config) limit(class Class) Limit {
+	switch class {
+	case Write:
+		return c.Write
+	case IP:
+		return c.IP
+	default:
+		return c.Read
+	}
+}
+
+// Validate checks that every enabled limit lets at least one request through.
+func (c Config) Validate() error {
+	for _, class := range []Class{Read, Write, IP} {
+		l := c.limit(class)
+		if l.Rate < 0 || math.IsNaN(l.Rate) || math.IsInf(l.Rate, 0) {
+			return fmt.Errorf("invalid %s rate %v", class, l.Rate)
+		}
+		if l.Rate > 0 && l.Burst < 1 {
+			return fmt.Errorf("the %s burst must be 1 or more", class)
+		}
+	}
+
+	return nil
+}
+
+// ParseConfig reads limits in the form "class=rate:burst", separated by commas, where class is read, write
+// or ip and rate is the number of requests per second, e.g. "read=10:20,write=0.5:5". A missing class is
+// not limited.
+func ParseConfig(spec string) (Config, error) {
+	var c Config
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		rate, burst, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 {
+			return c, fmt.Errorf("invalid rate limit %q, expected class=rate:burst", entry)
+		}
+
+		var (
+			l   Limit
+			err error
+		)
+		if l.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+			return c, fmt.Errorf("invalid rate limit %q: %w", entry, err)
+		}
+		if l.Burst, err = strconv.Atoi(burst); err != nil {
+			return c, fmt.Errorf("invalid rate limit %q: %w", entry, err)
+		}
+
+		switch name {
+		case Read.String():
+			c.Read = l
+		case Write.String():
+			c.Write = l
+		case IP.String():
+			c.IP = l
+		default:
+			return c, fmt.Errorf("invalid rate limit %q, the class is read, write or ip", entry)
+		}
+	}
+
+	return c, c.Validate()
+}
+
+// Decision is the outcome of a request against its bucket. Reset is when the bucket is full again;
+// RetryAfter, for a denied request, is when the next token is available.
+type Decision struct {
+	Allowed    bool
+	Limited    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucketKey struct {
+	client string
+	class  Class
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per client and class. Its configuration may be replaced at any time.
+type Limiter struct {
+	mtx       *sync.Mutex
+	config    Config
+	buckets   map[bucketKey]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func New(config Config) (*Limiter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Limiter{
+		mtx:     &sync.Mutex{},
+		config:  config,
+		buckets: make(map[bucketKey]*bucket),
+		now:     time.Now,
+	}, nil
+}
+
+// SetClock replaces the clock of the limiter, for tests.
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.now = now
+}
+
+func (l *Limiter) Config() Config {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.config
+}
+
+// SetConfig replaces the limits. An invalid configuration is rejected and the current one kept. The
+// buckets keep their tokens, up to the new bursts.
+func (l *Limiter) SetConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.config = config
+	for k, b := range l.buckets {
+		if burst := float64(config.limit(k.class).Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+
+	return nil
+}
+
+// Allow takes a token from the bucket of the client for the class.
+func (l *Limiter) Allow(client string, class Class) Decision {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	limit := l.config.limit(class)
+	if limit.Rate <= 0 {
+		return Decision{Allowed: true}
+	}
+
+	now := l.now()
+	l.prune(now)
+
+	burst := float64(limit.Burst)
+	k := bucketKey{client: client, class: class}
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[k] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	d := Decision{Limited: true, Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((burst - b.tokens) / limit.Rate)
+
+	return d
+}
+
+// prune drops the buckets that are full by now, a new bucket starts full anyway.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for k, b := range l.buckets {
+		limit := l.config.limit(k.class)
+		if limit.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,89 @@
+package ratelimit_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	l, err := ratelimit.New(ratelimit.Config{Read: ratelimit.Limit{Rate: 2, Burst: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, time.October, 1, 0, 0, 0, 0, time.UTC)
+	l.SetClock(func() time.Time { return now })
+
+	for i := 2; i >= 0; i-- {
+		d := l.Allow("a", ratelimit.Read)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 3, d.Limit)
+		assert.Equal(t, i, d.Remaining)
+	}
+
+	d := l.Allow("a", ratelimit.Read)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, d.Reset)
+
+	// the other clients and the write routes have their own limits
+	assert.True(t, l.Allow("b", ratelimit.Read).Allowed)
+	d = l.Allow("a", ratelimit.Write)
+	assert.True(t, d.Allowed)
+	assert.False(t, d.Limited)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("a", ratelimit.Read).Allowed)
+	assert.False(t, l.Allow("a", ratelimit.Read).Allowed)
+
+	// an hour later the bucket is full again, not fuller
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow("a", ratelimit.Read).Allowed)
+	}
+	assert.False(t, l.Allow("a", ratelimit.Read).Allowed)
+}
+
+func TestLimiter_SetConfig(t *testing.T) {
+	l, err := ratelimit.New(ratelimit.Config{Write: ratelimit.Limit{Rate: 1, Burst: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, time.October, 1, 0, 0, 0, 0, time.UTC)
+	l.SetClock(func() time.Time { return now })
+	assert.True(t, l.Allow("a", ratelimit.Write).Allowed)
+
+	assert.Error(t, l.SetConfig(ratelimit.Config{Write: ratelimit.Limit{Rate: 1}}))
+	assert.Equal(t, 10, l.Config().Write.Burst)
+
+	// the tokens left are capped to the new burst
+	assert.NoError(t, l.SetConfig(ratelimit.Config{Write: ratelimit.Limit{Rate: 1, Burst: 1}}))
+	assert.True(t, l.Allow("a", ratelimit.Write).Allowed)
+	assert.False(t, l.Allow("a", ratelimit.Write).Allowed)
+
+	assert.NoError(t, l.SetConfig(ratelimit.Config{}))
+	assert.True(t, l.Allow("a", ratelimit.Write).Allowed)
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := ratelimit.ParseConfig(" read=10:20, write=0.5:5,ip=50:100 ")
+	if assert.NoError(t, err) {
+		assert.Equal(t, ratelimit.Config{
+			Read:  ratelimit.Limit{Rate: 10, Burst: 20},
+			Write: ratelimit.Limit{Rate: 0.5, Burst: 5},
+			IP:    ratelimit.Limit{Rate: 50, Burst: 100},
+		}, c)
+	}
+
+	c, err = ratelimit.ParseConfig("")
+	assert.NoError(t, err)
+	assert.Zero(t, c)
+
+	for _, spec := range []string{"read=10", "read=x:1", "read=1:x", "read=1:0", "read=-1:1", "ip=1:0", "delete=1:1"} {
+		_, err = ratelimit.ParseConfig(spec)
+		assert.Error(t, err, spec)
+	}
+}
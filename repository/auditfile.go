@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"io/fs"
+	"os"
+)
+
+// AppendAuditEvent adds the event to the audit log. The log is a JSON-lines file next to the transaction
+// file that is only ever appended to. It holds transaction values, so the events are encrypted like the
+// transaction file. When the file is skipped, the log is kept in memory.
+func (r *RepoModule) AppendAuditEvent(ctx context.Context, event record.AuditEvent) error {
+	if err := r.auditMtx.Lock(ctx); err != nil {
+		return err
+	}
+	defer r.auditMtx.Unlock()
+
+	if r.config.SkipFile() {
+		r.auditLog = append(r.auditLog, event)
+		return nil
+	}
+
+	b, err := r.sealJSON(&event)
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	if f, err = os.OpenFile(r.sidecarPath("audit"), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644); err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	_, err = fmt.Fprintln(f, string(b))
+	return err
+}
+
+// ReadAuditEvents returns the events of a transaction in the order they were recorded. The scan of the log
+// stops with a types.CanceledError when ctx is done.
+func (r *RepoModule) ReadAuditEvents(ctx context.Context, transactionID string) ([]record.AuditEvent, error) {
+	if err := r.auditMtx.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.auditMtx.Unlock()
+
+	events := make([]record.AuditEvent, 0)
+	if r.config.SkipFile() {
+		for _, event := range r.auditLog {
+			if event.TransactionID == transactionID {
+				events = append(events, event)
+			}
+		}
+		return events, nil
+	}
+
+	f, err := os.Open(r.sidecarPath("audit"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return events, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var (
+		event record.AuditEvent
+		scan  = bufio.NewScanner(f)
+	)
+	scan.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scan.Scan() {
+		if ctx.Err() != nil {
+			return nil, types.Canceled(ctx, "reading the audit log")
+		}
+
+		event = record.AuditEvent{}
+		if err = r.openJSON(scan.Bytes(), &event); err != nil {
+			return nil, err
+		}
+
+		if event.TransactionID == transactionID {
+			events = append(events, event)
+		}
+	}
+
+	return events, scan.Err()
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+)
+
+func (r *RepoModule) ReadBudgets(ctx context.Context) ([]record.Budget, error) {
+	if r.config.SkipFile() {
+		return nil, nil
+	}
+
+	if err := r.sidecarMtx.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.sidecarMtx.Unlock()
+
+	var budgets []record.Budget
+	if err := r.readSidecar("budgets", &budgets); err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+func (r *RepoModule) WriteBudgets(ctx context.Context, budgets []record.Budget) error {
+	if r.config.SkipFile() {
+		return nil
+	}
+
+	if err := r.sidecarMtx.Lock(ctx); err != nil {
+		return err
+	}
+	defer r.sidecarMtx.Unlock()
+
+	return r.writeSidecar("budgets", budgets)
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+var (
+	// chainHeader is the first line of a chained file. Every line of a file starting with it must be chained,
+	// the first one to the header.
+	chainHeader = []byte(`{"chain":"sha256"}`)
+	// genesisHash is the prev value of the first line of a chained file without a header, written before the
+	// header was introduced.
+	genesisHash = lineHash(nil)
+)
+
+// chainedLine is a line of the transaction file. Prev is the SHA-256 of the previous line, which makes any
+// edit of a line detectable from the next line onward. Files written before the chain was introduced have
+// neither a header nor Prev; in a file without a header, such lines are accepted until the first chained
+// line. Rewriting the file, see Compact, chains all its lines. An encrypted line carries the key id and the
+// sealed record instead of the record fields.
+type chainedLine struct {
+	*record.TransactionRecord
+	KeyID      string `json:"kid,omitempty"`
+	Ciphertext string `json:"ct,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+type chainState struct {
+	line    int
+	head    string
+	chained bool
+	marked  bool
+}
+
+func lineHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// header reports whether the raw line is the header of a chained file, which is not a line of the chain.
+// Only the first line may be the header.
+func (c *chainState) header(raw []byte) bool {
+	if c.line > 0 || c.marked || !bytes.Equal(raw, chainHeader) {
+		return false
+	}
+
+	c.marked = true
+	c.head = lineHash(raw)
+	return true
+}
+
+// next checks the prev value of the following raw line and moves the head to it.
+func (c *chainState) next(raw []byte, prev string) error {
+	c.line++
+
+	switch {
+	case prev == "" && (c.chained || c.marked):
+		return fmt.Errorf("%w: line %d has no link to the previous line", types.ChainBrokenError, c.line)
+	case prev != "":
+		expected := c.head
+		if c.line == 1 && !c.marked {
+			expected = genesisHash
+		}
+		if prev != expected {
+			return fmt.Errorf("%w: line %d does not match the previous line", types.ChainBrokenError, c.line)
+		}
+		c.chained = true
+	}
+
+	c.head = lineHash(raw)
+	return nil
+}
+
+// chainHead returns the hash of the last line of the transaction file, the header included, reading the file
+// when it is not known yet. It is empty when the file is empty: the header is to be written first. The caller
+// holds fileMtx.
+func (r *RepoModule) chainHead(ctx context.Context) (string, error) {
+	if r.headKnown {
+		return r.head, nil
+	}
+
+	f, err := os.Open(r.config.FilePath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			r.head, r.headKnown = "", true
+			return r.head, nil
+		}
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var c chainState
+	if err = scanChain(ctx, f, &c, nil); err != nil {
+		return "", err
+	}
+
+	r.head, r.headKnown = c.head, true
+	return r.head, nil
+}
+
+// scanChain walks over the lines of the transaction file checking the chain. It stops after the line
+// stop when stop is not nil, or with a types.CanceledError when ctx is done.
+func scanChain(ctx context.Context, rd io.Reader, c *chainState, stop *int) error {
+	var (
+		line chainedLine
+		scan = newLineScanner(rd)
+	)
+
+	for scan.Scan() {
+		if ctx.Err() != nil {
+			return types.Canceled(ctx, "reading the transaction file")
+		}
+
+		if c.header(scan.Bytes()) {
+			continue
+		}
+		if stop != nil && c.line == *stop {
+			return nil
+		}
+
+		line = chainedLine{}
+		if err := json.Unmarshal(scan.Bytes(), &line); err != nil {
+			return fmt.Errorf("line %d: %w", c.line+1, err)
+		}
+
+		if err := c.next(scan.Bytes(), line.Prev); err != nil {
+			return err
+		}
+	}
+
+	return scan.Err()
+}
+
+func newLineScanner(rd io.Reader) *bufio.Scanner {
+	scan := bufio.NewScanner(rd)
+	scan.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return scan
+}
+
+// Verify checks the whole chain of the transaction file and returns an unsigned checkpoint of its head.
+// The error names the first broken link.
+func (r *RepoModule) Verify(ctx context.Context) (cp record.Checkpoint, err error) {
+	if err = r.fileMtx.Lock(ctx); err != nil {
+		return
+	}
+	defer r.fileMtx.Unlock()
+
+	return r.verify(ctx, nil)
+}
+
+func (r *RepoModule) verify(ctx context.Context, stop *int) (cp record.Checkpoint, err error) {
+	var f *os.File
+	if f, err = os.Open(r.config.FilePath()); err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var c chainState
+	if err = scanChain(ctx, f, &c, stop); err != nil {
+		return
+	}
+
+	cp.Lines = c.line
+	cp.Head = c.head
+	cp.Chained = c.marked || c.chained
+	if c.line == 0 {
+		cp.Head = genesisHash
+	}
+
+	return
+}
+
+// Checkpoint verifies the chain and signs its head. A checkpoint archived outside the server proves later
+// that the file was neither rewritten nor truncated up to that point.
+func (r *RepoModule) Checkpoint(ctx context.Context, key ed25519.PrivateKey) (cp record.Checkpoint, err error) {
+	if cp, err = r.Verify(ctx); err != nil {
+		return
+	}
+
+	cp.Time = time.Now().UTC()
+	cp.PublicKey = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	cp.Signature = hex.EncodeToString(ed25519.Sign(key, cp.SignedData()))
+	return
+}
+
+// VerifyCheckpoint checks the signature of the checkpoint against the public key and that the transaction
+// file still has the same head at the line of the checkpoint. A file that was chained at the checkpoint must
+// still be chained, otherwise its header and links were stripped. Every file matches the checkpoint of an
+// empty, unchained file.
+func (r *RepoModule) VerifyCheckpoint(ctx context.Context, cp record.Checkpoint, pub ed25519.PublicKey) error {
+	sig, err := hex.DecodeString(cp.Signature)
+	if err != nil || !ed25519.Verify(pub, cp.SignedData(), sig) {
+		return fmt.Errorf("%w: invalid checkpoint signature", types.ChainBrokenError)
+	}
+	if cp.Lines == 0 && !cp.Chained {
+		return nil
+	}
+
+	if err = r.fileMtx.Lock(ctx); err != nil {
+		return err
+	}
+	defer r.fileMtx.Unlock()
+
+	var current record.Checkpoint
+	if current, err = r.verify(ctx, &cp.Lines); err != nil {
+		return err
+	}
+
+	if cp.Chained && !current.Chained {
+		return fmt.Errorf("%w: the file was chained at the checkpoint and no longer is", types.ChainBrokenError)
+	}
+
+	if current.Lines != cp.Lines {
+		return fmt.Errorf("%w: file has %d lines, the checkpoint covers %d", types.ChainBrokenError, current.Lines, cp.Lines)
+	}
+
+	if current.Head != cp.Head {
+		return fmt.Errorf("%w: line %d does not match the checkpoint", types.ChainBrokenError, cp.Lines)
+	}
+
+	return nil
+}
+
+// ReadSigningKey reads a hex encoded ed25519 seed from the file. When create is set and the file does not
+// exist, a new key is generated and written to it.
+func ReadSigningKey(path string, create bool) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		var key ed25519.PrivateKey
+		if _, key, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+
+		if err = os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())), 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var seed []byte
+	if seed, err = hex.DecodeString(string(b)); err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s: invalid signing key", path)
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
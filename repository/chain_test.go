@@ -0,0 +1,269 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appendRecords(t *testing.T, repo *repository.RepoModule, from, to int) {
+	recs := make([]record.TransactionRecord, 0)
+	for i := from; i < to; i++ {
+		recs = append(recs, record.TransactionRecord{
+			ID:          fmt.Sprintf("id%d", i),
+			Description: fmt.Sprintf("transaction %d", i),
+			Date:        record.FiscalDate(time.Date(2023, time.September, 1, 0, 0, 0, 0, time.UTC)),
+			Amount:      float64(i),
+		})
+	}
+
+	h, err := repo.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = h.Close()
+	}()
+
+	if _, err = h.AppendRecords(context.Background(), recs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepoModule_Verify(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	appendRecords(t, repository.New(app), 0, 15)
+	// a fresh module reads the head of the chain from the file
+	repo := repository.New(app)
+	appendRecords(t, repo, 15, 25)
+
+	cp, err := repo.Verify(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 25, cp.Lines)
+	}
+
+	h, err := repo.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]record.TransactionRecord, 10)
+	total := 0
+	for {
+		n, err := h.ReadRecords(context.Background(), buf)
+		if !assert.NoError(t, err) || n == 0 {
+			break
+		}
+		total += n
+	}
+	_ = h.Close()
+	assert.Equal(t, 25, total)
+
+	b, err := os.ReadFile(app.AppFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(b), `"amount":7`, `"amount":70`, 1)
+	if err = os.WriteFile(app.AppFilePath, []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.New(app).Verify(context.Background())
+	if assert.ErrorIs(t, err, types.ChainBrokenError) {
+		assert.Contains(t, err.Error(), "line 9")
+	}
+}
+
+func TestRepoModule_Checkpoint(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	repo := repository.New(app)
+	appendRecords(t, repo, 0, 5)
+
+	key, err := repository.ReadSigningKey(filepath.Join(dir, "checkpoint.key"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+
+	cp, err := repo.Checkpoint(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendRecords(t, repo, 5, 8)
+	assert.NoError(t, repo.VerifyCheckpoint(context.Background(), cp, pub))
+
+	forged := cp
+	forged.Lines = 4
+	assert.ErrorIs(t, repo.VerifyCheckpoint(context.Background(), forged, pub), types.ChainBrokenError)
+
+	b, err := os.ReadFile(app.AppFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if err = os.WriteFile(app.AppFilePath, []byte(strings.Join(lines[:3], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, repository.New(app).VerifyCheckpoint(context.Background(), cp, pub), types.ChainBrokenError)
+}
+
+func TestRepoModule_VerifyHeader(t *testing.T) {
+	dir := t.TempDir()
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	appendRecords(t, repository.New(app), 0, 3)
+
+	b, err := os.ReadFile(app.AppFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	assert.Equal(t, "{\"chain\":\"sha256\"}\n", lines[0])
+
+	unlinked := regexp.MustCompile(`,"prev":"[0-9a-f]+"`)
+	for name, content := range map[string]string{
+		// the header requires every line to be chained
+		"unlinked": unlinked.ReplaceAllString(string(b), ""),
+		// the first line is chained to the header
+		"headless": strings.Join(lines[1:], ""),
+	} {
+		if err = os.WriteFile(app.AppFilePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err = repository.New(app).Verify(context.Background())
+		if assert.ErrorIs(t, err, types.ChainBrokenError, name) {
+			assert.Contains(t, err.Error(), "line 1", name)
+		}
+	}
+
+	// a file written before the chain has neither the header nor the links, compacting it chains it
+	legacy := unlinked.ReplaceAllString(strings.Join(lines[1:], ""), "")
+	if err = os.WriteFile(app.AppFilePath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.New(app)
+	_, err = repo.Verify(context.Background())
+	assert.NoError(t, err)
+	_, _, err = repo.Compact(context.Background())
+	assert.NoError(t, err)
+	if b, err = os.ReadFile(app.AppFilePath); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(string(b), "{\"chain\":\"sha256\"}\n"))
+	assert.Len(t, readAll(t, repo), 3)
+}
+
+func TestRepoModule_CheckpointEmpty(t *testing.T) {
+	dir := t.TempDir()
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	if err := os.WriteFile(app.AppFilePath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := repository.ReadSigningKey(filepath.Join(dir, "checkpoint.key"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.New(app)
+	cp, err := repo.Checkpoint(context.Background(), key)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, cp.Lines)
+	}
+
+	appendRecords(t, repo, 0, 2)
+	assert.NoError(t, repo.VerifyCheckpoint(context.Background(), cp, key.Public().(ed25519.PublicKey)))
+}
+
+func TestRepoModule_CheckpointStripped(t *testing.T) {
+	dir := t.TempDir()
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	repo := repository.New(app)
+	appendRecords(t, repo, 0, 3)
+
+	key, err := repository.ReadSigningKey(filepath.Join(dir, "checkpoint.key"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+
+	cp, err := repo.Checkpoint(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, cp.Chained)
+
+	b, err := os.ReadFile(app.AppFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	stripped := regexp.MustCompile(`,"prev":"[0-9a-f]+"`).ReplaceAllString(strings.Join(lines[1:], ""), "")
+	if err = os.WriteFile(app.AppFilePath, []byte(stripped), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stripped file passes as one written before the chain, but not against the checkpoint
+	repo = repository.New(app)
+	current, err := repo.Verify(context.Background())
+	if assert.NoError(t, err) {
+		assert.False(t, current.Chained)
+	}
+	err = repo.VerifyCheckpoint(context.Background(), cp, pub)
+	if assert.ErrorIs(t, err, types.ChainBrokenError) {
+		assert.Contains(t, err.Error(), "no longer")
+	}
+}
+
+func TestRepoModule_CheckpointHeaderOnly(t *testing.T) {
+	dir := t.TempDir()
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	if err := os.WriteFile(app.AppFilePath, []byte("{\"chain\":\"sha256\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := repository.ReadSigningKey(filepath.Join(dir, "checkpoint.key"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.New(app)
+	cp, err := repo.Checkpoint(context.Background(), key)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, cp.Lines)
+		assert.True(t, cp.Chained)
+	}
+
+	// a checkpoint of no lines still requires the header
+	if err = os.WriteFile(app.AppFilePath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = repository.New(app).VerifyCheckpoint(context.Background(), cp, key.Public().(ed25519.PublicKey))
+	assert.ErrorIs(t, err, types.ChainBrokenError)
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Close waits for the writes in progress and flushes the transaction file, its sidecar files and their
+// directory to the disk, then lets the other processes use the file, see LockFile. The repository stays
+// locked afterwards: a later write waits until its context is done. The wait for the writes in progress
+// ends when ctx is done, the files are neither flushed nor unlocked then.
+func (r *RepoModule) Close(ctx context.Context) error {
+	if err := r.fileMtx.Lock(ctx); err != nil {
+		return err
+	}
+	if err := r.sidecarMtx.Lock(ctx); err != nil {
+		r.fileMtx.Unlock()
+		return err
+	}
+	if err := r.auditMtx.Lock(ctx); err != nil {
+		r.sidecarMtx.Unlock()
+		r.fileMtx.Unlock()
+		return err
+	}
+
+	if r.config.SkipFile() {
+		return nil
+	}
+
+	defer func() {
+		_ = r.unlockFile()
+	}()
+
+	paths := []string{
+		r.config.FilePath(),
+		r.sidecarPath("budgets"),
+		r.sidecarPath("schedules"),
+		r.sidecarPath("audit"),
+		filepath.Dir(r.config.FilePath()),
+	}
+
+	var errs []error
+	for _, p := range paths {
+		if err := syncFile(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// syncFile flushes the file, or the directory, at path to the disk. A missing file has nothing to flush.
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
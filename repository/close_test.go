@@ -0,0 +1,69 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRepoModule_Close(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	assert.NoError(t, os.WriteFile(app.AppFilePath, []byte("{}\n"), 0o600))
+	repo := repository.New(app)
+
+	h, err := repo.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the handle in use holds the repository until the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, repo.Close(ctx), types.CanceledError)
+
+	assert.NoError(t, h.Close())
+	assert.NoError(t, repo.Close(context.Background()))
+
+	// nothing is written once the repository is closed
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = repo.Open(ctx)
+	assert.ErrorIs(t, err, types.CanceledError)
+}
+
+func TestRepoModule_LockFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	first, second := repository.New(app), repository.New(app)
+
+	if !assert.NoError(t, first.LockFile()) {
+		return
+	}
+	assert.ErrorContains(t, second.LockFile(), "in use by another process")
+
+	assert.NoError(t, first.Close(context.Background()))
+	if assert.NoError(t, second.LockFile()) {
+		assert.NoError(t, second.Close(context.Background()))
+	}
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"io/fs"
+	"os"
+)
+
+// sealedLine is an encrypted line of a JSON-lines file other than the transaction file.
+type sealedLine struct {
+	KeyID      string `json:"kid,omitempty"`
+	Ciphertext string `json:"ct,omitempty"`
+}
+
+// encodeLine returns the line of the transaction file for the record, sealed with the primary key when a
+// keyring is configured.
+func (r *RepoModule) encodeLine(rec record.TransactionRecord, prev string) ([]byte, error) {
+	k := r.config.Keyring()
+	if k == nil {
+		return json.Marshal(&chainedLine{TransactionRecord: &rec, Prev: prev})
+	}
+
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		return nil, err
+	}
+
+	line := chainedLine{Prev: prev}
+	line.KeyID, line.Ciphertext = k.Seal(b)
+	return json.Marshal(&line)
+}
+
+// decodeLine reads a line of the transaction file, cleartext or sealed with any key of the keyring.
+func (r *RepoModule) decodeLine(raw []byte) (rec record.TransactionRecord, prev string, err error) {
+	var line chainedLine
+	if err = json.Unmarshal(raw, &line); err != nil {
+		return
+	}
+	prev = line.Prev
+
+	if line.Ciphertext == "" {
+		if line.TransactionRecord != nil {
+			rec = *line.TransactionRecord
+		}
+		return
+	}
+
+	var b []byte
+	if b, err = r.open(line.KeyID, line.Ciphertext); err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &rec)
+	return
+}
+
+func (r *RepoModule) sealJSON(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || r.config.Keyring() == nil {
+		return b, err
+	}
+
+	var line sealedLine
+	line.KeyID, line.Ciphertext = r.config.Keyring().Seal(b)
+	return json.Marshal(&line)
+}
+
+func (r *RepoModule) openJSON(raw []byte, v any) error {
+	// a cleartext value is not necessarily an object, e.g. the list of a sidecar file
+	var line sealedLine
+	if err := json.Unmarshal(raw, &line); err != nil || line.Ciphertext == "" {
+		return json.Unmarshal(raw, v)
+	}
+
+	b, err := r.open(line.KeyID, line.Ciphertext)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func (r *RepoModule) open(keyID, ciphertext string) ([]byte, error) {
+	k := r.config.Keyring()
+	if k == nil {
+		return nil, fmt.Errorf("encrypted record with key %q but no keyring configured", keyID)
+	}
+
+	return k.Open(keyID, ciphertext)
+}
+
+// Reencrypt rewrites the transaction file and its sidecar files, the audit log included, with the primary
+// key of the keyring, or in cleartext without a keyring. It is used after a key rotation, before the old key
+// is removed from the keyring. It returns the number of transactions. The hash chain is rebuilt, so
+// checkpoints taken before are no longer valid.
+func (r *RepoModule) Reencrypt(ctx context.Context) (int, error) {
+	n, _, err := r.rewrite(ctx, "reencrypting the transaction file", nil)
+	if err != nil {
+		return n, err
+	}
+
+	if err = r.reencryptSidecars(ctx, "budgets", "schedules"); err != nil {
+		return n, err
+	}
+
+	return n, r.reencryptAudit(ctx)
+}
+
+func (r *RepoModule) reencryptSidecars(ctx context.Context, names ...string) error {
+	if err := r.sidecarMtx.Lock(ctx); err != nil {
+		return err
+	}
+	defer r.sidecarMtx.Unlock()
+
+	for _, name := range names {
+		var v json.RawMessage
+		if err := r.readSidecar(name, &v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if v == nil {
+			continue
+		}
+
+		if err := r.writeSidecar(name, v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// reencryptAudit rewrites the audit log line by line. The log replaces the old one once complete; when ctx
+// is done or a line is invalid, the log is left as it was.
+func (r *RepoModule) reencryptAudit(ctx context.Context) error {
+	if err := r.auditMtx.Lock(ctx); err != nil {
+		return err
+	}
+	defer r.auditMtx.Unlock()
+
+	path := r.sidecarPath("audit")
+	src, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	var (
+		out  bytes.Buffer
+		b    []byte
+		line int
+		scan = newLineScanner(src)
+	)
+	for scan.Scan() {
+		if ctx.Err() != nil {
+			return types.Canceled(ctx, "reencrypting the audit log")
+		}
+		line++
+
+		var event json.RawMessage
+		if err = r.openJSON(scan.Bytes(), &event); err != nil {
+			return fmt.Errorf("audit line %d: %w", line, err)
+		}
+		if b, err = r.sealJSON(event); err != nil {
+			return fmt.Errorf("audit line %d: %w", line, err)
+		}
+
+		out.Write(b)
+		out.WriteByte('\n')
+	}
+	if err = scan.Err(); err != nil {
+		return err
+	}
+
+	return writeFile(path, out.Bytes())
+}
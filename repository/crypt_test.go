@@ -0,0 +1,117 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/keyring"
+	"github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newKeyring(t *testing.T, spec ...string) *keyring.Keyring {
+	k, err := keyring.Parse(strings.Join(spec, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func readAll(t *testing.T, repo *repository.RepoModule) []record.TransactionRecord {
+	h, err := repo.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = h.Close()
+	}()
+
+	recs := make([]record.TransactionRecord, 0)
+	buf := make([]record.TransactionRecord, 10)
+	for {
+		n, err := h.ReadRecords(context.Background(), buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			return recs
+		}
+		recs = append(recs, buf[:n]...)
+	}
+}
+
+func TestRepoModule_Encryption(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	key1, _ := keyring.Generate("k1")
+	key2, _ := keyring.Generate("k2")
+
+	app := &transactiondemo.App{
+		AppFilePath: filepath.Join(dir, "data.json"),
+		AppKeyring:  newKeyring(t, key1),
+	}
+	repo := repository.New(app)
+	appendRecords(t, repo, 0, 3)
+
+	b, err := os.ReadFile(app.AppFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, string(b), "transaction 1")
+	assert.Contains(t, string(b), `"kid":"k1"`)
+	assert.Len(t, readAll(t, repo), 3)
+
+	_, err = repo.Verify(context.Background())
+	assert.NoError(t, err)
+
+	// the sidecar files are sealed too
+	budgets := []record.Budget{{ID: "b1", Category: "groceries", Currency: "USD", Amount: 400}}
+	assert.NoError(t, repo.WriteBudgets(context.Background(), budgets))
+	assert.NoError(t, repo.AppendAuditEvent(context.Background(), record.AuditEvent{
+		ID: "e1", TransactionID: "t1", Action: "create", Actor: "alice",
+	}))
+	for _, name := range []string{"budgets", "audit"} {
+		if b, err = os.ReadFile(filepath.Join(dir, "data."+name+".json")); err != nil {
+			t.Fatal(err)
+		}
+		assert.NotContains(t, string(b), "groceries")
+		assert.NotContains(t, string(b), "alice")
+		assert.Contains(t, string(b), `"kid":"k1"`)
+	}
+
+	app.AppKeyring = newKeyring(t, key2, key1)
+	appendRecords(t, repo, 3, 4)
+	n, err := repo.Reencrypt(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, n)
+	}
+
+	app.AppKeyring = newKeyring(t, key2)
+	rotated := repository.New(app)
+	recs := readAll(t, rotated)
+	if assert.Len(t, recs, 4) {
+		assert.Equal(t, "transaction 3", recs[3].Description)
+	}
+
+	got, err := rotated.ReadBudgets(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, budgets, got)
+	}
+	events, err := rotated.ReadAuditEvents(context.Background(), "t1")
+	if assert.NoError(t, err) && assert.Len(t, events, 1) {
+		assert.Equal(t, "alice", events[0].Actor)
+	}
+
+	_, err = repo.Verify(context.Background())
+	assert.NoError(t, err)
+}
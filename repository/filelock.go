@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+)
+
+// LockFile keeps the other processes from using the transaction file until Close, so that a server and a
+// command working on the file directly do not run together. It fails at once when another process holds
+// the file.
+func (r *RepoModule) LockFile() error {
+	if r.config.SkipFile() {
+		return nil
+	}
+
+	f, err := os.OpenFile(r.config.FilePath()+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err = lockFile(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("%s is in use by another process: %w", r.config.FilePath(), err)
+	}
+
+	r.lockFile = f
+	return nil
+}
+
+// unlockFile lets the other processes use the transaction file again.
+func (r *RepoModule) unlockFile() error {
+	if r.lockFile == nil {
+		return nil
+	}
+
+	err := r.lockFile.Close()
+	r.lockFile = nil
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package repository
+
+import "os"
+
+// lockFile does nothing, the other systems have no advisory lock: the transaction file is not protected
+// from the other processes there.
+func lockFile(*os.File) error {
+	return nil
+}
@@ -1,28 +1,52 @@
 package repository
 
 import (
+	"context"
+	"github.com/suyono3484/transactiondemo/tracing"
 	"github.com/suyono3484/transactiondemo/transaction/record"
 	"github.com/suyono3484/transactiondemo/types"
 	"sync"
 	"time"
 )
 
+// otherCurrency is the currency label of the cache lookups of a currency not in the cache.
+const otherCurrency = "other"
+
 type fiscalCache struct {
 	createdAt time.Time
 	table     map[string]map[record.FiscalDate]float64
 	mtx       *sync.RWMutex
 }
 
-func (r *RepoModule) CacheGetExchangeRate(cDesc string, start, txDate time.Time) (date record.FiscalDate, rate float64, err error) {
+func (r *RepoModule) CacheGetExchangeRate(ctx context.Context, cDesc string, start, txDate time.Time) (date record.FiscalDate, rate float64, err error) {
+	_, span := tracing.Start(ctx, "RepoModule.CacheGetExchangeRate", tracing.KindInternal)
+	span.SetAttribute("currency", cDesc)
+	defer span.End()
+
 	r.fiscalCache.mtx.RLock()
 	defer r.fiscalCache.mtx.RUnlock()
 
+	defer func() {
+		// the currency comes from the client, only the currencies the provider answered for are labels
+		label := otherCurrency
+		if _, known := r.fiscalCache.table[cDesc]; known {
+			label = cDesc
+		}
+
+		span.SetAttribute("cache.hit", err == nil)
+		if err == nil {
+			r.metrics.cacheHits.Inc(label)
+		} else {
+			r.metrics.cacheMisses.Inc(label)
+		}
+	}()
+
 	var (
 		ok       bool
 		currency map[record.FiscalDate]float64
 	)
 
-	if time.Now().Add(-12 * time.Hour).After(r.fiscalCache.createdAt) {
+	if time.Since(r.fiscalCache.createdAt) >= r.cacheTTL() {
 		err = types.CacheNoDataError
 		return
 	}
@@ -43,6 +67,11 @@ func (r *RepoModule) CacheGetExchangeRate(cDesc string, start, txDate time.Time)
 		}
 	}
 
+	// the currency is cached, but not for the period of this transaction
+	if rate == 0 {
+		err = types.CacheNoDataError
+	}
+
 	return
 }
 
@@ -50,7 +79,10 @@ func (r *RepoModule) CacheSetExchangeRate(cDesc string, date record.FiscalDate,
 	r.fiscalCache.mtx.Lock()
 	defer r.fiscalCache.mtx.Unlock()
 
-	if time.Now().Add(-12 * time.Hour).After(r.fiscalCache.createdAt) {
+	if time.Since(r.fiscalCache.createdAt) >= r.cacheTTL() {
+		for currency, rates := range r.fiscalCache.table {
+			r.metrics.cacheEvictions.Add(float64(len(rates)), currency)
+		}
 		r.fiscalCache.table = make(map[string]map[record.FiscalDate]float64)
 		r.fiscalCache.createdAt = time.Now()
 	}
@@ -67,3 +99,12 @@ func (r *RepoModule) CacheSetExchangeRate(cDesc string, date record.FiscalDate,
 	currency[date] = rate
 	r.fiscalCache.table[cDesc] = currency
 }
+
+// cacheTTL returns the time the exchange rates are cached for. The whole cache expires at once.
+func (r *RepoModule) cacheTTL() time.Duration {
+	if ttl := r.rateConfig.CacheTTL(); ttl > 0 {
+		return ttl
+	}
+
+	return types.DefaultCacheTTL
+}
@@ -1,10 +1,14 @@
 package repository_test
 
 import (
+	"bytes"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/metrics"
 	"github.com/suyono3484/transactiondemo/repository"
 	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
 	"testing"
 	"time"
 )
@@ -21,7 +25,7 @@ func TestCache(t *testing.T) {
 	repo.CacheSetExchangeRate(cDesc, record.FiscalDate(date1), rate1)
 	repo.CacheSetExchangeRate(cDesc, record.FiscalDate(date2), rate2)
 
-	d, r, e := repo.CacheGetExchangeRate(cDesc, time.Now().AddDate(0, -6, 0), time.Now())
+	d, r, e := repo.CacheGetExchangeRate(context.Background(), cDesc, time.Now().AddDate(0, -6, 0), time.Now())
 	if e != nil {
 		t.Fatal(e)
 	}
@@ -29,3 +33,28 @@ func TestCache(t *testing.T) {
 	assert.Equal(t, record.FiscalDate(date2), d)
 	assert.Equal(t, rate2, r)
 }
+
+func TestCache_Metrics(t *testing.T) {
+	app := &transactiondemo.App{AppMetrics: metrics.NewRegistry()}
+	repo := repository.New(app)
+
+	date := time.Now().AddDate(0, 0, -7)
+	repo.CacheSetExchangeRate("Canada-Dollar", record.FiscalDate(date), 1.35)
+
+	_, _, err := repo.CacheGetExchangeRate(context.Background(), "Canada-Dollar", time.Now().AddDate(0, -6, 0), time.Now())
+	assert.NoError(t, err)
+	_, _, err = repo.CacheGetExchangeRate(context.Background(), "Euro Zone-Euro", time.Now().AddDate(0, -6, 0), time.Now())
+	assert.ErrorIs(t, err, types.CacheNoDataError)
+	// cached, but not for this period
+	_, _, err = repo.CacheGetExchangeRate(context.Background(), "Canada-Dollar", time.Now().AddDate(0, -6, 0), time.Now().AddDate(0, -1, 0))
+	assert.ErrorIs(t, err, types.CacheNoDataError)
+
+	var b bytes.Buffer
+	if err = app.AppMetrics.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, b.String(), `transactiondemo_rate_cache_hits_total{currency="Canada-Dollar"} 1`)
+	assert.Contains(t, b.String(), `transactiondemo_rate_cache_misses_total{currency="Canada-Dollar"} 1`)
+	assert.NotContains(t, b.String(), "Euro Zone-Euro")
+	assert.Contains(t, b.String(), `transactiondemo_rate_cache_misses_total{currency="other"} 1`)
+}
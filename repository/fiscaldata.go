@@ -1,10 +1,15 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/tracing"
 	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -13,7 +18,26 @@ type RecordContainer struct {
 	Data []record.FiscalRecord `json:"data"`
 }
 
-func (r *RepoModule) FetchFiscalData(cDesc string, start, txDate time.Time) ([]record.FiscalRecord, error) {
+// FetchFiscalData requests the exchange rates of the currency effective between start and txDate from the
+// rate provider, the latest first. The trace context of ctx is passed on in the traceparent header, and the
+// request is aborted with a types.CanceledError when ctx is done.
+func (r *RepoModule) FetchFiscalData(ctx context.Context, cDesc string, start, txDate time.Time) ([]record.FiscalRecord, error) {
+	ctx, span := tracing.Start(ctx, "RepoModule.FetchFiscalData", tracing.KindClient)
+	span.SetAttribute("currency", cDesc)
+	defer span.End()
+
+	begin := time.Now()
+	recs, err := r.fetchFiscalData(ctx, cDesc, start, txDate)
+	r.metrics.fetchDuration.Observe(time.Since(begin).Seconds())
+	if err != nil {
+		r.metrics.fetchErrors.Inc()
+		span.RecordError(err)
+	}
+
+	return recs, err
+}
+
+func (r *RepoModule) fetchFiscalData(ctx context.Context, cDesc string, start, txDate time.Time) ([]record.FiscalRecord, error) {
 	sortParam := "-record_date"
 	fieldsParam := "record_date,country,currency,country_currency_desc,exchange_rate,effective_date"
 	dateRangeFilter := fmt.Sprintf("effective_date:gte:%s,effective_date:lte:%s",
@@ -22,35 +46,74 @@ func (r *RepoModule) FetchFiscalData(cDesc string, start, txDate time.Time) ([]r
 	filterParam := fmt.Sprintf("%s,%s", currencyFilter, dateRangeFilter)
 
 	var container RecordContainer
-	url := fmt.Sprintf("%s?sort=%s&fields=%s&filter=%s", r.config.ExchangeRateURL(), sortParam, fieldsParam, filterParam)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	url := fmt.Sprintf("%s?sort=%s&fields=%s&filter=%s", r.rateConfig.ExchangeRateURL(), sortParam, fieldsParam, filterParam)
+	b, err := r.getRates(ctx, url, logging.FromContext(ctx).With("upstream", "exchange_rates", "currency", cDesc))
 	if err != nil {
 		return container.Data, err
 	}
 
-	client := &http.Client{}
-	var resp *http.Response
-	resp, err = client.Do(req)
-	if err != nil {
+	if err = json.Unmarshal(b, &container); err != nil {
 		return container.Data, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return container.Data, fmt.Errorf("HTTP Status not OK: %d", resp.StatusCode)
+	return container.Data, nil
+}
+
+// getRates requests the URL from the rate provider and returns the body of the response. The outcome is
+// recorded for the status of the provider, unless ctx is done.
+func (r *RepoModule) getRates(ctx context.Context, url string, logger *slog.Logger) (b []byte, err error) {
+	defer func() {
+		if ctx.Err() == nil {
+			r.upstream.record(err)
+		}
+	}()
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil); err != nil {
+		return
+	}
+
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", r.rateConfig.ExchangeRateURL())
+	if sc := span.SpanContext(); sc.IsValid() {
+		req.Header.Set(tracing.TraceparentHeader, sc.Traceparent())
 	}
 
-	var b []byte
-	b, err = io.ReadAll(resp.Body)
+	var (
+		client = &http.Client{Timeout: r.upstreamTimeout()}
+		begin  = time.Now()
+		resp   *http.Response
+	)
+	resp, err = client.Do(req)
 	if err != nil {
-		return container.Data, err
+		if ctx.Err() != nil {
+			return nil, types.Canceled(ctx, "fetching the exchange rates")
+		}
+		logger.Warn("upstream call failed", "duration", time.Since(begin), "error", err)
+		return
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	logger.Info("upstream call", "status", resp.StatusCode, "duration", time.Since(begin))
 
-	if err = json.Unmarshal(b, &container); err != nil {
-		return container.Data, err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Status not OK: %d", resp.StatusCode)
 	}
 
-	return container.Data, nil
+	if b, err = io.ReadAll(resp.Body); err != nil && ctx.Err() != nil {
+		return nil, types.Canceled(ctx, "fetching the exchange rates")
+	}
+
+	return
+}
+
+func (r *RepoModule) upstreamTimeout() time.Duration {
+	if d := r.rateConfig.UpstreamTimeout(); d > 0 {
+		return d
+	}
+
+	return types.DefaultUpstreamTimeout
 }
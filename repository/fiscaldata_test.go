@@ -1,11 +1,14 @@
 package repository_test
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/suyono3484/transactiondemo"
 	"github.com/suyono3484/transactiondemo/repository"
 	"github.com/suyono3484/transactiondemo/transaction/record"
 	"github.com/suyono3484/transactiondemo/types"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -57,7 +60,7 @@ func TestRepoModule_FetchFiscalData(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.FetchFiscalData(tt.args.cDesc, tt.args.start, tt.args.txDate)
+			got, err := repo.FetchFiscalData(context.Background(), tt.args.cDesc, tt.args.start, tt.args.txDate)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FetchFiscalData() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -66,3 +69,18 @@ func TestRepoModule_FetchFiscalData(t *testing.T) {
 		})
 	}
 }
+
+func TestRepoModule_FetchFiscalDataCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	repo := repository.New(&transactiondemo.App{AppExchangeRateURL: srv.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := repo.FetchFiscalData(ctx, "Canada-Dollar", time.Now().AddDate(0, -6, 0), time.Now())
+	assert.ErrorIs(t, err, types.CanceledError)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
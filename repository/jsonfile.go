@@ -2,7 +2,7 @@ package repository
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/suyono3484/transactiondemo/transaction/record"
@@ -23,15 +23,22 @@ type Handle struct {
 	activeFileHandle *os.File
 	fileHandleState  handleState
 	module           *RepoModule
+	scan             *bufio.Scanner
+	chain            chainState
 }
 
-func (r *RepoModule) Open() types.RepoHandle {
-	r.fileMtx.Lock()
+// Open locks the transaction file for the handle until it is closed. The wait for the lock ends when ctx
+// is done.
+func (r *RepoModule) Open(ctx context.Context) (types.RepoHandle, error) {
+	if err := r.fileMtx.Lock(ctx); err != nil {
+		return nil, err
+	}
+
 	return &Handle{
 		activeFileHandle: nil,
 		fileHandleState:  idle,
 		module:           r,
-	}
+	}, nil
 }
 
 func (h *Handle) Close() (err error) {
@@ -46,7 +53,9 @@ func (h *Handle) Close() (err error) {
 	return
 }
 
-func (h *Handle) ReadRecords(records []record.TransactionRecord) (int, error) {
+// ReadRecords reads the next records of the transaction file into records, up to their capacity. It stops
+// with a types.CanceledError when ctx is done.
+func (h *Handle) ReadRecords(ctx context.Context, records []record.TransactionRecord) (int, error) {
 	if h.module.config.SkipFile() || cap(records) == 0 {
 		return 0, nil
 	}
@@ -57,8 +66,8 @@ func (h *Handle) ReadRecords(records []record.TransactionRecord) (int, error) {
 
 	var (
 		err   error
-		scan  *bufio.Scanner
 		rec   record.TransactionRecord
+		prev  string
 		index int
 	)
 
@@ -71,12 +80,24 @@ func (h *Handle) ReadRecords(records []record.TransactionRecord) (int, error) {
 			return 0, err
 		}
 		h.fileHandleState = read
+		h.scan = newLineScanner(h.activeFileHandle)
 	}
 
-	scan = bufio.NewScanner(h.activeFileHandle)
 scanLoop:
-	for scan.Scan() {
-		if err = json.Unmarshal(scan.Bytes(), &rec); err != nil {
+	for h.scan.Scan() {
+		if ctx.Err() != nil {
+			return index, types.Canceled(ctx, "reading the transaction file")
+		}
+
+		if h.chain.header(h.scan.Bytes()) {
+			continue
+		}
+
+		if rec, prev, err = h.module.decodeLine(h.scan.Bytes()); err != nil {
+			return index, fmt.Errorf("line %d: %w", h.chain.line+1, err)
+		}
+
+		if err = h.chain.next(h.scan.Bytes(), prev); err != nil {
 			return index, err
 		}
 
@@ -87,14 +108,16 @@ scanLoop:
 		}
 	}
 
-	if err = scan.Err(); err != nil {
+	if err = h.scan.Err(); err != nil {
 		return index, err
 	}
 
 	return index, err
 }
 
-func (h *Handle) AppendRecords(records []record.TransactionRecord) (int, error) {
+// AppendRecords appends the records to the transaction file. Once the first record is written, the others
+// are written too whatever ctx, so a transaction is never stored partially.
+func (h *Handle) AppendRecords(ctx context.Context, records []record.TransactionRecord) (int, error) {
 	if h.module.config.SkipFile() || len(records) == 0 {
 		return 0, nil
 	}
@@ -108,8 +131,13 @@ func (h *Handle) AppendRecords(records []record.TransactionRecord) (int, error)
 		index int
 		rec   record.TransactionRecord
 		bb    []byte
+		head  string
 	)
 
+	if head, err = h.module.chainHead(ctx); err != nil {
+		return 0, err
+	}
+
 	if h.activeFileHandle == nil {
 		h.activeFileHandle, err = os.OpenFile(h.module.config.FilePath(), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 		if err != nil {
@@ -118,14 +146,28 @@ func (h *Handle) AppendRecords(records []record.TransactionRecord) (int, error)
 		h.fileHandleState = write
 	}
 
+	if head == "" {
+		if _, err = fmt.Fprintln(h.activeFileHandle, string(chainHeader)); err != nil {
+			h.module.headKnown = false
+			return 0, err
+		}
+		head = lineHash(chainHeader)
+		h.module.head = head
+	}
+
 	for index, rec = range records {
-		if bb, err = json.Marshal(&rec); err != nil {
+		if bb, err = h.module.encodeLine(rec, head); err != nil {
 			return index, err
 		}
 
 		if _, err = fmt.Fprintln(h.activeFileHandle, string(bb)); err != nil {
+			// the file may end with a partial line now, let the next append read the head again
+			h.module.headKnown = false
 			return index, err
 		}
+
+		head = lineHash(bb)
+		h.module.head = head
 	}
 	index = len(records)
 
@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"github.com/suyono3484/transactiondemo/keyring"
+	"github.com/suyono3484/transactiondemo/lock"
+	"github.com/suyono3484/transactiondemo/metrics"
 	"github.com/suyono3484/transactiondemo/transaction/record"
+	"os"
 	"sync"
 	"time"
 )
@@ -10,22 +14,75 @@ type Config interface {
 	FilePath() string
 	SkipFile() bool
 	ExchangeRateURL() string
+	CacheTTL() time.Duration
+	UpstreamTimeout() time.Duration
+	Keyring() *keyring.Keyring
+	Metrics() *metrics.Registry
 }
 
 type RepoModule struct {
 	config      Config
-	fileMtx     *sync.Mutex
+	rateConfig  Config
+	fileMtx     *lock.Mutex
+	head        string
+	headKnown   bool
+	sidecarMtx  *lock.Mutex
+	auditMtx    *lock.Mutex
+	auditLog    []record.AuditEvent
 	fiscalCache *fiscalCache
+	upstream    *upstreamStatus
+	metrics     repoMetrics
+	lockFile    *os.File
+}
+
+// repoMetrics are the metrics of the exchange rate cache and the rate provider. They record nothing when
+// the configuration has no registry.
+type repoMetrics struct {
+	cacheHits      *metrics.Counter
+	cacheMisses    *metrics.Counter
+	cacheEvictions *metrics.Counter
+	fetchDuration  *metrics.Histogram
+	fetchErrors    *metrics.Counter
+}
+
+func newRepoMetrics(reg *metrics.Registry) repoMetrics {
+	return repoMetrics{
+		cacheHits: reg.Counter("transactiondemo_rate_cache_hits_total",
+			"Exchange rates found in the cache.", "currency"),
+		cacheMisses: reg.Counter("transactiondemo_rate_cache_misses_total",
+			"Exchange rates missing from the cache.", "currency"),
+		cacheEvictions: reg.Counter("transactiondemo_rate_cache_evictions_total",
+			"Exchange rates dropped from the cache when it expired.", "currency"),
+		fetchDuration: reg.Histogram("transactiondemo_rate_fetch_duration_seconds",
+			"Duration of the requests to the exchange rate provider.", nil),
+		fetchErrors: reg.Counter("transactiondemo_rate_fetch_errors_total",
+			"Failed requests to the exchange rate provider."),
+	}
 }
 
 func New(config Config) *RepoModule {
 	return &RepoModule{
-		config:  config,
-		fileMtx: &sync.Mutex{},
+		config:     config,
+		rateConfig: config,
+		fileMtx:    lock.NewMutex(),
+		sidecarMtx: lock.NewMutex(),
+		auditMtx:   lock.NewMutex(),
 		fiscalCache: &fiscalCache{
 			createdAt: time.Now(),
 			table:     make(map[string]map[record.FiscalDate]float64),
 			mtx:       &sync.RWMutex{},
 		},
+		upstream: &upstreamStatus{},
+		metrics:  newRepoMetrics(config.Metrics()),
 	}
 }
+
+// ForTenant returns a repository for the files of config sharing the exchange rate cache of r, the status
+// of the rate provider and the configuration of the exchange rates, the rates do not depend on the tenant.
+func (r *RepoModule) ForTenant(config Config) *RepoModule {
+	t := New(config)
+	t.rateConfig = r.rateConfig
+	t.fiscalCache = r.fiscalCache
+	t.upstream = r.upstream
+	return t
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+)
+
+// Compact rewrites the transaction file without the lines repeating a transaction of a later line, e.g.
+// written by two processes sharing the file before it was locked, see LockFile. Like Load, it keeps the
+// last line of each transaction. It returns the number of transactions kept and of lines dropped. The hash
+// chain is rebuilt, so checkpoints taken before are no longer valid.
+func (r *RepoModule) Compact(ctx context.Context) (kept, dropped int, err error) {
+	const op = "compacting the transaction file"
+	if err = r.fileMtx.Lock(ctx); err != nil {
+		return
+	}
+	defer r.fileMtx.Unlock()
+
+	var last map[string]int
+	if last, err = r.lastLines(ctx, op); err != nil {
+		return
+	}
+
+	var line int
+	return r.rewriteLocked(ctx, op, func(rec record.TransactionRecord) bool {
+		line++
+		return last[rec.ID] == line
+	})
+}
+
+// lastLines returns the number of the last line of each transaction of the transaction file. The caller
+// holds fileMtx.
+func (r *RepoModule) lastLines(ctx context.Context, op string) (map[string]int, error) {
+	f, err := os.Open(r.config.FilePath())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var (
+		chain chainState
+		rec   record.TransactionRecord
+		last  = make(map[string]int)
+		scan  = newLineScanner(f)
+	)
+	for scan.Scan() {
+		if ctx.Err() != nil {
+			return nil, types.Canceled(ctx, op)
+		}
+
+		if chain.header(scan.Bytes()) {
+			continue
+		}
+		chain.line++
+
+		if rec, _, err = r.decodeLine(scan.Bytes()); err != nil {
+			return nil, fmt.Errorf("line %d: %w", chain.line, err)
+		}
+		last[rec.ID] = chain.line
+	}
+
+	return last, scan.Err()
+}
+
+// rewrite writes the records of the transaction file kept by keep, all of them when keep is nil, to a new
+// transaction file encrypted with the primary key and chained from its header. The file replaces the
+// transaction file once complete; when ctx is done or a line is invalid, the transaction file is left as it
+// was. It returns the number of records written and dropped.
+func (r *RepoModule) rewrite(ctx context.Context, op string, keep func(rec record.TransactionRecord) bool) (n, dropped int, err error) {
+	if err = r.fileMtx.Lock(ctx); err != nil {
+		return
+	}
+	defer r.fileMtx.Unlock()
+
+	return r.rewriteLocked(ctx, op, keep)
+}
+
+// rewriteLocked is rewrite for a caller holding fileMtx.
+func (r *RepoModule) rewriteLocked(ctx context.Context, op string, keep func(rec record.TransactionRecord) bool) (n, dropped int, err error) {
+	path := r.config.FilePath()
+	var src *os.File
+	if src, err = os.Open(path); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	tmp := path + ".tmp"
+	var dst *os.File
+	if dst, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return
+	}
+	defer func() {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+	}()
+
+	var (
+		chain chainState
+		rec   record.TransactionRecord
+		prev  string
+		b     []byte
+		head  = lineHash(chainHeader)
+		scan  = newLineScanner(src)
+	)
+	if _, err = fmt.Fprintln(dst, string(chainHeader)); err != nil {
+		return
+	}
+	for scan.Scan() {
+		if ctx.Err() != nil {
+			// the temporary file is removed, the transaction file is left as it was
+			err = types.Canceled(ctx, op)
+			return
+		}
+
+		if chain.header(scan.Bytes()) {
+			continue
+		}
+
+		if rec, prev, err = r.decodeLine(scan.Bytes()); err != nil {
+			err = fmt.Errorf("line %d: %w", chain.line+1, err)
+			return
+		}
+
+		// refuse to launder a tampered file into a fresh chain
+		if err = chain.next(scan.Bytes(), prev); err != nil {
+			return
+		}
+
+		if keep != nil && !keep(rec) {
+			dropped++
+			continue
+		}
+
+		if b, err = r.encodeLine(rec, head); err != nil {
+			return
+		}
+
+		if _, err = fmt.Fprintln(dst, string(b)); err != nil {
+			return
+		}
+		head = lineHash(b)
+		n++
+	}
+	if err = scan.Err(); err != nil {
+		return
+	}
+
+	if err = dst.Sync(); err != nil {
+		return
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return
+	}
+
+	r.head, r.headKnown = head, true
+	return
+}
@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoModule_Compact(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	repo := repository.New(app)
+	appendRecords(t, repo, 0, 3)
+	appendRecords(t, repo, 1, 4)
+
+	kept, dropped, err := repo.Compact(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, kept)
+		assert.Equal(t, 2, dropped)
+	}
+
+	recs := readAll(t, repository.New(app))
+	if assert.Len(t, recs, 4) {
+		assert.Equal(t, "transaction 3", recs[3].Description)
+	}
+
+	_, err = repo.Verify(context.Background())
+	assert.NoError(t, err)
+
+	// the last line of a transaction is kept, as Load does
+	h, err := repo.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.AppendRecords(context.Background(), []record.TransactionRecord{{ID: "id1", Description: "updated"}})
+	_ = h.Close()
+	assert.NoError(t, err)
+	_, dropped, err = repo.Compact(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, dropped)
+	}
+	recs = readAll(t, repository.New(app))
+	if assert.Len(t, recs, 4) {
+		assert.Equal(t, "updated", recs[3].Description)
+	}
+
+	// nothing is left to drop, and the file can still be appended to
+	_, dropped, err = repo.Compact(context.Background())
+	if assert.NoError(t, err) {
+		assert.Zero(t, dropped)
+	}
+	appendRecords(t, repo, 4, 5)
+	_, err = repo.Verify(context.Background())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+)
+
+func (r *RepoModule) ReadSchedules(ctx context.Context) ([]record.Schedule, error) {
+	if r.config.SkipFile() {
+		return nil, nil
+	}
+
+	if err := r.sidecarMtx.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.sidecarMtx.Unlock()
+
+	var schedules []record.Schedule
+	if err := r.readSidecar("schedules", &schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r *RepoModule) WriteSchedules(ctx context.Context, schedules []record.Schedule) error {
+	if r.config.SkipFile() {
+		return nil
+	}
+
+	if err := r.sidecarMtx.Lock(ctx); err != nil {
+		return err
+	}
+	defer r.sidecarMtx.Unlock()
+
+	return r.writeSidecar("schedules", schedules)
+}
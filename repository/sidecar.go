@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sidecarPath returns the path of a file stored alongside the transaction file, e.g. data.schedules.json
+// for data.json and the name schedules.
+func (r *RepoModule) sidecarPath(name string) string {
+	p := r.config.FilePath()
+	ext := filepath.Ext(p)
+	return strings.TrimSuffix(p, ext) + "." + name + ext
+}
+
+// readSidecar reads the JSON sidecar file into v, cleartext or sealed with any key of the keyring. A missing
+// file leaves v as it is.
+func (r *RepoModule) readSidecar(name string, v any) error {
+	b, err := os.ReadFile(r.sidecarPath(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	return r.openJSON(b, v)
+}
+
+// writeSidecar replaces the JSON sidecar file with v, sealed with the primary key when a keyring is
+// configured.
+func (r *RepoModule) writeSidecar(name string, v any) error {
+	var (
+		b   []byte
+		err error
+	)
+	if r.config.Keyring() == nil {
+		b, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		b, err = r.sealJSON(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeFile(r.sidecarPath(name), b)
+}
+
+// writeFile replaces the content of the file through a rename of a file flushed to the disk, so a crash
+// never leaves a partially written file behind.
+func writeFile(path string, b []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// upstreamStatus keeps the last outcomes of the requests to the rate provider. Like the cache, it is
+// shared by the tenants.
+type upstreamStatus struct {
+	mtx          sync.Mutex
+	lastSuccess  time.Time
+	lastError    time.Time
+	lastErrorMsg string
+}
+
+func (u *upstreamStatus) record(err error) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	if err != nil {
+		u.lastError = time.Now()
+		u.lastErrorMsg = err.Error()
+		return
+	}
+
+	u.lastSuccess = time.Now()
+}
+
+// RateStatus reports the exchange rate cache and the last outcomes of the requests to the rate provider.
+// The cache is warm when it holds rates and has not expired.
+func (r *RepoModule) RateStatus() record.RateStatus {
+	r.fiscalCache.mtx.RLock()
+	s := record.RateStatus{CacheCreatedAt: r.fiscalCache.createdAt}
+	for _, rates := range r.fiscalCache.table {
+		s.CachedRates += len(rates)
+	}
+	r.fiscalCache.mtx.RUnlock()
+
+	age := time.Since(s.CacheCreatedAt)
+	s.CacheAgeSeconds = age.Seconds()
+	s.CacheWarm = s.CachedRates > 0 && age < r.cacheTTL()
+
+	r.upstream.mtx.Lock()
+	defer r.upstream.mtx.Unlock()
+
+	if !r.upstream.lastSuccess.IsZero() {
+		t := r.upstream.lastSuccess
+		s.LastSuccess = &t
+	}
+	if !r.upstream.lastError.IsZero() {
+		t := r.upstream.lastError
+		s.LastError = &t
+		s.LastErrorMessage = r.upstream.lastErrorMsg
+	}
+
+	return s
+}
+
+// ProbeRateProvider requests a single exchange rate from the rate provider to find out whether it is
+// reachable. The outcome is recorded like the one of any other request.
+func (r *RepoModule) ProbeRateProvider(ctx context.Context) error {
+	u, err := url.Parse(r.rateConfig.ExchangeRateURL())
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	query.Set("page[size]", "1")
+	u.RawQuery = query.Encode()
+
+	_, err = r.getRates(ctx, u.String(), logging.FromContext(ctx).With("upstream", "exchange_rates", "probe", true))
+	return err
+}
+
+// CheckWritable checks that the transaction file can be appended to, or created when it does not exist
+// yet. It writes nothing to the file.
+func (r *RepoModule) CheckWritable() error {
+	if r.config.SkipFile() {
+		return nil
+	}
+
+	f, err := os.OpenFile(r.config.FilePath(), os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		if f, err = os.CreateTemp(filepath.Dir(r.config.FilePath()), ".writable-*"); err == nil {
+			defer func() {
+				_ = os.Remove(f.Name())
+			}()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// DataFileStatus returns the path and the size of the transaction file. Without a file, the path is empty.
+func (r *RepoModule) DataFileStatus() (record.DataFileStatus, error) {
+	if r.config.SkipFile() {
+		return record.DataFileStatus{}, nil
+	}
+
+	s := record.DataFileStatus{Path: r.config.FilePath()}
+	info, err := os.Stat(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+
+	s.SizeBytes = info.Size()
+	return s, nil
+}
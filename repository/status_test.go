@@ -0,0 +1,95 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	"github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRepoModule_CheckWritable(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	app := &transactiondemo.App{AppFilePath: filepath.Join(dir, "data.json")}
+	repo := repository.New(app)
+
+	assert.NoError(t, repo.CheckWritable())
+	entries, err := os.ReadDir(dir)
+	if assert.NoError(t, err) {
+		assert.Empty(t, entries)
+	}
+
+	s, err := repo.DataFileStatus()
+	if assert.NoError(t, err) {
+		assert.Equal(t, record.DataFileStatus{Path: app.AppFilePath}, s)
+	}
+
+	assert.NoError(t, os.WriteFile(app.AppFilePath, []byte("{}\n"), 0o600))
+	assert.NoError(t, repo.CheckWritable())
+	s, err = repo.DataFileStatus()
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), s.SizeBytes)
+	}
+
+	app.AppFilePath = filepath.Join(dir, "missing", "data.json")
+	assert.Error(t, repo.CheckWritable())
+}
+
+func TestRepoModule_RateStatus(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	var query atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query.Store(r.URL.Query())
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+
+	repo := repository.New(&transactiondemo.App{AppExchangeRateURL: srv.URL})
+
+	s := repo.RateStatus()
+	assert.False(t, s.CacheWarm)
+	assert.False(t, s.Reachable())
+	assert.Nil(t, s.LastError)
+
+	assert.Error(t, repo.ProbeRateProvider(context.Background()))
+	s = repo.RateStatus()
+	assert.False(t, s.Reachable())
+	if assert.NotNil(t, s.LastError) {
+		assert.Contains(t, s.LastErrorMessage, "502")
+	}
+
+	fail.Store(false)
+	assert.NoError(t, repo.ProbeRateProvider(context.Background()))
+	assert.True(t, repo.RateStatus().Reachable())
+
+	// the probe keeps the query of the configured URL
+	repo = repository.New(&transactiondemo.App{AppExchangeRateURL: srv.URL + "?format=json"})
+	assert.NoError(t, repo.ProbeRateProvider(context.Background()))
+	assert.Equal(t, url.Values{"format": {"json"}, "page[size]": {"1"}}, query.Load())
+
+	repo.CacheSetExchangeRate("Canada-Dollar", record.FiscalDate(time.Now()), 1.35)
+	s = repo.RateStatus()
+	assert.True(t, s.CacheWarm)
+	assert.Equal(t, 1, s.CachedRates)
+	assert.Less(t, s.CacheAgeSeconds, 60.0)
+}
@@ -0,0 +1,166 @@
+package schedule
+
+import (
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronRule is a parsed cron-like expression with the day fields only: "day-of-month month day-of-week".
+// Transactions carry a date without a time, so the minute and hour fields of a regular cron expression
+// have no meaning here.
+type cronRule struct {
+	dom, month, dow []bool
+	domAny, dowAny  bool
+}
+
+func parseCron(expr string) (*cronRule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("%w: cron expression needs 3 fields: day-of-month month day-of-week", types.InvalidInputError)
+	}
+
+	var (
+		c   cronRule
+		err error
+	)
+
+	if c.dom, err = parseCronField(fields[0], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField(fields[1], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseCronField(fields[2], 0, 7); err != nil {
+		return nil, err
+	}
+	// both 0 and 7 stand for Sunday
+	c.dow[0] = c.dow[0] || c.dow[7]
+	// like in cron, a field starting with * counts as unrestricted for matches, a step included
+	c.domAny = strings.HasPrefix(fields[0], "*")
+	c.dowAny = strings.HasPrefix(fields[2], "*")
+
+	return &c, nil
+}
+
+// parseCronField accepts "*", single values, ranges "a-b", steps "*/n" or "a-b/n" and comma separated lists
+// of those.
+func parseCronField(field string, min, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		var (
+			lo, hi, step = min, max, 1
+			err          error
+		)
+
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("%w: invalid cron step %q", types.InvalidInputError, part)
+			}
+		}
+
+		if rangePart != "*" {
+			loPart, hiPart, isRange := strings.Cut(rangePart, "-")
+			if lo, err = strconv.Atoi(loPart); err != nil {
+				return nil, fmt.Errorf("%w: invalid cron value %q", types.InvalidInputError, part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiPart); err != nil {
+					return nil, fmt.Errorf("%w: invalid cron value %q", types.InvalidInputError, part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%w: cron value %q out of range %d-%d", types.InvalidInputError, part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+// matches follows the cron convention: when both day-of-month and day-of-week are restricted, a day
+// matching either of them is an occurrence.
+func (c *cronRule) matches(day time.Time) bool {
+	if !c.month[int(day.Month())] {
+		return false
+	}
+
+	domMatch := c.dom[day.Day()]
+	dowMatch := c.dow[int(day.Weekday())]
+	if !c.domAny && !c.dowAny {
+		return domMatch || dowMatch
+	}
+
+	return domMatch && dowMatch
+}
+
+func validateRule(verr *types.ValidationError, s record.Schedule) {
+	switch s.Frequency {
+	case record.Daily, record.Weekly, record.Monthly:
+		if s.Interval < 0 {
+			verr.Add("interval", "is negative")
+		}
+	case record.Cron:
+		if _, err := parseCron(s.Cron); err != nil {
+			verr.Add("cron", strings.TrimPrefix(err.Error(), types.InvalidInputError.Error()+": "))
+		}
+		if s.Interval != 0 {
+			verr.Add("interval", "is not supported with a cron expression")
+		}
+	default:
+		verr.Add("frequency", "is not one of daily, weekly, monthly or cron")
+	}
+
+	if s.End != nil && s.End.Date().Before(s.Start.Date()) {
+		verr.Add("end", "is before start")
+	}
+}
+
+// occursOn reports whether the schedule has an occurrence on the given day. The day must not be before the
+// start of the schedule.
+func occursOn(s record.Schedule, cron *cronRule, day time.Time) bool {
+	start := s.Start.Date()
+	interval := s.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	switch s.Frequency {
+	case record.Daily:
+		return daysBetween(start, day)%interval == 0
+	case record.Weekly:
+		return daysBetween(start, day)%(7*interval) == 0
+	case record.Monthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()) - int(start.Month())
+		if months%interval != 0 {
+			return false
+		}
+		// an occurrence on the 31st falls on the last day of shorter months
+		lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		return day.Day() == min(start.Day(), lastDay)
+	case record.Cron:
+		return cron.matches(day)
+	}
+
+	return false
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,281 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cespare/xxhash"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Config interface {
+	Repo() types.RepoI
+	Transaction() types.TxI
+}
+
+type Module struct {
+	config Config
+	table  map[string]record.Schedule
+	mtx    *sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New(config Config) *Module {
+	return &Module{
+		config: config,
+		table:  make(map[string]record.Schedule),
+		mtx:    &sync.Mutex{},
+	}
+}
+
+func (m *Module) Load(ctx context.Context) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	schedules, err := m.config.Repo().ReadSchedules(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range schedules {
+		m.table[s.ID] = s
+	}
+
+	return nil
+}
+
+// Add validates and stores a new schedule. Adding a schedule identical to an existing one returns the
+// existing schedule.
+func (m *Module) Add(ctx context.Context, s record.Schedule) (record.Schedule, error) {
+	var verr types.ValidationError
+
+	amount := strconv.FormatFloat(s.Amount, 'f', -1, 64)
+	_, err := transaction.NewRecord(s.Description, s.Start.Date().Format(record.FiscalDateFormat), amount, s.Lines...)
+	if txErr, ok := err.(*types.ValidationError); ok {
+		for _, f := range txErr.Fields {
+			// the template is validated as the transaction of the first occurrence
+			if f.Field == "date" {
+				f.Field = "start"
+			}
+			verr.Fields = append(verr.Fields, f)
+		}
+	}
+
+	validateRule(&verr, s)
+	if err = verr.Err(); err != nil {
+		return s, err
+	}
+
+	s.LastRun = nil
+	// the end and the line items are part of the identifier, a schedule without them keeps the identifier it
+	// always had
+	id := fmt.Sprintf("%s%f%s%d%s%s", s.Description, s.Amount, s.Frequency, s.Interval, s.Cron,
+		s.Start.Date().Format(time.RFC3339))
+	if s.End != nil {
+		id += "end" + s.End.Date().Format(time.RFC3339)
+	}
+	if len(s.Lines) > 0 {
+		var b []byte
+		if b, err = json.Marshal(s.Lines); err != nil {
+			return s, err
+		}
+		id += string(b)
+	}
+	s.ID = fmt.Sprintf("%x", xxhash.Sum64String(id))
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if existing, ok := m.table[s.ID]; ok {
+		return existing, nil
+	}
+
+	m.table[s.ID] = s
+	if err := m.persist(ctx); err != nil {
+		delete(m.table, s.ID)
+		return s, types.AsServerError(err)
+	}
+
+	return s, nil
+}
+
+func (m *Module) List() []record.Schedule {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	schedules := make([]record.Schedule, 0, len(m.table))
+	for _, s := range m.table {
+		schedules = append(schedules, s)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+
+	return schedules
+}
+
+func (m *Module) Get(id string) (record.Schedule, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	s, ok := m.table[id]
+	if !ok {
+		return s, types.RecordNotFound
+	}
+
+	return s, nil
+}
+
+func (m *Module) Delete(ctx context.Context, id string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	s, ok := m.table[id]
+	if !ok {
+		return types.RecordNotFound
+	}
+
+	delete(m.table, id)
+	if err := m.persist(ctx); err != nil {
+		m.table[id] = s
+		return types.AsServerError(err)
+	}
+
+	return nil
+}
+
+// Run materialises every occurrence due up to now that has not been materialised yet. Occurrences missed
+// while the application was down are caught up. The transaction ID is derived from the description, date
+// and amount, so an occurrence added twice, e.g. after a crash before LastRun was persisted, is stored once.
+// When ctx is done, the run stops before the next occurrence and the progress made so far is persisted.
+func (m *Module) Run(ctx context.Context, now time.Time) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var (
+		changed  bool
+		firstErr error
+		today    = truncateDay(now)
+	)
+
+	for id, s := range m.table {
+		before := s.LastRun
+		err := m.materialise(ctx, &s, today)
+		if s.LastRun != before {
+			m.table[id] = s
+			changed = true
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("schedule %s: %w", id, err)
+		}
+	}
+
+	if changed {
+		if err := m.persist(context.WithoutCancel(ctx)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// materialise adds the occurrences of the schedule up to today and moves LastRun forward.
+func (m *Module) materialise(ctx context.Context, s *record.Schedule, today time.Time) error {
+	var (
+		cron *cronRule
+		err  error
+	)
+
+	if s.Frequency == record.Cron {
+		if cron, err = parseCron(s.Cron); err != nil {
+			return err
+		}
+	}
+
+	day := s.Start.Date()
+	if s.LastRun != nil {
+		day = s.LastRun.Date().AddDate(0, 0, 1)
+	}
+
+	last := today
+	if s.End != nil && s.End.Date().Before(last) {
+		last = s.End.Date()
+	}
+
+	ctx = types.WithPrincipal(ctx, types.Principal{
+		Name:   "schedule " + s.ID,
+		Source: types.SourceScheduler,
+	})
+	amount := strconv.FormatFloat(s.Amount, 'f', -1, 64)
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if occursOn(*s, cron, day) {
+			if err = m.config.Transaction().Add(ctx, s.Description, day.Format(record.FiscalDateFormat), amount, s.Lines...); err != nil {
+				return err
+			}
+		}
+
+		lastRun := record.FiscalDate(day)
+		s.LastRun = &lastRun
+	}
+
+	return nil
+}
+
+// Start runs the schedules immediately and then at every interval until Stop is called. Stop cancels the
+// run in progress.
+func (m *Module) Start(interval time.Duration) {
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := m.Run(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				logging.FromContext(ctx).Error("running schedules", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (m *Module) Stop() {
+	if m.cancel == nil {
+		return
+	}
+
+	m.cancel()
+	<-m.done
+	m.cancel = nil
+}
+
+func (m *Module) persist(ctx context.Context) error {
+	schedules := make([]record.Schedule, 0, len(m.table))
+	for _, s := range m.table {
+		schedules = append(schedules, s)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+
+	return m.config.Repo().WriteSchedules(ctx, schedules)
+}
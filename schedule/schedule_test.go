@@ -0,0 +1,198 @@
+package schedule_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/schedule"
+	tx "github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newApp(fileName string) *transactiondemo.App {
+	app := &transactiondemo.App{
+		AppSkipFile: fileName == "",
+		AppFilePath: fileName,
+	}
+
+	app.AppRepo = repoModule.New(app)
+	app.AppTransaction = tx.New(app)
+
+	return app
+}
+
+func date(year int, month time.Month, day int) record.FiscalDate {
+	return record.FiscalDate(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+func TestModule_RunCatchUp(t *testing.T) {
+	app := newApp("")
+	sched := schedule.New(app)
+
+	_, err := sched.Add(context.Background(), record.Schedule{
+		Description: "rent",
+		Amount:      1200,
+		Frequency:   record.Monthly,
+		Start:       date(2023, time.January, 31),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, time.April, 15, 8, 0, 0, 0, time.UTC)
+	assert.NoError(t, sched.Run(context.Background(), now))
+	assert.NoError(t, sched.Run(context.Background(), now))
+
+	list, err := app.Transaction().List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+
+	days := make(map[string]bool)
+	for _, rec := range list {
+		days[rec.Date.Date().Format(record.FiscalDateFormat)] = true
+	}
+	assert.Equal(t, map[string]bool{"2023-01-31": true, "2023-02-28": true, "2023-03-31": true}, days)
+}
+
+func TestModule_Cron(t *testing.T) {
+	app := newApp("")
+	sched := schedule.New(app)
+
+	end := date(2023, time.October, 31)
+	_, err := sched.Add(context.Background(), record.Schedule{
+		Description: "payroll",
+		Amount:      10,
+		Frequency:   record.Cron,
+		Cron:        "1,15 * *",
+		Start:       date(2023, time.October, 1),
+		End:         &end,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, sched.Run(context.Background(), time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	n, err := app.Transaction().Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, err = sched.Add(context.Background(), record.Schedule{
+		Description: "invalid",
+		Amount:      10,
+		Frequency:   record.Cron,
+		Cron:        "32 * *",
+		Start:       date(2023, time.October, 1),
+	})
+	assert.ErrorIs(t, err, types.InvalidInputError)
+
+	// an interval is a frequency setting, a cron expression has its own steps
+	_, err = sched.Add(context.Background(), record.Schedule{
+		Description: "invalid",
+		Amount:      10,
+		Frequency:   record.Cron,
+		Cron:        "1 * *",
+		Interval:    2,
+		Start:       date(2023, time.October, 1),
+	})
+	assert.ErrorIs(t, err, types.InvalidInputError)
+}
+
+func TestModule_CronStep(t *testing.T) {
+	app := newApp("")
+	sched := schedule.New(app)
+
+	// a day-of-month step is unrestricted like *, the odd Mondays of October 2023 are the 9th and the 23rd
+	end := date(2023, time.October, 31)
+	_, err := sched.Add(context.Background(), record.Schedule{
+		Description: "odd mondays",
+		Amount:      10,
+		Frequency:   record.Cron,
+		Cron:        "*/2 * 1",
+		Start:       date(2023, time.October, 1),
+		End:         &end,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, sched.Run(context.Background(), time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	n, err := app.Transaction().Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestModule_AddID(t *testing.T) {
+	sched := schedule.New(newApp(""))
+	add := func(s record.Schedule) record.Schedule {
+		added, err := sched.Add(context.Background(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return added
+	}
+
+	base := record.Schedule{Description: "rent", Amount: 1200, Frequency: record.Monthly,
+		Start: date(2023, time.January, 31)}
+	plain := add(base)
+
+	end := date(2023, time.June, 30)
+	ending := base
+	ending.End = &end
+	split := base
+	split.Lines = []record.LineItem{{Category: "home", Amount: 1000}, {Category: "parking", Amount: 200}}
+
+	// a schedule differing only by its end or its line items is another schedule
+	assert.NotEqual(t, plain.ID, add(ending).ID)
+	assert.NotEqual(t, plain.ID, add(split).ID)
+	assert.Len(t, sched.List(), 3)
+	assert.Equal(t, plain, add(base))
+}
+
+func TestModule_Persistence(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	fileName := filepath.Join(dir, "data.json")
+
+	sched := schedule.New(newApp(fileName))
+	s, err := sched.Add(context.Background(), record.Schedule{
+		Description: "gym",
+		Amount:      30,
+		Frequency:   record.Weekly,
+		Interval:    2,
+		Start:       date(2023, time.October, 2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, sched.Run(context.Background(), time.Date(2023, time.October, 31, 0, 0, 0, 0, time.UTC)))
+
+	app := newApp(fileName)
+	if err = app.Transaction().Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sched = schedule.New(app)
+	if err = sched.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := sched.Get(s.ID)
+	if assert.NoError(t, err) && assert.NotNil(t, reloaded.LastRun) {
+		assert.Equal(t, date(2023, time.October, 31), *reloaded.LastRun)
+	}
+
+	assert.NoError(t, sched.Run(context.Background(), time.Date(2023, time.November, 13, 0, 0, 0, 0, time.UTC)))
+	n, err := app.Transaction().Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+}
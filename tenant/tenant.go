@@ -0,0 +1,250 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DataFileName is the name of the transaction file in the directory of a tenant.
+const DataFileName = "data.json"
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Quota limits what a tenant may store. Zero means no limit.
+type Quota struct {
+	MaxTransactions int
+}
+
+// Builder assembles the modules of a tenant working on the transaction file at filePath, with its quota.
+type Builder func(name, filePath string, quota Quota) (types.TenantI, error)
+
+// Registry holds the tenants other than the default one. A tenant is built on first use, its files are in
+// its own directory under the registry directory.
+type Registry struct {
+	dir          string
+	build        Builder
+	defaultQuota Quota
+	quotas       map[string]Quota
+	max          int
+
+	mtx      *sync.Mutex
+	tenants  map[string]types.TenantI
+	building map[string]*tenantBuild
+}
+
+// tenantBuild is the build of a tenant shared by the callers asking for it while it runs. tenant and err are
+// set before done is closed.
+type tenantBuild struct {
+	done   chan struct{}
+	tenant types.TenantI
+	err    error
+}
+
+// New returns a registry keeping the tenants under dir. quotas overrides defaultQuota per tenant.
+func New(dir string, build Builder, defaultQuota Quota, quotas map[string]Quota) *Registry {
+	return &Registry{
+		dir:          dir,
+		build:        build,
+		defaultQuota: defaultQuota,
+		quotas:       quotas,
+		mtx:          &sync.Mutex{},
+		tenants:      make(map[string]types.TenantI),
+		building:     make(map[string]*tenantBuild),
+	}
+}
+
+// ValidName reports whether name may name a tenant: lower case letters, digits, - and _, up to 63
+// characters.
+func ValidName(name string) bool {
+	return validName.MatchString(name) && name != types.DefaultTenant
+}
+
+// SetMax limits the number of tenants, those having their own quota excepted. A tenant beyond the limit is
+// a types.QuotaExceededError, it is neither created nor loaded. Zero means no limit.
+func (r *Registry) SetMax(max int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.max = max
+}
+
+// Get returns the tenant, building it on first use.
+func (r *Registry) Get(name string) (types.TenantI, error) {
+	return r.get(name, true)
+}
+
+// get returns the tenant, building it on first use, within the maximum number of tenants when capped. The
+// tenant is built outside the lock, the callers asking for it meanwhile wait for the same build.
+func (r *Registry) get(name string, capped bool) (types.TenantI, error) {
+	if !ValidName(name) {
+		return nil, fmt.Errorf("%w: invalid tenant name %q", types.InvalidInputError, name)
+	}
+
+	r.mtx.Lock()
+	if t, ok := r.tenants[name]; ok {
+		r.mtx.Unlock()
+		return t, nil
+	}
+
+	if b := r.building[name]; b != nil {
+		r.mtx.Unlock()
+		<-b.done
+		return b.tenant, b.err
+	}
+
+	if _, configured := r.quotas[name]; capped && !configured && r.max > 0 && r.unconfigured() >= r.max {
+		r.mtx.Unlock()
+		return nil, fmt.Errorf("%w: tenant %q would exceed the maximum of %d tenants", types.QuotaExceededError, name, r.max)
+	}
+
+	quota, ok := r.quotas[name]
+	if !ok {
+		quota = r.defaultQuota
+	}
+
+	b := &tenantBuild{done: make(chan struct{})}
+	r.building[name] = b
+	r.mtx.Unlock()
+
+	b.tenant, b.err = r.buildTenant(name, quota)
+
+	r.mtx.Lock()
+	delete(r.building, name)
+	if b.err == nil {
+		r.tenants[name] = b.tenant
+	}
+	r.mtx.Unlock()
+
+	close(b.done)
+	return b.tenant, b.err
+}
+
+func (r *Registry) buildTenant(name string, quota Quota) (types.TenantI, error) {
+	dir := filepath.Join(r.dir, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("%w: creating the tenant directory: %w", types.ServerError, err)
+	}
+
+	t, err := r.build(name, filepath.Join(dir, DataFileName), quota)
+	if err != nil {
+		return nil, fmt.Errorf("%w: loading tenant %s: %w", types.ServerError, name, err)
+	}
+
+	return t, nil
+}
+
+// unconfigured returns the number of tenants built so far, or being built, without their own quota.
+func (r *Registry) unconfigured() int {
+	n := 0
+	for name := range r.tenants {
+		if _, ok := r.quotas[name]; !ok {
+			n++
+		}
+	}
+	for name := range r.building {
+		if _, ok := r.quotas[name]; !ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// LoadAll builds every tenant having a directory, so that their schedules run without waiting for a
+// request. They all count toward the maximum number of tenants, even beyond it.
+func (r *Registry) LoadAll() error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() && ValidName(e.Name()) {
+			if _, err = r.get(e.Name(), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Names returns the names of the tenants built so far.
+func (r *Registry) Names() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	names := make([]string, 0, len(r.tenants))
+	for name := range r.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Close stops the tenants implementing interface{ Close(context.Context) error }, the wait for them ends
+// when ctx is done.
+func (r *Registry) Close(ctx context.Context) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var first error
+	for _, t := range r.tenants {
+		if c, ok := t.(interface{ Close(context.Context) error }); ok {
+			if err := c.Close(ctx); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+
+	return first
+}
+
+// ParseQuotas reads quotas in the form "name=max", separated by commas, where max is the maximum number of
+// transactions. The name * sets the quota of the tenants without their own.
+func ParseQuotas(spec string) (defaultQuota Quota, quotas map[string]Quota, err error) {
+	quotas = make(map[string]Quota)
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			err = fmt.Errorf("invalid quota %q, expected name=max", entry)
+			return
+		}
+
+		var max int
+		if max, err = strconv.Atoi(value); err != nil || max < 0 {
+			err = fmt.Errorf("invalid quota %q, expected a number of transactions", entry)
+			return
+		}
+
+		switch {
+		case name == "*":
+			defaultQuota.MaxTransactions = max
+		case name == types.DefaultTenant || ValidName(name):
+			quotas[name] = Quota{MaxTransactions: max}
+		default:
+			err = fmt.Errorf("invalid tenant name %q", name)
+			return
+		}
+	}
+
+	return
+}
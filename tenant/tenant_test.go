@@ -0,0 +1,173 @@
+package tenant_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo"
+	repoModule "github.com/suyono3484/transactiondemo/repository"
+	"github.com/suyono3484/transactiondemo/tenant"
+	"github.com/suyono3484/transactiondemo/transaction"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func build(_, filePath string, quota tenant.Quota) (types.TenantI, error) {
+	app := &transactiondemo.App{AppFilePath: filePath}
+	app.AppRepo = repoModule.New(app)
+
+	tx := transaction.New(app)
+	if err := tx.Load(context.Background()); err != nil {
+		return nil, err
+	}
+	tx.SetQuota(quota.MaxTransactions)
+	app.AppTransaction = tx
+
+	return app, nil
+}
+
+func count(t *testing.T, tenant types.TenantI) int {
+	n, err := tenant.Transaction().Count(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestRegistry_Get(t *testing.T) {
+	dir := t.TempDir()
+	r := tenant.New(dir, build, tenant.Quota{}, map[string]tenant.Quota{"team-b": {MaxTransactions: 1}})
+
+	for _, name := range []string{"", "default", "../etc", "Team", "a/b"} {
+		_, err := r.Get(name)
+		assert.ErrorIs(t, err, types.InvalidInputError, name)
+	}
+
+	a, err := r.Get("team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Get("team-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := r.Get("team-a")
+	assert.Same(t, a, again)
+
+	date := time.Now().Format(record.FiscalDateFormat)
+	assert.NoError(t, a.Transaction().Add(context.Background(), "rent", date, "100"))
+	assert.NoError(t, a.Transaction().Add(context.Background(), "food", date, "20"))
+	assert.NoError(t, b.Transaction().Add(context.Background(), "rent", date, "100"))
+	assert.ErrorIs(t, b.Transaction().Add(context.Background(), "food", date, "20"), types.QuotaExceededError)
+
+	assert.Equal(t, 2, count(t, a))
+	assert.Equal(t, 1, count(t, b))
+	assert.FileExists(t, filepath.Join(dir, "team-a", tenant.DataFileName))
+
+	// a new registry finds the tenants on disk with their transactions
+	r = tenant.New(dir, build, tenant.Quota{}, nil)
+	if err = r.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"team-a", "team-b"}, r.Names())
+
+	a, _ = r.Get("team-a")
+	assert.Equal(t, 2, count(t, a))
+}
+
+func TestRegistry_SetMax(t *testing.T) {
+	dir := t.TempDir()
+	r := tenant.New(dir, build, tenant.Quota{}, map[string]tenant.Quota{"team-b": {MaxTransactions: 10}})
+	r.SetMax(1)
+
+	_, err := r.Get("team-a")
+	assert.NoError(t, err)
+	_, err = r.Get("team-c")
+	assert.ErrorIs(t, err, types.QuotaExceededError)
+	assert.NoDirExists(t, filepath.Join(dir, "team-c"))
+
+	// a tenant with its own quota is configured, it does not count
+	_, err = r.Get("team-b")
+	assert.NoError(t, err)
+	_, err = r.Get("team-a")
+	assert.NoError(t, err)
+
+	// the tenants on disk are loaded even beyond the maximum
+	if err = os.Mkdir(filepath.Join(dir, "team-d"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	r = tenant.New(dir, build, tenant.Quota{}, nil)
+	r.SetMax(1)
+	if assert.NoError(t, r.LoadAll()) {
+		assert.Equal(t, []string{"team-a", "team-b", "team-d"}, r.Names())
+	}
+}
+
+func TestRegistry_GetConcurrent(t *testing.T) {
+	var (
+		builds  atomic.Int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	slow := func(name, filePath string, quota tenant.Quota) (types.TenantI, error) {
+		if name == "team-a" {
+			builds.Add(1)
+			close(started)
+			<-release
+		}
+		return build(name, filePath, quota)
+	}
+	r := tenant.New(t.TempDir(), slow, tenant.Quota{}, nil)
+
+	results := make(chan types.TenantI, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			a, err := r.Get("team-a")
+			assert.NoError(t, err)
+			results <- a
+		}()
+	}
+	<-started
+
+	// another tenant is built while the first one is loading
+	_, err := r.Get("team-b")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team-b"}, r.Names())
+
+	close(release)
+	a, again := <-results, <-results
+	assert.Same(t, a, again)
+	assert.Equal(t, int32(1), builds.Load())
+}
+
+func TestRegistry_LoadAllMissingDir(t *testing.T) {
+	r := tenant.New(filepath.Join(t.TempDir(), "missing"), build, tenant.Quota{}, nil)
+	assert.NoError(t, r.LoadAll())
+	assert.Empty(t, r.Names())
+}
+
+func TestParseQuotas(t *testing.T) {
+	def, quotas, err := tenant.ParseQuotas(" *=100, default=50,team-a=10 ")
+	if assert.NoError(t, err) {
+		assert.Equal(t, tenant.Quota{MaxTransactions: 100}, def)
+		assert.Equal(t, map[string]tenant.Quota{
+			"default": {MaxTransactions: 50},
+			"team-a":  {MaxTransactions: 10},
+		}, quotas)
+	}
+
+	def, quotas, err = tenant.ParseQuotas("")
+	assert.NoError(t, err)
+	assert.Zero(t, def)
+	assert.Empty(t, quotas)
+
+	for _, spec := range []string{"team-a", "team-a=x", "team-a=-1", "Team=1"} {
+		_, _, err = tenant.ParseQuotas(spec)
+		assert.Error(t, err, spec)
+	}
+}
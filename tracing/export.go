@@ -0,0 +1,201 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileExporter appends the spans to a file, one JSON object per line.
+type FileExporter struct {
+	mtx  *sync.Mutex
+	file *os.File
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileExporter{mtx: &sync.Mutex{}, file: f}, nil
+}
+
+func (e *FileExporter) Export(_ context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range spans {
+		if err := enc.Encode(&spans[i]); err != nil {
+			return err
+		}
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	_, err := e.file.Write(buf.Bytes())
+	return err
+}
+
+func (e *FileExporter) Close() error {
+	return e.file.Close()
+}
+
+// ReadSpanFile reads the spans written by a FileExporter.
+func ReadSpanFile(path string) ([]SpanData, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		spans []SpanData
+		dec   = json.NewDecoder(bytes.NewReader(b))
+	)
+	for {
+		var s SpanData
+		if err = dec.Decode(&s); err == io.EOF {
+			return spans, nil
+		} else if err != nil {
+			return nil, err
+		}
+		spans = append(spans, s)
+	}
+}
+
+// OTLPExporter posts the spans to an OpenTelemetry collector with OTLP over HTTP, in the JSON encoding.
+// Endpoint is the base URL of the collector, e.g. http://localhost:4318; the spans go to /v1/traces.
+type OTLPExporter struct {
+	Endpoint    string
+	ServiceName string
+	Headers     map[string]string
+	client      *http.Client
+}
+
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	return &OTLPExporter{
+		Endpoint:    strings.TrimSuffix(endpoint, "/"),
+		ServiceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	b, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+"/v1/traces", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("exporting spans: unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+type otlpValue map[string]any
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+func (e *OTLPExporter) request(spans []SpanData) map[string]any {
+	out := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]any{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              int(s.Kind),
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attributes),
+		}
+		if s.ParentID != "" {
+			span["parentSpanId"] = s.ParentID
+		}
+		if s.Error != "" {
+			span["status"] = map[string]any{"code": 2, "message": s.Error}
+		}
+		out = append(out, span)
+	}
+
+	return map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": otlpAttributes(map[string]any{"service.name": e.ServiceName}),
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]any{"name": "github.com/suyono3484/transactiondemo/tracing"},
+						"spans": out,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]otlpAttribute, 0, len(attrs))
+	for _, k := range keys {
+		var v otlpValue
+		switch a := attrs[k].(type) {
+		case string:
+			v = otlpValue{"stringValue": a}
+		case bool:
+			v = otlpValue{"boolValue": a}
+		case int:
+			v = otlpValue{"intValue": strconv.Itoa(a)}
+		case int64:
+			v = otlpValue{"intValue": strconv.FormatInt(a, 10)}
+		case float64:
+			if math.IsNaN(a) || math.IsInf(a, 0) {
+				v = otlpValue{"stringValue": strconv.FormatFloat(a, 'g', -1, 64)}
+			} else {
+				v = otlpValue{"doubleValue": a}
+			}
+		default:
+			v = otlpValue{"stringValue": fmt.Sprint(a)}
+		}
+		out = append(out, otlpAttribute{Key: k, Value: v})
+	}
+
+	return out
+}
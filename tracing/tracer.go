@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// maxQueue bounds the spans waiting for export; the spans ended while the queue is full are dropped.
+	maxQueue      = 2048
+	maxBatch      = 512
+	flushInterval = 5 * time.Second
+)
+
+// Exporter sends finished spans to their destination.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Tracer starts the root spans of the requests and exports the sampled spans in batches, in the
+// background. A nil tracer starts no span.
+type Tracer struct {
+	exporter Exporter
+
+	mtx     *sync.Mutex
+	queue   []SpanData
+	dropped int
+	flushed chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewTracer returns a tracer exporting to exporter; Shutdown stops it.
+func NewTracer(exporter Exporter) *Tracer {
+	t := &Tracer{
+		exporter: exporter,
+		mtx:      &sync.Mutex{},
+		flushed:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+
+	return t
+}
+
+// StartRemote starts a span continuing the trace of remote, the span context read from an incoming request,
+// or a new trace when remote is not valid.
+func (t *Tracer) StartRemote(ctx context.Context, name string, kind Kind, remote SpanContext) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	if !remote.IsValid() {
+		remote = SpanContext{Sampled: true}
+		newID(remote.TraceID[:])
+	}
+
+	return t.start(ctx, name, kind, remote, remote.SpanID)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind Kind, parent SpanContext, parentID SpanID) (context.Context, *Span) {
+	s := &Span{
+		tracer: t,
+		sc:     SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled},
+		parent: parentID,
+		mtx:    &sync.Mutex{},
+	}
+	newID(s.sc.SpanID[:])
+
+	s.data = SpanData{
+		Name:    name,
+		Kind:    kind,
+		TraceID: s.sc.TraceID.String(),
+		SpanID:  s.sc.SpanID.String(),
+		Start:   time.Now(),
+	}
+	if parentID.IsValid() {
+		s.data.ParentID = parentID.String()
+	}
+
+	return ContextWithSpan(ctx, s), s
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if len(t.queue) >= maxQueue {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, data)
+
+	if len(t.queue) >= maxBatch {
+		select {
+		case t.flushed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		case <-t.flushed:
+		}
+		_ = t.Flush(context.Background())
+	}
+}
+
+// Flush exports the queued spans.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mtx.Lock()
+	spans := t.queue
+	t.queue = nil
+	t.mtx.Unlock()
+
+	for len(spans) > 0 {
+		n := min(len(spans), maxBatch)
+		if err := t.exporter.Export(ctx, spans[:n]); err != nil {
+			return err
+		}
+		spans = spans[n:]
+	}
+
+	return nil
+}
+
+// Dropped returns the number of spans dropped because the queue was full.
+func (t *Tracer) Dropped() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.dropped
+}
+
+// Shutdown stops the background export and exports the queued spans.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	close(t.stop)
+	<-t.done
+
+	return t.Flush(ctx)
+}
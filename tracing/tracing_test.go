@@ -0,0 +1,133 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/tracing"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+		assert.True(t, sc.Sampled)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+	}
+
+	// a later version may add fields
+	sc, err = tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	if assert.NoError(t, err) {
+		assert.False(t, sc.Sampled)
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	} {
+		_, err = tracing.ParseTraceparent(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := tracing.NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = exporter.Close()
+	}()
+	tracer := tracing.NewTracer(exporter)
+
+	// without a span in the context nothing is traced
+	ctx, span := tracing.Start(context.Background(), "orphan", tracing.KindInternal)
+	assert.Nil(t, span)
+	assert.Nil(t, tracing.SpanFromContext(ctx))
+	span.SetAttribute("ignored", true)
+	span.End()
+
+	remote, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tracer.StartRemote(context.Background(), "GET /things/:id", tracing.KindServer, remote)
+	_, child := tracing.Start(ctx, "lookup", tracing.KindInternal)
+	child.SetAttribute("id", "a")
+	child.RecordError(errors.New("not found"))
+	child.End()
+	child.End()
+	root.End()
+
+	// an unsampled trace is propagated but not exported
+	remote.Sampled = false
+	ctx, unsampled := tracer.StartRemote(context.Background(), "GET /other", tracing.KindServer, remote)
+	_, span = tracing.Start(ctx, "lookup", tracing.KindInternal)
+	assert.Equal(t, remote.TraceID, span.SpanContext().TraceID)
+	span.End()
+	unsampled.End()
+
+	assert.NoError(t, tracer.Shutdown(context.Background()))
+
+	spans, err := tracing.ReadSpanFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "lookup", spans[0].Name)
+		assert.Equal(t, root.SpanContext().SpanID.String(), spans[0].ParentID)
+		assert.Equal(t, "a", spans[0].Attributes["id"])
+		assert.Equal(t, "not found", spans[0].Error)
+
+		assert.Equal(t, "GET /things/:id", spans[1].Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", spans[1].ParentID)
+		assert.Equal(t, tracing.KindServer, spans[1].Kind)
+		assert.False(t, spans[1].End.Before(spans[1].Start))
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer ts.Close()
+
+	tracer := tracing.NewTracer(tracing.NewOTLPExporter(ts.URL+"/", "test"))
+	_, span := tracer.StartRemote(context.Background(), "GET /", tracing.KindServer, tracing.SpanContext{})
+	span.SetAttribute("http.status_code", 200)
+	span.End()
+	assert.NoError(t, tracer.Shutdown(context.Background()))
+
+	rs := body["resourceSpans"].([]any)[0].(map[string]any)
+	service := rs["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	assert.Equal(t, "service.name", service["key"])
+	assert.Equal(t, map[string]any{"stringValue": "test"}, service["value"])
+
+	s := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	assert.Equal(t, "GET /", s["name"])
+	assert.Equal(t, float64(tracing.KindServer), s["kind"])
+	assert.Equal(t, span.SpanContext().TraceID.String(), s["traceId"])
+	assert.NotContains(t, s, "parentSpanId")
+	assert.Equal(t, []any{map[string]any{"key": "http.status_code", "value": map[string]any{"intValue": "200"}}},
+		s["attributes"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	assert.Error(t, tracing.NewOTLPExporter(failing.URL, "test").Export(context.Background(), []tracing.SpanData{{}}))
+}
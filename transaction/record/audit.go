@@ -0,0 +1,18 @@
+package record
+
+import "time"
+
+const AuditCreate = "create"
+
+// AuditEvent records a change of a transaction. Before is nil for a created transaction.
+type AuditEvent struct {
+	ID            string             `json:"id"`
+	TransactionID string             `json:"transaction_id"`
+	Action        string             `json:"action"`
+	Actor         string             `json:"actor"`
+	Source        string             `json:"source"`
+	ClientIP      string             `json:"client_ip,omitempty"`
+	Time          time.Time          `json:"time"`
+	Before        *TransactionRecord `json:"before,omitempty"`
+	After         *TransactionRecord `json:"after,omitempty"`
+}
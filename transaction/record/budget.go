@@ -0,0 +1,33 @@
+package record
+
+import "time"
+
+const BudgetMonthFormat = "2006-01"
+
+// Budget is a monthly spending limit for the line items with the given category, tag, or both, expressed
+// in Currency.
+type Budget struct {
+	ID       string  `json:"id"`
+	Category string  `json:"category,omitempty"`
+	Tag      string  `json:"tag,omitempty"`
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+type BudgetStatus struct {
+	Budget
+	Month string  `json:"month"`
+	Spent float64 `json:"spent"`
+	Ratio float64 `json:"ratio"`
+}
+
+type BudgetAlert struct {
+	BudgetStatus
+	Threshold float64   `json:"threshold"`
+	Time      time.Time `json:"time"`
+}
+
+// Matches reports whether a line item counts toward the budget.
+func (b Budget) Matches(line LineItem) bool {
+	return line.matches(b.Category, b.Tag)
+}
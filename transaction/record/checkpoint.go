@@ -0,0 +1,29 @@
+package record
+
+import (
+	"fmt"
+	"time"
+)
+
+// Checkpoint is the head of the hash chain of the transaction file after Lines lines. Chained tells that the
+// file was chained at that point, so a file stripped of its header and links no longer passes as one written
+// before the chain.
+type Checkpoint struct {
+	Lines     int       `json:"lines"`
+	Head      string    `json:"head"`
+	Chained   bool      `json:"chained,omitempty"`
+	Time      time.Time `json:"time"`
+	PublicKey string    `json:"public_key,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+// SignedData returns the bytes covered by the signature. Chained is only part of them when set, the
+// checkpoints of unchained files keep their former signature.
+func (c Checkpoint) SignedData() []byte {
+	data := fmt.Sprintf("%d\n%s\n%s", c.Lines, c.Head, c.Time.UTC().Format(time.RFC3339Nano))
+	if c.Chained {
+		data += "\nchained"
+	}
+
+	return []byte(data)
+}
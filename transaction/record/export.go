@@ -0,0 +1,30 @@
+package record
+
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportExcel ExportFormat = "excel"
+	ExportJSON  ExportFormat = "json"
+)
+
+type ExportOptions struct {
+	Format     ExportFormat
+	Filter     Filter
+	Currencies []string
+}
+
+// Conversion is a transaction amount in another currency. Error is set instead of the amounts when the
+// transaction cannot be converted.
+type Conversion struct {
+	Currency      string      `json:"currency"`
+	Rate          float64     `json:"rate,omitempty"`
+	EffectiveDate *FiscalDate `json:"effective_date,omitempty"`
+	Converted     float64     `json:"converted,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
+
+type ExportRow struct {
+	TransactionRecord
+	Conversions []Conversion `json:"conversions,omitempty"`
+}
@@ -0,0 +1,35 @@
+package record
+
+import "time"
+
+// Filter selects transactions. Zero fields do not restrict: From and To are inclusive dates, Category and
+// Tag match any line item of a split transaction.
+type Filter struct {
+	From     time.Time
+	To       time.Time
+	Category string
+	Tag      string
+}
+
+func (f Filter) Matches(rec TransactionRecord) bool {
+	date := rec.Date.Date()
+	if !f.From.IsZero() && date.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && date.After(f.To) {
+		return false
+	}
+
+	if f.Category == "" && f.Tag == "" {
+		return true
+	}
+
+	for _, line := range rec.Lines {
+		if line.matches(f.Category, f.Tag) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package record
+
+type ImportFormat string
+
+const (
+	FormatCSV ImportFormat = "csv"
+	FormatOFX ImportFormat = "ofx"
+	FormatQIF ImportFormat = "qif"
+)
+
+// CSVMapping tells which columns of a CSV statement hold the transaction fields. A column is either a
+// header name or a 1-based index. DateFormat is a Go time layout; when empty, common layouts are tried.
+type CSVMapping struct {
+	Date         string `json:"date"`
+	Description  string `json:"description"`
+	Amount       string `json:"amount"`
+	DateFormat   string `json:"date_format,omitempty"`
+	Delimiter    rune   `json:"delimiter,omitempty"`
+	NoHeader     bool   `json:"no_header,omitempty"`
+	DecimalComma bool   `json:"decimal_comma,omitempty"`
+}
+
+type ImportOptions struct {
+	// Name is the file name of the statement, used for format detection when Format is empty.
+	Name    string       `json:"name,omitempty"`
+	Format  ImportFormat `json:"format,omitempty"`
+	Mapping CSVMapping   `json:"mapping"`
+	DryRun  bool         `json:"dry_run"`
+}
+
+// ImportRow is a statement entry. Row is the line number for CSV and QIF and the entry number for OFX.
+type ImportRow struct {
+	Row         int    `json:"row"`
+	ID          string `json:"id,omitempty"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
+	Amount      string `json:"amount"`
+	Error       string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Format     ImportFormat `json:"format"`
+	DryRun     bool         `json:"dry_run"`
+	Rows       int          `json:"rows"`
+	Imported   int          `json:"imported"`
+	Duplicates []ImportRow  `json:"duplicates"`
+	Invalid    []ImportRow  `json:"invalid"`
+}
@@ -14,11 +14,49 @@ type TransactionRecord struct {
 	Description string     `json:"description"`
 	Date        FiscalDate `json:"date"`
 	Amount      float64    `json:"amount"`
+	Lines       []LineItem `json:"lines,omitempty"`
 }
 
+// LineItem is a part of a split transaction. The amounts of all line items in a transaction add up to
+// the transaction amount.
+type LineItem struct {
+	Description string   `json:"description,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Amount      float64  `json:"amount"`
+}
+
+// matches reports whether the line item has the category and the tag. An empty category or tag matches
+// any line item.
+func (l LineItem) matches(category, tag string) bool {
+	if category != "" && category != l.Category {
+		return false
+	}
+
+	if tag != "" {
+		for _, t := range l.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// ConvertedTransaction is a transaction in a target currency. EffectiveDate is the effective date of the
+// exchange rate; it is nil for the default currency.
 type ConvertedTransaction struct {
 	TransactionRecord
-	Rate      float64 `json:"rate"`
+	Rate          float64             `json:"rate"`
+	EffectiveDate *FiscalDate         `json:"effective_date,omitempty"`
+	Converted     float64             `json:"converted"`
+	Lines         []ConvertedLineItem `json:"lines,omitempty"`
+}
+
+type ConvertedLineItem struct {
+	LineItem
 	Converted float64 `json:"converted"`
 }
 
@@ -0,0 +1,26 @@
+package record
+
+type Frequency string
+
+const (
+	Daily   Frequency = "daily"
+	Weekly  Frequency = "weekly"
+	Monthly Frequency = "monthly"
+	Cron    Frequency = "cron"
+)
+
+// Schedule describes a recurring transaction. The occurrences start at Start and repeat every Interval
+// days, weeks or months depending on Frequency. A Cron schedule uses the day fields of a cron expression
+// instead: "day-of-month month day-of-week". LastRun is the date of the last materialised occurrence.
+type Schedule struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Amount      float64     `json:"amount"`
+	Lines       []LineItem  `json:"lines,omitempty"`
+	Frequency   Frequency   `json:"frequency"`
+	Interval    int         `json:"interval,omitempty"`
+	Cron        string      `json:"cron,omitempty"`
+	Start       FiscalDate  `json:"start"`
+	End         *FiscalDate `json:"end,omitempty"`
+	LastRun     *FiscalDate `json:"last_run,omitempty"`
+}
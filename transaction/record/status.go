@@ -0,0 +1,25 @@
+package record
+
+import "time"
+
+// RateStatus reports the exchange rate cache and the last outcomes of the requests to the rate provider.
+type RateStatus struct {
+	CacheCreatedAt   time.Time  `json:"cache_created_at"`
+	CacheAgeSeconds  float64    `json:"cache_age_seconds"`
+	CachedRates      int        `json:"cached_rates"`
+	CacheWarm        bool       `json:"cache_warm"`
+	LastSuccess      *time.Time `json:"last_success,omitempty"`
+	LastError        *time.Time `json:"last_error,omitempty"`
+	LastErrorMessage string     `json:"last_error_message,omitempty"`
+}
+
+// Reachable reports whether the last request to the rate provider succeeded.
+func (s RateStatus) Reachable() bool {
+	return s.LastSuccess != nil && (s.LastError == nil || s.LastSuccess.After(*s.LastError))
+}
+
+// DataFileStatus describes the transaction file. The size is zero until the first transaction is stored.
+type DataFileStatus struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+}
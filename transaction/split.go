@@ -0,0 +1,87 @@
+package transaction
+
+import (
+	"fmt"
+	"github.com/suyono3484/transactiondemo/transaction/record"
+	"github.com/suyono3484/transactiondemo/types"
+	"math"
+	"sort"
+)
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func validateLines(verr *types.ValidationError, amount float64, lines []record.LineItem) {
+	if len(lines) == 0 {
+		return
+	}
+
+	var sum int64
+	for i, line := range lines {
+		if len(line.Description) > 50 {
+			verr.Add(fmt.Sprintf("lines[%d].description", i), "is longer than 50 character")
+		}
+		if len(line.Category) > 50 {
+			verr.Add(fmt.Sprintf("lines[%d].category", i), "is longer than 50 character")
+		}
+		if math.IsNaN(line.Amount) || math.IsInf(line.Amount, 0) {
+			verr.Add(fmt.Sprintf("lines[%d].amount", i), "is not a number")
+			continue
+		}
+		sum += toCents(line.Amount)
+	}
+
+	if sum != toCents(amount) {
+		verr.Add("lines", fmt.Sprintf("amounts add up to %.2f instead of %.2f", float64(sum)/100, amount))
+	}
+}
+
+// convertLines converts each line item using the rate of the parent transaction. Every line is rounded
+// down to the cent and the remaining cents are handed to the lines with the largest rounding loss, so that
+// the converted lines add up to the converted total.
+func convertLines(lines []record.LineItem, rate, converted float64) []record.ConvertedLineItem {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var (
+		cents     = make([]int64, len(lines))
+		fractions = make([]float64, len(lines))
+		order     = make([]int, len(lines))
+		sum       int64
+	)
+
+	for i, line := range lines {
+		raw := line.Amount * rate * 100
+		floor := math.Floor(raw)
+		cents[i] = int64(floor)
+		fractions[i] = raw - floor
+		order[i] = i
+		sum += cents[i]
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return fractions[order[a]] > fractions[order[b]]
+	})
+
+	residue := toCents(converted) - sum
+	for k := int64(0); residue > 0; k++ {
+		cents[order[k%int64(len(order))]]++
+		residue--
+	}
+	for k := int64(0); residue < 0; k++ {
+		cents[order[len(order)-1-int(k%int64(len(order)))]]--
+		residue++
+	}
+
+	out := make([]record.ConvertedLineItem, len(lines))
+	for i, line := range lines {
+		out[i] = record.ConvertedLineItem{
+			LineItem:  line,
+			Converted: float64(cents[i]) / 100,
+		}
+	}
+
+	return out
+}
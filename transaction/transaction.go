@@ -1,13 +1,20 @@
 package transaction
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/cespare/xxhash"
+	"github.com/suyono3484/transactiondemo/lock"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/tracing"
 	"github.com/suyono3484/transactiondemo/transaction/record"
 	"github.com/suyono3484/transactiondemo/types"
 	"math"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,21 +22,35 @@ type Config interface {
 	Repo() types.RepoI
 }
 
+// AddHook is called after a new transaction is stored. The context carries the values of the context of
+// Add but is never canceled, the transaction is stored already.
+type AddHook func(ctx context.Context, rec record.TransactionRecord)
+
 type TxModule struct {
 	config   Config
 	table    map[string]record.TransactionRecord
-	tableMtx *sync.RWMutex
+	tableMtx *lock.RWMutex
+	hooksMtx *sync.Mutex
+	hooks    []AddHook
+	quota    int
+	loaded   atomic.Bool
+
+	// pendingAudit holds the audit events of stored transactions that could not be written yet
+	pendingAudit []record.AuditEvent
 }
 
 func New(config Config) *TxModule {
 	return &TxModule{
 		config:   config,
 		table:    make(map[string]record.TransactionRecord),
-		tableMtx: &sync.RWMutex{},
+		tableMtx: lock.NewRWMutex(),
+		hooksMtx: &sync.Mutex{},
 	}
 }
 
-func (t *TxModule) Load() error {
+// Load reads the transaction file into the table. When ctx is done, it stops with a types.CanceledError
+// and the table holds the records read so far.
+func (t *TxModule) Load(ctx context.Context) error {
 	var (
 		n   int
 		err error
@@ -38,16 +59,20 @@ func (t *TxModule) Load() error {
 	)
 	buf := make([]record.TransactionRecord, 10)
 
-	t.tableMtx.Lock()
+	if err = t.tableMtx.Lock(ctx); err != nil {
+		return err
+	}
 	defer t.tableMtx.Unlock()
 
-	h = t.config.Repo().Open()
+	if h, err = t.config.Repo().Open(ctx); err != nil {
+		return err
+	}
 	defer func() {
 		_ = h.Close()
 	}()
 readRecords:
 	for {
-		n, err = h.ReadRecords(buf)
+		n, err = h.ReadRecords(ctx, buf)
 		if err != nil {
 			return err
 		}
@@ -60,64 +85,228 @@ readRecords:
 			t.table[rec.ID] = rec
 		}
 	}
+
+	t.loaded.Store(true)
 	return nil
 }
 
-func (t *TxModule) Add(description, date, amount string) error {
-	if len(description) > 50 {
-		return fmt.Errorf("%w: description is longer than 50 character", types.InvalidInputError)
-	}
+// Loaded reports whether Load has read the whole transaction file.
+func (t *TxModule) Loaded() bool {
+	return t.loaded.Load()
+}
 
+// NewRecord validates the input of a transaction and builds its record, including the identifier. When
+// lines are given, the transaction is split and the line amounts must add up to the transaction amount.
+// A failure is a *types.ValidationError naming every invalid field.
+func NewRecord(description, date, amount string, lines ...record.LineItem) (rec record.TransactionRecord, err error) {
 	var (
 		tDate   time.Time
-		err     error
 		fAmount float64
-		rec     record.TransactionRecord
-		ok      bool
-		h       types.RepoHandle
+		verr    types.ValidationError
 	)
 
-	tDate, err = time.Parse(record.FiscalDateFormat, date)
-	if err != nil {
-		return fmt.Errorf("%w: invalid date %w", types.InvalidInputError, err)
+	if len(description) > 50 {
+		verr.Add("description", "is longer than 50 character")
 	}
 
-	fAmount, err = strconv.ParseFloat(amount, 64)
-	if err != nil {
-		return fmt.Errorf("%w: invalid amount %w", types.InvalidInputError, err)
+	if tDate, err = time.Parse(record.FiscalDateFormat, date); err != nil {
+		verr.Add("date", fmt.Sprintf("is not a date in the %s format", record.FiscalDateFormat))
+	}
+
+	if fAmount, err = strconv.ParseFloat(amount, 64); err != nil || math.IsNaN(fAmount) || math.IsInf(fAmount, 0) {
+		verr.Add("amount", "is not a number")
+	} else {
+		validateLines(&verr, fAmount, lines)
+	}
+
+	if err = verr.Err(); err != nil {
+		return
 	}
 
 	rec = record.TransactionRecord{
 		Description: description,
 		Date:        record.FiscalDate(tDate),
 		Amount:      fAmount,
+		Lines:       lines,
+	}
+	// the line items are part of the identifier, an unsplit transaction keeps the identifier it always had
+	id := fmt.Sprintf("%s%s%f", description, tDate.Format(time.RFC3339), fAmount)
+	if len(lines) > 0 {
+		var b []byte
+		if b, err = json.Marshal(lines); err != nil {
+			return
+		}
+		id += string(b)
+	}
+	rec.ID = fmt.Sprintf("%x", xxhash.Sum64String(id))
+
+	return
+}
+
+// Add stores a new transaction on behalf of the principal in the context, see types.WithPrincipal. The
+// creation is recorded in the audit log. When the log cannot be written, the transaction is stored anyway
+// and its event is written before the next transaction is stored; no transaction is stored until then. See
+// NewRecord for the validation rules.
+func (t *TxModule) Add(ctx context.Context, description, date, amount string, lines ...record.LineItem) error {
+	_, _, err := t.Create(ctx, description, date, amount, lines...)
+	return err
+}
+
+// Create stores a new transaction like Add and returns its record. When the same transaction is stored
+// already, nothing is stored and the record returned is the stored one, with created unset.
+func (t *TxModule) Create(ctx context.Context, description, date, amount string, lines ...record.LineItem) (rec record.TransactionRecord, created bool, err error) {
+	if rec, err = NewRecord(description, date, amount, lines...); err != nil {
+		return
+	}
+
+	if rec, created, err = t.store(ctx, rec); err != nil || !created {
+		return
 	}
-	rec.ID = fmt.Sprintf("%x",
-		xxhash.Sum64String(
-			fmt.Sprintf("%s%s%f", description, tDate.Format(time.RFC3339), fAmount)))
 
-	t.tableMtx.Lock()
+	t.hooksMtx.Lock()
+	hooks := t.hooks
+	t.hooksMtx.Unlock()
+
+	hookCtx := context.WithoutCancel(ctx)
+	for _, hook := range hooks {
+		hook(hookCtx, rec)
+	}
+
+	return
+}
+
+// OnAdd registers a hook called after each new transaction. The hooks run outside the table lock, so they
+// may call back into the module.
+func (t *TxModule) OnAdd(hook AddHook) {
+	t.hooksMtx.Lock()
+	defer t.hooksMtx.Unlock()
+
+	t.hooks = append(t.hooks, hook)
+}
+
+// SetQuota limits the number of transactions Add accepts to max. Zero means no limit. Transactions already
+// in the file are loaded regardless of the quota.
+func (t *TxModule) SetQuota(max int) {
+	// the background context is never done, so the lock cannot fail
+	_ = t.tableMtx.Lock(context.Background())
 	defer t.tableMtx.Unlock()
 
-	if _, ok = t.table[rec.ID]; !ok {
-		h = t.config.Repo().Open()
-		defer func() {
-			_ = h.Close()
-		}()
+	t.quota = max
+}
 
-		_, err = h.AppendRecords([]record.TransactionRecord{rec})
-		if err != nil {
-			return fmt.Errorf("%w: %w", types.ServerError, err)
-		}
+// store appends the record to the transaction file unless its transaction is in the table already. It returns
+// the record in the table and whether it was added.
+func (t *TxModule) store(ctx context.Context, rec record.TransactionRecord) (record.TransactionRecord, bool, error) {
+	if err := t.tableMtx.Lock(ctx); err != nil {
+		return rec, false, err
+	}
+	defer t.tableMtx.Unlock()
+
+	// a transaction stored before is audited before anything else, a retry of the same transaction included
+	if err := t.writePendingAudit(ctx); err != nil {
+		return rec, false, err
+	}
+
+	if stored, ok := t.table[rec.ID]; ok {
+		return stored, false, nil
+	}
+
+	if t.quota > 0 && len(t.table) >= t.quota {
+		return rec, false, fmt.Errorf("%w: the quota of %d transactions is reached",
+			types.QuotaExceededError, t.quota)
+	}
+
+	h, err := t.config.Repo().Open(ctx)
+	if err != nil {
+		return rec, false, err
+	}
+	defer func() {
+		_ = h.Close()
+	}()
+
+	if _, err = h.AppendRecords(ctx, []record.TransactionRecord{rec}); err != nil {
+		return rec, false, types.AsServerError(err)
+	}
+
+	// the record is in the data file at this point, so it belongs in the table even if the audit fails, and
+	// the audit is written even if ctx is done
+	t.table[rec.ID] = rec
 
-		t.table[rec.ID] = rec
+	event := newAuditEvent(ctx, record.AuditCreate, nil, &rec)
+	if err = t.config.Repo().AppendAuditEvent(context.WithoutCancel(ctx), event); err != nil {
+		logging.FromContext(ctx).Error("writing the audit event, retrying with the next transaction",
+			"transaction", rec.ID, "event", event.ID, "error", err)
+		t.pendingAudit = append(t.pendingAudit, event)
+	}
+
+	return rec, true, nil
+}
+
+// writePendingAudit writes the audit events that failed before, in order. The table lock must be held.
+func (t *TxModule) writePendingAudit(ctx context.Context) error {
+	for len(t.pendingAudit) > 0 {
+		if err := t.config.Repo().AppendAuditEvent(ctx, t.pendingAudit[0]); err != nil {
+			return types.AsServerError(fmt.Errorf("the audit log is unavailable, %d events are pending: %w",
+				len(t.pendingAudit), err))
+		}
+		t.pendingAudit = t.pendingAudit[1:]
 	}
 
 	return nil
 }
 
-func (t *TxModule) List() []record.TransactionRecord {
-	t.tableMtx.RLock()
+// History returns the audit events of a transaction, oldest first.
+func (t *TxModule) History(ctx context.Context, id string) ([]record.AuditEvent, error) {
+	events, err := t.config.Repo().ReadAuditEvents(ctx, id)
+	if err != nil {
+		return nil, types.AsServerError(err)
+	}
+
+	if len(events) == 0 {
+		if err = t.tableMtx.RLock(ctx); err != nil {
+			return nil, err
+		}
+		_, ok := t.table[id]
+		t.tableMtx.RUnlock()
+
+		if !ok {
+			return nil, types.RecordNotFound
+		}
+	}
+
+	return events, nil
+}
+
+func newAuditEvent(ctx context.Context, action string, before, after *record.TransactionRecord) record.AuditEvent {
+	var (
+		p   = types.PrincipalFrom(ctx)
+		now = time.Now().UTC()
+		id  string
+	)
+
+	if after != nil {
+		id = after.ID
+	} else {
+		id = before.ID
+	}
+
+	return record.AuditEvent{
+		ID:            fmt.Sprintf("%x", xxhash.Sum64String(fmt.Sprintf("%s%s%s", id, action, now.Format(time.RFC3339Nano)))),
+		TransactionID: id,
+		Action:        action,
+		Actor:         p.Name,
+		Source:        p.Source,
+		ClientIP:      p.ClientIP,
+		Time:          now,
+		Before:        before,
+		After:         after,
+	}
+}
+
+func (t *TxModule) List(ctx context.Context) ([]record.TransactionRecord, error) {
+	if err := t.tableMtx.RLock(ctx); err != nil {
+		return nil, err
+	}
 	defer t.tableMtx.RUnlock()
 
 	recs := make([]record.TransactionRecord, 0)
@@ -126,19 +315,42 @@ func (t *TxModule) List() []record.TransactionRecord {
 		recs = append(recs, rec)
 	}
 
-	return recs
+	return recs, nil
 }
 
-func (t *TxModule) Get(id, targetCurrency string) (outRec record.ConvertedTransaction, err error) {
-	t.tableMtx.RLock()
+// Count returns the number of transactions.
+func (t *TxModule) Count(ctx context.Context) (int, error) {
+	if err := t.tableMtx.RLock(ctx); err != nil {
+		return 0, err
+	}
 	defer t.tableMtx.RUnlock()
 
+	return len(t.table), nil
+}
+
+func (t *TxModule) Get(ctx context.Context, id, targetCurrency string) (outRec record.ConvertedTransaction, err error) {
+	ctx, span := tracing.Start(ctx, "TxModule.Get", tracing.KindInternal)
+	span.SetAttribute("transaction.id", id)
+	span.SetAttribute("currency", targetCurrency)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	wait := time.Now()
+	if err = t.tableMtx.RLock(ctx); err != nil {
+		return
+	}
+	defer t.tableMtx.RUnlock()
+	span.SetAttribute("lock.wait_seconds", time.Since(wait).Seconds())
+
 	var (
 		ok     bool
 		rec    record.TransactionRecord
 		txDate time.Time
 		start  time.Time
 		frecs  []record.FiscalRecord
+		date   record.FiscalDate
 	)
 
 	rec, ok = t.table[id]
@@ -148,6 +360,12 @@ func (t *TxModule) Get(id, targetCurrency string) (outRec record.ConvertedTransa
 	}
 
 	outRec.TransactionRecord = rec
+	defer func() {
+		if err == nil {
+			outRec.Lines = convertLines(rec.Lines, outRec.Rate, outRec.Converted)
+		}
+	}()
+
 	if targetCurrency == types.DefaultCurrency {
 		outRec.Rate = 1
 		outRec.Converted = math.Round(rec.Amount*100) / 100
@@ -156,9 +374,9 @@ func (t *TxModule) Get(id, targetCurrency string) (outRec record.ConvertedTransa
 
 	txDate = time.Time(rec.Date)
 	start = txDate.AddDate(0, -6, 0)
-	_, outRec.Rate, err = t.config.Repo().CacheGetExchangeRate(targetCurrency, start, txDate)
+	date, outRec.Rate, err = t.config.Repo().CacheGetExchangeRate(ctx, targetCurrency, start, txDate)
 	if err != nil {
-		frecs, err = t.config.Repo().FetchFiscalData(targetCurrency, start, txDate)
+		frecs, err = t.config.Repo().FetchFiscalData(ctx, targetCurrency, start, txDate)
 		if err != nil {
 			return
 		}
@@ -170,10 +388,62 @@ func (t *TxModule) Get(id, targetCurrency string) (outRec record.ConvertedTransa
 
 		t.config.Repo().CacheSetExchangeRate(targetCurrency, frecs[0].EffectiveDate, frecs[0].ExchangeRate.Float())
 		outRec.Rate = frecs[0].ExchangeRate.Float()
+		outRec.EffectiveDate = &frecs[0].EffectiveDate
 		outRec.Converted = math.Round(outRec.Amount*outRec.Rate*100) / 100
 		return
 	}
 
+	outRec.EffectiveDate = &date
 	outRec.Converted = math.Round(outRec.Amount*outRec.Rate*100) / 100
 	return
 }
+
+// Range calls fn for each transaction matching the filter, ordered by date and ID. Unlike List, it does not
+// copy the table: only the matching IDs are collected up front, and each record is read when its turn
+// comes, so fn runs without holding the table lock. When ctx is done, it stops with a types.CanceledError
+// before the next record.
+func (t *TxModule) Range(ctx context.Context, filter record.Filter, fn func(rec record.TransactionRecord) error) error {
+	if err := t.tableMtx.RLock(ctx); err != nil {
+		return err
+	}
+	type key struct {
+		date time.Time
+		id   string
+	}
+	keys := make([]key, 0)
+	for id, rec := range t.table {
+		if filter.Matches(rec) {
+			keys = append(keys, key{date: rec.Date.Date(), id: id})
+		}
+	}
+	t.tableMtx.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].date.Equal(keys[j].date) {
+			return keys[i].id < keys[j].id
+		}
+		return keys[i].date.Before(keys[j].date)
+	})
+
+	for _, k := range keys {
+		if ctx.Err() != nil {
+			return types.Canceled(ctx, "ranging over the transactions")
+		}
+
+		if err := t.tableMtx.RLock(ctx); err != nil {
+			return err
+		}
+		rec, ok := t.table[k.id]
+		t.tableMtx.RUnlock()
+
+		if !ok {
+			continue
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,12 +1,15 @@
 package transaction_test
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/suyono3484/transactiondemo"
 	repoModule "github.com/suyono3484/transactiondemo/repository"
 	tx "github.com/suyono3484/transactiondemo/transaction"
 	"github.com/suyono3484/transactiondemo/transaction/record"
 	"github.com/suyono3484/transactiondemo/types"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -41,18 +44,19 @@ func TestTxModule_Get(t *testing.T) {
 	amount1 := "12.15"
 	amount2 := "34.75"
 
-	if err = transaction.Add("transaction 1", date1.Format(record.FiscalDateFormat), amount1); err != nil {
+	if err = transaction.Add(context.Background(), "transaction 1", date1.Format(record.FiscalDateFormat), amount1); err != nil {
 		t.Fatal(err)
 	}
 
-	if err = transaction.Add("transaction 2", date2.Format(record.FiscalDateFormat), amount2); err != nil {
+	if err = transaction.Add(context.Background(), "transaction 2", date2.Format(record.FiscalDateFormat), amount2); err != nil {
 		t.Fatal(err)
 	}
 
-	list := transaction.List()
+	list, err := transaction.List(context.Background())
+	assert.NoError(t, err)
 	var or record.ConvertedTransaction
 	for _, l := range list {
-		or, err = transaction.Get(l.ID, "Canada-Dollar")
+		or, err = transaction.Get(context.Background(), l.ID, "Canada-Dollar")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -77,10 +81,239 @@ func TestTxModule_List(t *testing.T) {
 
 	transaction = tx.New(app)
 
-	_ = transaction.Add("transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
-	_ = transaction.Add("transaction 1", time.Now().Format(record.FiscalDateFormat), "12.17")
+	_ = transaction.Add(context.Background(), "transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
+	_ = transaction.Add(context.Background(), "transaction 1", time.Now().Format(record.FiscalDateFormat), "12.17")
 
-	assert.Equal(t, 2, len(transaction.List()))
+	list, err := transaction.List(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+}
+
+func TestTxModule_Create(t *testing.T) {
+	app := &transactiondemo.App{
+		AppSkipFile: true,
+	}
+	app.AppRepo = repoModule.New(app)
+	transaction := tx.New(app)
+
+	date := time.Now().Format(record.FiscalDateFormat)
+	rec, created, err := transaction.Create(context.Background(), "transaction 1", date, "12.15")
+	if assert.NoError(t, err) {
+		assert.True(t, created)
+		assert.Equal(t, "transaction 1", rec.Description)
+	}
+
+	again, created, err := transaction.Create(context.Background(), "transaction 1", date, "12.15")
+	if assert.NoError(t, err) {
+		assert.False(t, created)
+		assert.Equal(t, rec, again)
+	}
+}
+
+func TestTxModule_AddSplit(t *testing.T) {
+	app := &transactiondemo.App{
+		AppSkipFile: true,
+	}
+
+	repo := repoModule.New(app)
+	app.AppRepo = repo
+
+	transaction := tx.New(app)
+	date := time.Now().Format(record.FiscalDateFormat)
+
+	err := transaction.Add(context.Background(), "split", date, "10.00",
+		record.LineItem{Category: "travel", Amount: 3.33},
+		record.LineItem{Category: "meals", Amount: 3.33})
+	assert.ErrorIs(t, err, types.InvalidInputError)
+
+	err = transaction.Add(context.Background(), "split", date, "10.00",
+		record.LineItem{Category: "travel", Amount: 3.33},
+		record.LineItem{Category: "meals", Amount: 3.33},
+		record.LineItem{Category: "office", Amount: 3.34})
+	assert.NoError(t, err)
+
+	list, err := transaction.List(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Len(t, list[0].Lines, 3)
+	}
+}
+
+func TestNewRecord_LinesID(t *testing.T) {
+	date := time.Now().Format(record.FiscalDateFormat)
+
+	plain, err := tx.NewRecord("split", date, "10.00")
+	assert.NoError(t, err)
+	split1, err := tx.NewRecord("split", date, "10.00",
+		record.LineItem{Category: "travel", Amount: 4},
+		record.LineItem{Category: "meals", Amount: 6})
+	assert.NoError(t, err)
+	split2, err := tx.NewRecord("split", date, "10.00",
+		record.LineItem{Category: "travel", Amount: 5},
+		record.LineItem{Category: "meals", Amount: 5})
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, plain.ID, split1.ID)
+	assert.NotEqual(t, split1.ID, split2.ID)
+
+	again, _ := tx.NewRecord("split", date, "10.00",
+		record.LineItem{Category: "travel", Amount: 4},
+		record.LineItem{Category: "meals", Amount: 6})
+	assert.Equal(t, split1.ID, again.ID)
+}
+
+func TestNewRecord_NotFinite(t *testing.T) {
+	date := time.Now().Format(record.FiscalDateFormat)
+
+	for _, amount := range []string{"NaN", "Inf", "-Inf", "1e400"} {
+		_, err := tx.NewRecord("not finite", date, amount)
+		assert.ErrorIs(t, err, types.InvalidInputError, amount)
+	}
+
+	_, err := tx.NewRecord("not finite", date, "10", record.LineItem{Amount: math.NaN()}, record.LineItem{Amount: 10})
+	assert.ErrorIs(t, err, types.InvalidInputError)
+}
+
+func TestTxModule_GetSplit(t *testing.T) {
+	app := &transactiondemo.App{
+		AppSkipFile: true,
+	}
+
+	repo := repoModule.New(app)
+	app.AppRepo = repo
+
+	transaction := tx.New(app)
+
+	txDate := time.Now().AddDate(0, 0, -1)
+	rateDate, _ := time.Parse(record.FiscalDateFormat, txDate.AddDate(0, 0, -7).Format(record.FiscalDateFormat))
+	cDesc := "Canada-Dollar"
+	repo.CacheSetExchangeRate(cDesc, record.FiscalDate(rateDate), 1.3333)
+
+	err := transaction.Add(context.Background(), "split", txDate.Format(record.FiscalDateFormat), "10.00",
+		record.LineItem{Amount: 3.33},
+		record.LineItem{Amount: 3.33},
+		record.LineItem{Amount: 3.34})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := transaction.List(context.Background())
+	assert.NoError(t, err)
+	or, err := transaction.Get(context.Background(), list[0].ID, cDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 13.33, or.Converted)
+	if assert.Len(t, or.Lines, 3) {
+		var sum float64
+		for _, l := range or.Lines {
+			sum += l.Converted
+		}
+		assert.InDelta(t, or.Converted, sum, 0.001)
+	}
+}
+
+func TestTxModule_History(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	app := &transactiondemo.App{
+		AppFilePath: filepath.Join(dir, "data.json"),
+	}
+	app.AppRepo = repoModule.New(app)
+	transaction := tx.New(app)
+
+	ctx := types.WithPrincipal(context.Background(), types.Principal{
+		Name:     "alice",
+		Source:   types.SourceHTTP,
+		ClientIP: "192.0.2.1",
+	})
+	date := time.Now().Format(record.FiscalDateFormat)
+	assert.NoError(t, transaction.Add(ctx, "transaction 1", date, "12.15"))
+	assert.NoError(t, transaction.Add(ctx, "transaction 1", date, "12.15"))
+
+	list, err := transaction.List(context.Background())
+	assert.NoError(t, err)
+	events, err := transaction.History(context.Background(), list[0].ID)
+	if assert.NoError(t, err) && assert.Len(t, events, 1) {
+		assert.Equal(t, record.AuditCreate, events[0].Action)
+		assert.Equal(t, "alice", events[0].Actor)
+		assert.Equal(t, "192.0.2.1", events[0].ClientIP)
+		assert.Nil(t, events[0].Before)
+		assert.Equal(t, list[0].ID, events[0].After.ID)
+	}
+
+	_, err = transaction.History(context.Background(), "unknown")
+	assert.ErrorIs(t, err, types.RecordNotFound)
+}
+
+// failingAudit fails to write the audit events while fail is set.
+type failingAudit struct {
+	types.RepoI
+	fail bool
+}
+
+func (r *failingAudit) AppendAuditEvent(ctx context.Context, event record.AuditEvent) error {
+	if r.fail {
+		return errors.New("disk full")
+	}
+	return r.RepoI.AppendAuditEvent(ctx, event)
+}
+
+func TestTxModule_AuditRetry(t *testing.T) {
+	app := &transactiondemo.App{
+		AppFilePath: filepath.Join(t.TempDir(), "data.json"),
+	}
+	repo := &failingAudit{RepoI: repoModule.New(app), fail: true}
+	app.AppRepo = repo
+	transaction := tx.New(app)
+
+	// the transaction is stored, its audit event waits
+	date := time.Now().Format(record.FiscalDateFormat)
+	assert.NoError(t, transaction.Add(context.Background(), "transaction 1", date, "12.15"))
+	list, err := transaction.List(context.Background())
+	if !assert.NoError(t, err) || !assert.Len(t, list, 1) {
+		return
+	}
+
+	// nothing else is stored while the audit log fails
+	assert.ErrorIs(t, transaction.Add(context.Background(), "transaction 2", date, "10"), types.ServerError)
+	count, _ := transaction.Count(context.Background())
+	assert.Equal(t, 1, count)
+
+	// a retry of the same transaction writes the pending event
+	repo.fail = false
+	assert.NoError(t, transaction.Add(context.Background(), "transaction 1", date, "12.15"))
+	events, err := transaction.History(context.Background(), list[0].ID)
+	if assert.NoError(t, err) {
+		assert.Len(t, events, 1)
+	}
+}
+
+func TestTxModule_Quota(t *testing.T) {
+	app := &transactiondemo.App{
+		AppSkipFile: true,
+	}
+	app.AppRepo = repoModule.New(app)
+	transaction := tx.New(app)
+	transaction.SetQuota(2)
+
+	date := time.Now().Format(record.FiscalDateFormat)
+	assert.NoError(t, transaction.Add(context.Background(), "transaction 1", date, "12.15"))
+	assert.NoError(t, transaction.Add(context.Background(), "transaction 2", date, "12.15"))
+	assert.ErrorIs(t, transaction.Add(context.Background(), "transaction 3", date, "12.15"), types.QuotaExceededError)
+
+	// a duplicate is not a new transaction
+	assert.NoError(t, transaction.Add(context.Background(), "transaction 1", date, "12.15"))
+	n, err := transaction.Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
 }
 
 func TestTxModule_Load(t *testing.T) {
@@ -92,11 +325,12 @@ func TestTxModule_Load(t *testing.T) {
 	}()
 
 	transaction := writeAndReread(fileName)
-	if err := transaction.Load(); err != nil {
+	if err := transaction.Load(context.Background()); err != nil {
 		t.Fatal(err)
 	}
 
-	list := transaction.List()
+	list, err := transaction.List(context.Background())
+	assert.NoError(t, err)
 	assert.Equal(t, 2, len(list))
 }
 
@@ -122,8 +356,8 @@ func writeAndReread(name string) *tx.TxModule {
 	app.AppRepo = repo
 
 	transaction := tx.New(app)
-	_ = transaction.Add("transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
-	_ = transaction.Add("transaction 2", time.Now().Format(record.FiscalDateFormat), "12.17")
+	_ = transaction.Add(context.Background(), "transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
+	_ = transaction.Add(context.Background(), "transaction 2", time.Now().Format(record.FiscalDateFormat), "12.17")
 
 	app = &transactiondemo.App{
 		AppSkipFile: false,
@@ -134,9 +368,40 @@ func writeAndReread(name string) *tx.TxModule {
 	app.AppRepo = repo
 
 	transaction = tx.New(app)
-	if err := transaction.Load(); err != nil {
+	if err := transaction.Load(context.Background()); err != nil {
 		panic(err)
 	}
 
 	return transaction
 }
+
+func TestTxModule_Canceled(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "data.json")
+	app := &transactiondemo.App{AppFilePath: fileName}
+	app.AppRepo = repoModule.New(app)
+
+	transaction := tx.New(app)
+	date := time.Now().Format(record.FiscalDateFormat)
+	assert.NoError(t, transaction.Add(context.Background(), "transaction 1", date, "12.15"))
+	assert.NoError(t, transaction.Add(context.Background(), "transaction 2", date, "12.17"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var seen int
+	err := transaction.Range(ctx, record.Filter{}, func(rec record.TransactionRecord) error {
+		seen++
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, types.CanceledError)
+	assert.Equal(t, 1, seen)
+
+	assert.ErrorIs(t, transaction.Add(ctx, "transaction 3", date, "12.19"), types.CanceledError)
+	assert.ErrorIs(t, tx.New(app).Load(ctx), types.CanceledError)
+
+	_, err = transaction.History(ctx, "unknown")
+	assert.ErrorIs(t, err, types.CanceledError)
+
+	n, err := transaction.Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
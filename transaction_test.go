@@ -1,6 +1,7 @@
 package transactiondemo_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	. "github.com/onsi/ginkgo/v2"
@@ -68,16 +69,17 @@ var _ = Describe("retrieve a transaction using exchange rate", func() {
 		app.AppRepo = repo
 		transaction := tx.New(app)
 
-		err = transaction.Add("transaction 1",
+		err = transaction.Add(context.Background(), "transaction 1",
 			time.Now().Format(record.FiscalDateFormat),
 			fmt.Sprintf("%f", amount))
 		Expect(err).ToNot(HaveOccurred())
 
-		list := transaction.List()
+		list, err := transaction.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
 		Expect(list).To(HaveLen(1))
 
 		var outRec record.ConvertedTransaction
-		outRec, err = transaction.Get(list[0].ID, currDesc)
+		outRec, err = transaction.Get(context.Background(), list[0].ID, currDesc)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(outRec.Rate).To(Equal(testExchange))
 		Expect(outRec.Converted).To(Equal(math.Round(amount*testExchange*100) / 100))
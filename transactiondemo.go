@@ -1,7 +1,17 @@
 package transactiondemo
 
 import (
+	"fmt"
+	"github.com/suyono3484/transactiondemo/auth"
+	"github.com/suyono3484/transactiondemo/config"
+	"github.com/suyono3484/transactiondemo/keyring"
+	"github.com/suyono3484/transactiondemo/logging"
+	"github.com/suyono3484/transactiondemo/metrics"
+	"github.com/suyono3484/transactiondemo/ratelimit"
+	"github.com/suyono3484/transactiondemo/tracing"
 	"github.com/suyono3484/transactiondemo/types"
+	"sync/atomic"
+	"time"
 )
 
 type App struct {
@@ -9,7 +19,25 @@ type App struct {
 	AppFilePath        string
 	AppRepo            types.RepoI
 	AppExchangeRateURL string
+	AppCacheTTL        time.Duration
+	AppUpstreamTimeout time.Duration
 	AppTransaction     types.TxI
+	AppScheduler       types.ScheduleI
+	AppBudget          types.BudgetI
+	AppNotifier        types.Notifier
+	AppKeyring         *keyring.Keyring
+	AppImporter        types.ImporterI
+	AppExporter        types.ExporterI
+	AppAuthenticator   *auth.Authenticator
+	AppTenants         types.TenantsI
+	AppRateLimiter     *ratelimit.Limiter
+	AppMetrics         *metrics.Registry
+	AppTracer          *tracing.Tracer
+	AppLogger          *logging.Logger
+	AppVersion         string
+	AppReloader        *config.Reloader
+
+	exchangeRateURL atomic.Pointer[string]
 }
 
 func (a *App) SkipFile() bool {
@@ -24,10 +52,94 @@ func (a *App) Repo() types.RepoI {
 	return a.AppRepo
 }
 
+// ExchangeRateURL returns the URL given to SetExchangeRateURL, or else AppExchangeRateURL.
 func (a *App) ExchangeRateURL() string {
+	if url := a.exchangeRateURL.Load(); url != nil {
+		return *url
+	}
+
 	return a.AppExchangeRateURL
 }
 
+// SetExchangeRateURL changes the URL of the rate provider while the app is in use.
+func (a *App) SetExchangeRateURL(url string) {
+	a.exchangeRateURL.Store(&url)
+}
+
+func (a *App) CacheTTL() time.Duration {
+	return a.AppCacheTTL
+}
+
+func (a *App) UpstreamTimeout() time.Duration {
+	return a.AppUpstreamTimeout
+}
+
 func (a *App) Transaction() types.TxI {
 	return a.AppTransaction
 }
+
+func (a *App) Scheduler() types.ScheduleI {
+	return a.AppScheduler
+}
+
+func (a *App) Budget() types.BudgetI {
+	return a.AppBudget
+}
+
+func (a *App) Notifier() types.Notifier {
+	return a.AppNotifier
+}
+
+func (a *App) Keyring() *keyring.Keyring {
+	return a.AppKeyring
+}
+
+func (a *App) Importer() types.ImporterI {
+	return a.AppImporter
+}
+
+func (a *App) Exporter() types.ExporterI {
+	return a.AppExporter
+}
+
+func (a *App) Authenticator() *auth.Authenticator {
+	return a.AppAuthenticator
+}
+
+func (a *App) RateLimiter() *ratelimit.Limiter {
+	return a.AppRateLimiter
+}
+
+func (a *App) Metrics() *metrics.Registry {
+	return a.AppMetrics
+}
+
+func (a *App) Tracer() *tracing.Tracer {
+	return a.AppTracer
+}
+
+func (a *App) Logger() *logging.Logger {
+	return a.AppLogger
+}
+
+func (a *App) Version() string {
+	return a.AppVersion
+}
+
+func (a *App) Reloader() *config.Reloader {
+	return a.AppReloader
+}
+
+// Tenant returns the modules of the tenant. The app itself serves the default tenant; the other tenants
+// require AppTenants.
+func (a *App) Tenant(name string) (types.TenantI, error) {
+	if name == "" || name == types.DefaultTenant {
+		return a, nil
+	}
+
+	if a.AppTenants == nil {
+		return nil, fmt.Errorf("%w: unknown tenant %q, multi-tenancy is not enabled", types.InvalidInputError, name)
+	}
+
+	return a.AppTenants.Get(name)
+}
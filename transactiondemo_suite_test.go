@@ -1,6 +1,7 @@
 package transactiondemo_test
 
 import (
+	"context"
 	"github.com/suyono3484/transactiondemo"
 	"github.com/suyono3484/transactiondemo/transaction/record"
 	"os"
@@ -38,7 +39,7 @@ var _ = Describe("Transaction", func() {
 
 	When("add a valid new transaction", func() {
 		It("accepts a description, a transaction date, and a transaction amount", func() {
-			Expect(transaction.Add("description", time.Now().Format(record.FiscalDateFormat), "12.15")).NotTo(HaveOccurred())
+			Expect(transaction.Add(context.Background(), "description", time.Now().Format(record.FiscalDateFormat), "12.15")).NotTo(HaveOccurred())
 		})
 
 		Context("working with file", func() {
@@ -57,16 +58,18 @@ var _ = Describe("Transaction", func() {
 			It("stores the transaction into a file", func() {
 				transaction = writeAndReread(fileName)
 
-				list := transaction.List()
+				list, err := transaction.List(context.Background())
+				Expect(err).ToNot(HaveOccurred())
 				Expect(list).To(HaveLen(2))
 			})
 		})
 
 		It("assigns a unique identifier for each transaction", func() {
-			_ = transaction.Add("transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
-			_ = transaction.Add("transaction 2", time.Now().Format(record.FiscalDateFormat), "12.17")
+			_ = transaction.Add(context.Background(), "transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
+			_ = transaction.Add(context.Background(), "transaction 2", time.Now().Format(record.FiscalDateFormat), "12.17")
 
-			list := transaction.List()
+			list, err := transaction.List(context.Background())
+			Expect(err).ToNot(HaveOccurred())
 			GinkgoWriter.Printf("data: %+v\n", list)
 
 			m := make(map[string]any)
@@ -85,15 +88,15 @@ var _ = Describe("Transaction", func() {
 
 		It("rejects a description with length over 50 characters", func() {
 			longText := `Lorem ipsum dolor sit amet, consectetur adipiscing e`
-			Expect(transaction.Add(longText, validTime, validAmount)).To(HaveOccurred())
+			Expect(transaction.Add(context.Background(), longText, validTime, validAmount)).To(HaveOccurred())
 		})
 
 		It("rejects an invalid/malformed date", func() {
-			Expect(transaction.Add(validDescription, "invalid date", validAmount)).To(HaveOccurred())
+			Expect(transaction.Add(context.Background(), validDescription, "invalid date", validAmount)).To(HaveOccurred())
 		})
 
 		It("rejects an invalid amount", func() {
-			Expect(transaction.Add(validDescription, validTime, "three dollars and fifty cents")).To(HaveOccurred())
+			Expect(transaction.Add(context.Background(), validDescription, validTime, "three dollars and fifty cents")).To(HaveOccurred())
 		})
 	})
 })
@@ -120,8 +123,8 @@ func writeAndReread(name string) *tx.TxModule {
 	app.AppRepo = repo
 
 	transaction := tx.New(app)
-	_ = transaction.Add("transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
-	_ = transaction.Add("transaction 2", time.Now().Format(record.FiscalDateFormat), "12.17")
+	_ = transaction.Add(context.Background(), "transaction 1", time.Now().Format(record.FiscalDateFormat), "12.15")
+	_ = transaction.Add(context.Background(), "transaction 2", time.Now().Format(record.FiscalDateFormat), "12.17")
 
 	app = &transactiondemo.App{
 		AppSkipFile: false,
@@ -132,7 +135,7 @@ func writeAndReread(name string) *tx.TxModule {
 	app.AppRepo = repo
 
 	transaction = tx.New(app)
-	if err := transaction.Load(); err != nil {
+	if err := transaction.Load(context.Background()); err != nil {
 		panic(err)
 	}
 
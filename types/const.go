@@ -1,4 +1,12 @@
 package types
 
+import "time"
+
 const DefaultCurrency = "US-Dollar"
 const ExchangeRateURL = "https://api.fiscaldata.treasury.gov/services/api/fiscal_service/v1/accounting/od/rates_of_exchange"
+
+// DefaultCacheTTL is the time the exchange rates are cached for when no TTL is configured.
+const DefaultCacheTTL = 12 * time.Hour
+
+// DefaultUpstreamTimeout bounds a request to the exchange rate provider when no timeout is configured.
+const DefaultUpstreamTimeout = 30 * time.Second
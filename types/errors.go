@@ -1,6 +1,10 @@
 package types
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 var (
 	InvalidInputError         = errors.New("invalid input")
@@ -8,4 +12,44 @@ var (
 	CacheNoDataError          = errors.New("cache no data or too old")
 	RecordNotFound            = errors.New("record not found")
 	TargetCurrencyUnavailable = errors.New("target currency unavailable")
+	ChainBrokenError          = errors.New("hash chain broken")
+	UnauthenticatedError      = errors.New("unauthenticated")
+	ForbiddenError            = errors.New("forbidden")
+	QuotaExceededError        = errors.New("quota exceeded")
+	TooManyRequestsError      = errors.New("too many requests")
+	TooLargeError             = errors.New("request too large")
+	CanceledError             = errors.New("canceled")
 )
+
+// Errors lists every sentinel above, so the code mapping them, e.g. to HTTP responses, can be checked
+// for completeness.
+var Errors = []error{
+	InvalidInputError,
+	ServerError,
+	CacheNoDataError,
+	RecordNotFound,
+	TargetCurrencyUnavailable,
+	ChainBrokenError,
+	UnauthenticatedError,
+	ForbiddenError,
+	QuotaExceededError,
+	TooManyRequestsError,
+	TooLargeError,
+	CanceledError,
+}
+
+// Canceled returns the error of an operation aborted because ctx is done. It is a CanceledError and also
+// matches the error of the context, context.Canceled or context.DeadlineExceeded.
+func Canceled(ctx context.Context, op string) error {
+	return fmt.Errorf("%w: %s: %w", CanceledError, op, ctx.Err())
+}
+
+// AsServerError wraps err as a ServerError, unless err is a CanceledError: the caller gave up, the server
+// did not fail.
+func AsServerError(err error) error {
+	if errors.Is(err, CanceledError) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ServerError, err)
+}
@@ -0,0 +1,53 @@
+package types_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/suyono3484/transactiondemo/types"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+// TestErrors checks that every sentinel declared in errors.go is listed in types.Errors.
+func TestErrors(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(map[string]bool)
+	for _, e := range types.Errors {
+		listed[e.Error()] = true
+	}
+
+	declared := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "New" || len(call.Args) != 1 {
+			return true
+		}
+
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+
+		msg, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		declared++
+		assert.Truef(t, listed[msg], "the sentinel %q is missing from types.Errors", msg)
+		return true
+	})
+
+	assert.Equal(t, declared, len(types.Errors))
+}
@@ -1,26 +1,85 @@
 package types
 
 import (
+	"context"
 	"github.com/suyono3484/transactiondemo/transaction/record"
+	"io"
 	"time"
 )
 
+// TxI and the interfaces below take the context of the caller. When it is done, the waits for locks, the
+// file scans and the requests to the rate provider are aborted with a CanceledError.
 type TxI interface {
-	Load() error
-	Add(description, date, amount string) error
-	List() []record.TransactionRecord
-	Get(id, targetCurrency string) (outRec record.ConvertedTransaction, err error)
+	Load(ctx context.Context) error
+	Loaded() bool
+	Add(ctx context.Context, description, date, amount string, lines ...record.LineItem) error
+	Create(ctx context.Context, description, date, amount string, lines ...record.LineItem) (rec record.TransactionRecord, created bool, err error)
+	List(ctx context.Context) ([]record.TransactionRecord, error)
+	Count(ctx context.Context) (int, error)
+	Get(ctx context.Context, id, targetCurrency string) (outRec record.ConvertedTransaction, err error)
+	History(ctx context.Context, id string) ([]record.AuditEvent, error)
+	Range(ctx context.Context, filter record.Filter, fn func(rec record.TransactionRecord) error) error
 }
 
 type RepoI interface {
-	Open() RepoHandle
-	CacheGetExchangeRate(cDesc string, start, txDate time.Time) (date record.FiscalDate, rate float64, err error)
+	Open(ctx context.Context) (RepoHandle, error)
+	CacheGetExchangeRate(ctx context.Context, cDesc string, start, txDate time.Time) (date record.FiscalDate, rate float64, err error)
 	CacheSetExchangeRate(cDesc string, date record.FiscalDate, rate float64)
-	FetchFiscalData(cDesc string, start, txDate time.Time) ([]record.FiscalRecord, error)
+	FetchFiscalData(ctx context.Context, cDesc string, start, txDate time.Time) ([]record.FiscalRecord, error)
+	ReadSchedules(ctx context.Context) ([]record.Schedule, error)
+	WriteSchedules(ctx context.Context, schedules []record.Schedule) error
+	ReadBudgets(ctx context.Context) ([]record.Budget, error)
+	WriteBudgets(ctx context.Context, budgets []record.Budget) error
+	AppendAuditEvent(ctx context.Context, event record.AuditEvent) error
+	ReadAuditEvents(ctx context.Context, transactionID string) ([]record.AuditEvent, error)
+	DataFileStatus() (record.DataFileStatus, error)
+	CheckWritable() error
+	RateStatus() record.RateStatus
+	ProbeRateProvider(ctx context.Context) error
+}
+
+type ScheduleI interface {
+	Add(ctx context.Context, schedule record.Schedule) (record.Schedule, error)
+	List() []record.Schedule
+	Get(id string) (record.Schedule, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type BudgetI interface {
+	Add(ctx context.Context, budget record.Budget) (record.Budget, error)
+	List() []record.Budget
+	Delete(ctx context.Context, id string) error
+	Status(ctx context.Context, id string, month time.Time) (record.BudgetStatus, error)
+	Statuses(ctx context.Context, month time.Time) ([]record.BudgetStatus, error)
+}
+
+type ImporterI interface {
+	Import(ctx context.Context, r io.Reader, opts record.ImportOptions) (record.ImportReport, error)
+}
+
+type ExporterI interface {
+	Export(ctx context.Context, w io.Writer, opts record.ExportOptions) error
+}
+
+// TenantI gives the modules working on the data of one tenant.
+type TenantI interface {
+	Transaction() TxI
+	Scheduler() ScheduleI
+	Budget() BudgetI
+	Importer() ImporterI
+	Exporter() ExporterI
+}
+
+type TenantsI interface {
+	Get(name string) (TenantI, error)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, alert record.BudgetAlert) error
 }
 
 type RepoHandle interface {
-	AppendRecords(records []record.TransactionRecord) (int, error)
-	ReadRecords(records []record.TransactionRecord) (int, error)
+	AppendRecords(ctx context.Context, records []record.TransactionRecord) (int, error)
+	ReadRecords(ctx context.Context, records []record.TransactionRecord) (int, error)
 	Close() error
 }
@@ -0,0 +1,39 @@
+package types
+
+import "context"
+
+const (
+	SourceHTTP      = "http"
+	SourceScheduler = "scheduler"
+	SourceImport    = "import"
+	SourceCLI       = "cli"
+)
+
+// DefaultTenant is the tenant of the data file given by the configuration. The other tenants have their
+// own files, see package tenant.
+const DefaultTenant = "default"
+
+// Principal is the caller on whose behalf a change is made. It travels in the context of a request. Scopes
+// are the permissions of an authenticated caller, Tenant the tenant named by its token.
+type Principal struct {
+	Name     string   `json:"name"`
+	Source   string   `json:"source"`
+	ClientIP string   `json:"client_ip,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Tenant   string   `json:"tenant,omitempty"`
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal of the context, or an anonymous one.
+func PrincipalFrom(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey{}).(Principal); ok {
+		return p
+	}
+
+	return Principal{Name: "anonymous"}
+}